@@ -0,0 +1,175 @@
+// Package achievement defines the milestones that users unlock with
+// their karma and the badges that they earn for them.
+package achievement
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/troyxmccall/janet/i18n"
+)
+
+// The kinds of milestones.
+const (
+	// First is unlocked by the first karma that a user receives.
+	First = "first"
+
+	// Total is unlocked by reaching a number of points.
+	Total = "total"
+
+	// Streak is unlocked by receiving karma on a number
+	// of days in a row.
+	Streak = "streak"
+
+	// Generous is awarded to whoever gave the most karma in a
+	// month, once the month is over. It can be won every month.
+	Generous = "generous"
+)
+
+// monthFormat is the format of the month of a Generous badge.
+const monthFormat = "2006-01"
+
+// A Milestone is an achievement that users can unlock.
+type Milestone struct {
+	Kind string
+
+	// Value is the amount of points of a Total and
+	// the amount of days of a Streak milestone.
+	Value int
+}
+
+// Default are the milestones used if none are configured.
+func Default() []*Milestone {
+	return []*Milestone{
+		{Kind: First},
+		{Kind: Total, Value: 10},
+		{Kind: Total, Value: 50},
+		{Kind: Total, Value: 100},
+		{Kind: Total, Value: 500},
+		{Kind: Total, Value: 1000},
+		{Kind: Streak, Value: 3},
+		{Kind: Streak, Value: 7},
+		{Kind: Streak, Value: 30},
+		{Kind: Generous},
+	}
+}
+
+// Parse parses a milestone in the `first`, `total:<points>`,
+// `streak:<days>` or `generous` format.
+func Parse(spec string) (*Milestone, error) {
+	kind, value := strings.ToLower(strings.TrimSpace(spec)), ""
+	if i := strings.Index(kind, ":"); i >= 0 {
+		kind, value = kind[:i], kind[i+1:]
+	}
+
+	switch kind {
+	case First, Generous:
+		if value != "" {
+			return nil, fmt.Errorf("milestone %q does not take a value", spec)
+		}
+
+		return &Milestone{Kind: kind}, nil
+
+	case Total, Streak:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("milestone %q needs a positive value, e.g. %s:10", spec, kind)
+		}
+
+		return &Milestone{Kind: kind, Value: n}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown milestone %q, please use first, total:<points>, streak:<days> or generous", spec)
+	}
+}
+
+// Sort sorts milestones by kind, in the order of Default,
+// and then by value.
+func Sort(milestones []*Milestone) {
+	order := map[string]int{First: 0, Total: 1, Streak: 2, Generous: 3}
+	sort.SliceStable(milestones, func(i, j int) bool {
+		a, b := milestones[i], milestones[j]
+		if a.Kind != b.Kind {
+			return order[a.Kind] < order[b.Kind]
+		}
+		return a.Value < b.Value
+	})
+}
+
+// Badge returns the badge of a milestone. month is the
+// month that a Generous badge was won for.
+func (m *Milestone) Badge(month time.Time) *Badge {
+	badge := &Badge{Kind: m.Kind, Value: m.Value}
+	if m.Kind == Generous {
+		badge.Month = time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	return badge
+}
+
+// A Badge is an unlocked milestone.
+type Badge struct {
+	Kind  string
+	Value int
+
+	// Month is the month of a Generous badge.
+	Month time.Time
+}
+
+// ParseBadge parses the key of a badge.
+func ParseBadge(key string) (*Badge, error) {
+	if strings.HasPrefix(key, Generous+":") {
+		month, err := time.Parse(monthFormat, strings.TrimPrefix(key, Generous+":"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid badge %q: %v", key, err)
+		}
+
+		return &Badge{Kind: Generous, Month: month}, nil
+	}
+
+	m, err := Parse(key)
+	if err != nil || m.Kind == Generous {
+		return nil, fmt.Errorf("invalid badge %q", key)
+	}
+
+	return m.Badge(time.Time{}), nil
+}
+
+// Key identifies the badge. Users earn each badge only once.
+func (b *Badge) Key() string {
+	switch b.Kind {
+	case Total, Streak:
+		return fmt.Sprintf("%s:%d", b.Kind, b.Value)
+	case Generous:
+		return Generous + ":" + b.Month.Format(monthFormat)
+	default:
+		return b.Kind
+	}
+}
+
+// badgeEmoji are the emoji of the badges, as Slack
+// shortcodes and as text for the web UI.
+var badgeEmoji = map[string][2]string{
+	First:    {":seedling:", "\U0001F331"},
+	Total:    {":trophy:", "\U0001F3C6"},
+	Streak:   {":fire:", "\U0001F525"},
+	Generous: {":gift:", "\U0001F381"},
+}
+
+// Emoji returns the badge's emoji as a Slack shortcode.
+func (b *Badge) Emoji() string {
+	return badgeEmoji[b.Kind][0]
+}
+
+// Symbol returns the badge's emoji as text.
+func (b *Badge) Symbol() string {
+	return badgeEmoji[b.Kind][1]
+}
+
+// Name describes the badge in a locale, e.g. `100 points`.
+func (b *Badge) Name(locale *i18n.Locale) string {
+	return locale.T("badge."+b.Kind, "Points", b.Value, "Days", b.Value, "Month", locale.Month(b.Month))
+}
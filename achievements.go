@@ -0,0 +1,165 @@
+package janet
+
+import (
+	"strings"
+	"time"
+
+	"github.com/troyxmccall/janet/achievement"
+	"github.com/troyxmccall/janet/database"
+	"github.com/troyxmccall/janet/i18n"
+	"github.com/troyxmccall/janet/persona"
+	"github.com/troyxmccall/janet/webhook"
+
+	"github.com/nlopes/slack"
+)
+
+// An unlock is a badge that a user earned.
+type unlock struct {
+	user  string
+	badge *achievement.Badge
+}
+
+// unlockAchievements awards the badges that a karma operation unlocked,
+// has Good Janet announce them in the channel that the karma was given
+// in and sends a milestone event to the webhooks for each of them.
+func (b *Bot) unlockAchievements(record *database.Points, channel, thread string, locale *i18n.Locale) {
+	if len(b.Config.Achievements) == 0 {
+		return
+	}
+
+	for _, u := range b.unlocked(record, time.Now()) {
+		earned, err := b.Config.DB.InsertAchievement(u.user, u.badge.Key())
+		if err != nil {
+			b.Config.Log.Err(err).KV("user", u.user).KV("badge", u.badge.Key()).Error("could not award badge")
+			continue
+		}
+		if !earned {
+			continue
+		}
+
+		b.Config.Log.KV("user", u.user).KV("badge", u.badge.Key()).Info("badge earned")
+
+		if channel != "" {
+			data := persona.Data{"User": u.user, "Badge": u.badge.Emoji() + " " + u.badge.Name(locale)}
+			text := b.message(locale, "", persona.MessageAchievement, data)
+			headline := b.markdownMessage(locale, "", persona.MessageAchievement, data)
+			b.SendBlocks(text, b.userBlocks(&database.User{Name: u.user}, headline), channel, thread, "")
+		}
+
+		total := 0
+		if user, err := b.Config.DB.GetUser(u.user); err == nil {
+			total = user.Points
+		}
+
+		err = webhook.Send(b.Config.DB, webhook.EventMilestone, &webhook.Milestone{
+			User:  u.user,
+			Badge: u.badge.Key(),
+			Name:  u.badge.Name(i18n.Default()),
+			Total: total,
+		})
+		if err != nil {
+			b.Config.Log.Err(err).Error("could not queue webhook event")
+		}
+	}
+}
+
+// unlocked returns the badges that may have been unlocked by a karma
+// operation, including ones that were earned before. Only points given
+// by someone else count towards the receiver's milestones. Lookups that
+// fail are logged and skip the milestones that depend on them.
+func (b *Bot) unlocked(record *database.Points, now time.Time) []*unlock {
+	var (
+		unlocks []*unlock
+		user    *database.User
+		stats   *database.UserStats
+	)
+
+	if record.Points > 0 && record.From != record.To && record.From != "janet" {
+		var err error
+		user, err = b.Config.DB.GetUser(record.To)
+		if err == nil {
+			stats, err = b.Config.DB.GetUserStats(record.To, now)
+		}
+		if err != nil {
+			b.Config.Log.Err(err).KV("user", record.To).Error("could not look up achievements")
+			user, stats = nil, nil
+		}
+	}
+
+	for _, m := range b.Config.Achievements {
+		var name string
+
+		switch {
+		case m.Kind == achievement.Generous:
+			name = b.generousGiver(now)
+		case stats == nil:
+		case m.Kind == achievement.First && stats.Received == 1,
+			m.Kind == achievement.Total && user.Points-record.Points < m.Value && user.Points >= m.Value,
+			m.Kind == achievement.Streak && stats.Streak >= m.Value:
+			name = record.To
+		}
+
+		if name != "" {
+			unlocks = append(unlocks, &unlock{user: name, badge: m.Badge(lastMonth(now))})
+		}
+	}
+
+	return unlocks
+}
+
+// lastMonth returns the start of the month before now's.
+func lastMonth(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, now.Location())
+}
+
+// generousGiver returns who gave the most karma last month, the first
+// time it is called in a month. Afterwards, or if nobody gave any
+// karma, it returns an empty string.
+func (b *Bot) generousGiver(now time.Time) string {
+	month := lastMonth(now)
+
+	b.generousMonthMutex.Lock()
+	defer b.generousMonthMutex.Unlock()
+
+	if b.generousMonth.Equal(month) {
+		return ""
+	}
+
+	givers, err := b.Config.DB.GetGivers(month, month.AddDate(0, 1, 0), 1)
+	if err != nil {
+		b.Config.Log.Err(err).Error("could not look up the most generous giver")
+		return ""
+	}
+	b.generousMonth = month
+
+	if len(givers) == 0 {
+		return ""
+	}
+
+	return givers[0].Name
+}
+
+// badges lists the badges that a user earned, with their emoji.
+func (b *Bot) badges(name string, locale *i18n.Locale) (string, error) {
+	achievements, err := b.Config.DB.GetAchievements(name)
+	if err != nil {
+		return "", err
+	}
+
+	var badges []string
+	for _, a := range achievements {
+		badge, err := achievement.ParseBadge(a.Badge)
+		if err != nil {
+			continue
+		}
+
+		badges = append(badges, badge.Emoji()+" "+badge.Name(locale))
+	}
+
+	return strings.Join(badges, " · "), nil
+}
+
+// badgesBlock shows the badges of a user below their karma.
+func badgesBlock(text string) slack.Block {
+	return slack.NewContextBlock("", markdown("%s", text))
+}
@@ -0,0 +1,197 @@
+package janet
+
+import (
+	"strings"
+
+	"github.com/troyxmccall/janet/database"
+	"github.com/troyxmccall/janet/munge"
+	"github.com/troyxmccall/janet/persona"
+
+	"github.com/nlopes/slack"
+)
+
+// isAdmin checks whether the user that sent a message
+// may run admin commands.
+func (b *Bot) isAdmin(ev *slack.MessageEvent) (bool, error) {
+	name, err := b.getUserNameByID(ev.User)
+	if err != nil {
+		return false, err
+	}
+
+	return b.Config.Admins.Contains(strings.ToLower(name)), nil
+}
+
+// mustBeAdmin replies to non-admins and returns false
+// if the sender of the message is not an admin.
+func (b *Bot) mustBeAdmin(ev *slack.MessageEvent) bool {
+	admin, err := b.isAdmin(ev)
+	if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+		return false
+	}
+
+	if !admin {
+		b.SendMessage(b.message(b.locale(ev.User), "badJanet", persona.MessageNotAdmin, nil), ev.Channel, ev.ThreadTimestamp, "badJanet")
+	}
+
+	return admin
+}
+
+// resolveUser turns a slack user reference into a lowercase
+// username without resolving aliases.
+func (b *Bot) resolveUser(user string) (string, error) {
+	if match := regexps.SlackUser.FindStringSubmatch(user); len(match) > 0 {
+		var err error
+		user, err = b.getUserNameByID(match[1])
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return strings.ToLower(strings.TrimPrefix(user, "@")), nil
+}
+
+func (b *Bot) manageAliases(ev *slack.MessageEvent) {
+	match := regexps.Alias.FindStringSubmatch(ev.Text)
+	if len(match) == 0 {
+		return
+	}
+
+	if !b.mustBeAdmin(ev) {
+		return
+	}
+
+	var (
+		action = match[1]
+		locale = b.locale(ev.User)
+		text   string
+	)
+
+	switch action {
+	case "add":
+		if match[2] == "" || match[3] == "" {
+			text = locale.T("alias.add.usage")
+			break
+		}
+
+		alias, err := b.resolveUser(match[2])
+		if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+			return
+		}
+		user, err := b.resolveUser(match[3])
+		if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+			return
+		}
+
+		err = b.Config.DB.InsertAlias(alias, user)
+		if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+			return
+		}
+
+		text = locale.T("alias.added", "Alias", munge.Munge(alias), "User", munge.Munge(user))
+
+	case "remove":
+		if match[2] == "" || match[3] != "" {
+			text = locale.T("alias.remove.usage")
+			break
+		}
+
+		alias, err := b.resolveUser(match[2])
+		if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+			return
+		}
+
+		err = b.Config.DB.DeleteAlias(alias)
+		if err == database.ErrNoSuchAlias {
+			text = locale.T("notalias", "Alias", munge.Munge(alias))
+			break
+		}
+		if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+			return
+		}
+
+		text = locale.T("alias.removed", "Alias", munge.Munge(alias))
+
+	case "list":
+		aliases, err := b.Config.DB.GetAliases()
+		if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+			return
+		}
+
+		if len(aliases) == 0 {
+			text = locale.T("noaliases")
+			break
+		}
+
+		text = "*" + locale.T("aliases") + "*\n"
+		for _, alias := range aliases {
+			text += locale.T("aliases.line", "Alias", munge.Munge(alias.Alias), "User", munge.Munge(alias.User)) + "\n"
+		}
+	}
+
+	b.SendMessage(text, ev.Channel, ev.ThreadTimestamp, "")
+}
+
+func (b *Bot) manageBlacklist(ev *slack.MessageEvent) {
+	match := regexps.Blacklist.FindStringSubmatch(ev.Text)
+	if len(match) == 0 {
+		return
+	}
+
+	if !b.mustBeAdmin(ev) {
+		return
+	}
+
+	var (
+		action = match[1]
+		locale = b.locale(ev.User)
+		text   string
+	)
+
+	switch action {
+	case "add", "remove":
+		if match[2] == "" {
+			text = locale.T("blacklist.usage", "Action", action)
+			break
+		}
+
+		user, err := b.resolveUser(match[2])
+		if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+			return
+		}
+
+		if action == "add" {
+			err = b.Config.DB.InsertBlacklist(user)
+			text = locale.T("blacklisted", "User", munge.Munge(user))
+		} else {
+			err = b.Config.DB.DeleteBlacklist(user)
+			text = locale.T("unblacklisted", "User", munge.Munge(user))
+		}
+
+		if err == database.ErrNotBlacklisted {
+			text = locale.T("notblacklisted", "User", munge.Munge(user))
+			break
+		}
+		if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+			return
+		}
+
+	case "list":
+		users, err := b.Config.DB.GetBlacklist()
+		if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+			return
+		}
+
+		if len(users) == 0 {
+			text = locale.T("noblacklist")
+			break
+		}
+
+		munged := make([]string, len(users))
+		for i, user := range users {
+			munged[i] = munge.Munge(user)
+		}
+		text = "*" + locale.T("blacklist") + "*\n" + strings.Join(munged, "\n")
+	}
+
+	b.SendMessage(text, ev.Channel, ev.ThreadTimestamp, "")
+}
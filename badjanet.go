@@ -0,0 +1,141 @@
+package janet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/nlopes/slack"
+	"github.com/troyxmccall/janet/database"
+	"github.com/troyxmccall/janet/persona"
+)
+
+// roast makes Bad Janet roast a user, or the sender if
+// no user is passed.
+func (b *Bot) roast(ev *slack.MessageEvent, whichJanet string) {
+	match := regexps.Roast.FindStringSubmatch(ev.Text)
+	if len(match) == 0 {
+		return
+	}
+
+	var name string
+	var err error
+	if match[2] != "" {
+		name, err = b.parseUser(match[2])
+	} else {
+		name, err = b.getUserNameByID(ev.User)
+	}
+	if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+		return
+	}
+	name = strings.ToLower(name)
+
+	points := 0
+	user, err := b.Config.DB.GetUser(name)
+	switch {
+	case err == nil:
+		points = user.Points
+	case err != database.ErrNoSuchUser && b.handleError(err, ev.Channel, ev.ThreadTimestamp):
+		return
+	}
+
+	roast := b.Config.Catalog.Roast(persona.Bad, b.locale(ev.User), persona.Data{"User": name, "Points": points})
+	if roast == "" {
+		return
+	}
+
+	b.SendMessage(roast, ev.Channel, ev.ThreadTimestamp, whichJanet)
+}
+
+// printBottom lists the users who had the most points taken.
+func (b *Bot) printBottom(ev *slack.MessageEvent, whichJanet string) {
+	match := regexps.Bottom.FindStringSubmatch(ev.Text)
+	if len(match) == 0 {
+		return
+	}
+
+	limit := b.Config.LeaderboardLimit
+	if match[2] != "" {
+		var err error
+		limit, err = strconv.Atoi(match[2])
+		if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+			return
+		}
+	}
+
+	bottom, err := b.Config.DB.GetBottom(limit)
+	if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+		return
+	}
+
+	locale := b.locale(ev.User)
+	if len(bottom) == 0 {
+		b.SendMessage(locale.T("nobottom"), ev.Channel, ev.ThreadTimestamp, whichJanet)
+		return
+	}
+
+	title := locale.T("bottom", "Limit", limit)
+	text := fmt.Sprintf("*%s*\n", title)
+	for i, user := range bottom {
+		text += locale.Render("bottomline", false, leaderboardLine(i, user)) + "\n"
+	}
+
+	blocks := b.leaderboardBlocks(locale, title, "", "bottomline", bottom)
+	b.SendBlocks(text, blocks, ev.Channel, ev.ThreadTimestamp, whichJanet)
+}
+
+// An Identity is how Good Janet presents the messages that she sends
+// for Bad Janet when there is no Bad Janet bot. Name and Icon override
+// the bot user's name and icon, where Icon is an emoji like
+// `:smiling_imp:` or the URL of an image. Prefix is put in front of
+// the messages' text.
+type Identity struct {
+	Name, Icon, Prefix string
+}
+
+// chat returns the connection that a janet's messages are sent with.
+func (b *Bot) chat(whichJanet string) ChatService {
+	if whichJanet == "badJanet" && b.Config.BadJanetSlack != nil {
+		return b.Config.BadJanetSlack
+	}
+
+	return b.Config.Slack
+}
+
+// post sends a message as one of the janets, made of blocks if any are
+// passed. When Good Janet stands in for Bad Janet, the message gets Bad
+// Janet's identity if one is configured.
+func (b *Bot) post(text string, blocks []slack.Block, channel, thread, whichJanet string) error {
+	chat := b.chat(whichJanet)
+
+	if whichJanet == "badJanet" && b.Config.BadJanetSlack == nil {
+		identity := b.Config.BadJanetIdentity
+		if identity.Prefix != "" {
+			text = identity.Prefix + text
+			if len(blocks) > 0 && len(blocks) < maxBlocks {
+				prefix := slack.NewContextBlock("", markdown("%s", identity.Prefix))
+				blocks = append([]slack.Block{prefix}, blocks...)
+			}
+		}
+
+		if identity.Name != "" || identity.Icon != "" {
+			err := chat.SendAs(channel, thread, text, &identity, blocks...)
+			if err == nil {
+				return nil
+			}
+
+			// e.g. the bot lacks the chat:write.customize scope
+			b.Config.Log.Err(err).KV("channel", channel).Error("could not send as bad janet, sending as good janet")
+		}
+	}
+
+	if len(blocks) > 0 {
+		return chat.SendBlocks(channel, thread, text, blocks...)
+	}
+
+	msg := chat.NewOutgoingMessage(text, channel)
+	msg.ThreadTimestamp = thread
+	chat.SendMessage(msg)
+
+	return nil
+}
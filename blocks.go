@@ -0,0 +1,152 @@
+package janet
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/troyxmccall/janet/database"
+	"github.com/troyxmccall/janet/i18n"
+	"github.com/troyxmccall/janet/munge"
+	"github.com/troyxmccall/janet/persona"
+
+	"github.com/nlopes/slack"
+)
+
+// maxBlocks is the maximum amount of blocks in a Slack message.
+const maxBlocks = 50
+
+// SendBlocks sends a message made of Block Kit blocks. text is shown
+// in notifications and by clients that cannot show blocks, and is
+// sent as a plain message instead if blocks are disabled or cannot
+// be sent.
+func (b *Bot) SendBlocks(text string, blocks []slack.Block, channel, thread string, whichJanet string) {
+	if !b.Config.Blocks {
+		b.SendMessage(text, channel, thread, whichJanet)
+		return
+	}
+
+	err := b.post(text, blocks, channel, thread, whichJanet)
+	if err != nil {
+		b.Config.Log.Err(err).KV("channel", channel).Error("could not send blocks, falling back to text")
+		b.SendMessage(text, channel, thread, whichJanet)
+		return
+	}
+
+	b.sendQuote(channel, thread, whichJanet)
+}
+
+// DMUserBlocks sends a message made of blocks directly to a Slack user.
+func (b *Bot) DMUserBlocks(text string, blocks []slack.Block, user string, whichJanet string) {
+	_, _, channel, err := b.chat(whichJanet).OpenIMChannel(user)
+	if err != nil {
+		b.Config.Log.Err(err).KV("user", user).Error("could not open IM channel with user")
+		return
+	}
+
+	b.SendBlocks(text, blocks, channel, "", whichJanet)
+}
+
+// rememberUser stores the Slack ID of a user, so that their
+// avatar can be shown next to their name.
+func (b *Bot) rememberUser(name, id string) {
+	b.userIDs.Store(strings.ToLower(name), id)
+}
+
+// avatar returns the URL of a user's avatar, or an empty
+// string if janet has not seen the user yet.
+func (b *Bot) avatar(name string) string {
+	id, ok := b.userIDs.Load(name)
+	if !ok {
+		return ""
+	}
+
+	user, err := b.Config.Slack.GetUserInfo(id.(string))
+	if err != nil {
+		b.Config.Log.Err(err).KV("user", name).Info("could not look up avatar")
+		return ""
+	}
+
+	return user.Profile.Image48
+}
+
+func markdown(format string, a ...interface{}) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf(format, a...), false, false)
+}
+
+// userSection shows text next to a user's avatar, if there is one.
+func (b *Bot) userSection(name string, text *slack.TextBlockObject) *slack.SectionBlock {
+	var accessory *slack.Accessory
+	if url := b.avatar(name); url != "" {
+		accessory = slack.NewAccessory(slack.NewImageBlockElement(url, name))
+	}
+
+	return slack.NewSectionBlock(text, nil, accessory)
+}
+
+// userContext shows text in small print next to
+// a user's avatar, if there is one.
+func (b *Bot) userContext(name string, text *slack.TextBlockObject) *slack.ContextBlock {
+	var elements []slack.MixedElement
+	if url := b.avatar(name); url != "" {
+		elements = append(elements, slack.NewImageBlockElement(url, name))
+	}
+
+	return slack.NewContextBlock("", append(elements, text)...)
+}
+
+// pointsBlocks confirms a karma operation.
+func (b *Bot) pointsBlocks(user *database.User, headline, change string) []slack.Block {
+	return []slack.Block{
+		b.userSection(user.Name, markdown("%s", headline)),
+		slack.NewContextBlock("", markdown("%s", change)),
+	}
+}
+
+// userBlocks shows a user's karma.
+func (b *Bot) userBlocks(user *database.User, headline string) []slack.Block {
+	return []slack.Block{
+		b.userSection(user.Name, markdown("%s", headline)),
+	}
+}
+
+// leaderboardLine returns the data of a line of a leaderboard.
+func leaderboardLine(i int, user *database.User) persona.Data {
+	return persona.Data{"Rank": i + 1, "User": munge.Munge(user.Name), "Points": user.Points}
+}
+
+// leaderboardBlocks lists the users of a leaderboard with their avatars,
+// rendering each user with the line message.
+func (b *Bot) leaderboardBlocks(locale *i18n.Locale, title, url, line string, leaderboard database.Leaderboard) []slack.Block {
+	header := fmt.Sprintf("*%s*", title)
+	if url != "" {
+		header += fmt.Sprintf("\n<%s|%s>", url, locale.T("fullleaderboard"))
+	}
+
+	blocks := []slack.Block{
+		slack.NewSectionBlock(markdown("%s", header), nil, nil),
+	}
+
+	// long leaderboards don't fit into a message as one block per user
+	if len(leaderboard) > maxBlocks-1 {
+		var lines []string
+		for i, user := range leaderboard {
+			lines = append(lines, locale.Render(line, false, leaderboardLine(i, user)))
+		}
+
+		return append(blocks, slack.NewSectionBlock(markdown("%s", strings.Join(lines, "\n")), nil, nil))
+	}
+
+	for i, user := range leaderboard {
+		blocks = append(blocks, b.userContext(user.Name, markdown("%s", locale.Render(line, true, leaderboardLine(i, user)))))
+	}
+
+	return blocks
+}
+
+// throwbackBlocks shows a karma operation from the past.
+func (b *Bot) throwbackBlocks(throwback *database.Throwback, headline, details string) []slack.Block {
+	return []slack.Block{
+		b.userSection(throwback.To, markdown("%s", headline)),
+		slack.NewContextBlock("", markdown("%s", details)),
+	}
+}
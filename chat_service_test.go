@@ -6,7 +6,23 @@ type TestChatService struct {
 	IncomingEvents chan slack.RTMEvent
 
 	SentMessages []*slack.OutgoingMessage
+	SentBlocks   []*SentBlocks
 	id           int
+
+	// SentAs are the identities of the messages sent with SendAs
+	SentAs []*Identity
+
+	// Locales are the Slack locales of the users, keyed by ID
+	Locales map[string]string
+
+	// Users are the users returned by GetUsers
+	Users []slack.User
+}
+
+// SentBlocks is a message sent with SendBlocks.
+type SentBlocks struct {
+	Channel, Thread, Text string
+	Blocks                []slack.Block
 }
 
 func newTestChatService() ChatService {
@@ -23,11 +39,19 @@ func (t *TestChatService) OpenIMChannel(user string) (bool, bool, string, error)
 
 func (t *TestChatService) GetUserInfo(user string) (*slack.User, error) {
 	return &slack.User{
-		ID:   user,
-		Name: user,
+		ID:     user,
+		Name:   user,
+		Locale: t.Locales[user],
+		Profile: slack.UserProfile{
+			Image48: "https://avatars.example.com/" + user,
+		},
 	}, nil
 }
 
+func (t *TestChatService) GetUsers() ([]slack.User, error) {
+	return t.Users, nil
+}
+
 func (t *TestChatService) NewOutgoingMessage(text string, channel string, options ...slack.RTMsgOption) *slack.OutgoingMessage {
 	t.id++
 	return &slack.OutgoingMessage{
@@ -41,3 +65,26 @@ func (t *TestChatService) NewOutgoingMessage(text string, channel string, option
 func (t *TestChatService) SendMessage(m *slack.OutgoingMessage) {
 	t.SentMessages = append(t.SentMessages, m)
 }
+
+// SendBlocks records the blocks, and the text
+// fallback as if it was sent as a message.
+func (t *TestChatService) SendBlocks(channel, thread, text string, blocks ...slack.Block) error {
+	t.SentBlocks = append(t.SentBlocks, &SentBlocks{
+		Channel: channel,
+		Thread:  thread,
+		Text:    text,
+		Blocks:  blocks,
+	})
+
+	m := t.NewOutgoingMessage(text, channel)
+	m.ThreadTimestamp = thread
+	t.SentMessages = append(t.SentMessages, m)
+
+	return nil
+}
+
+// SendAs records the identity and the message like SendBlocks.
+func (t *TestChatService) SendAs(channel, thread, text string, identity *Identity, blocks ...slack.Block) error {
+	t.SentAs = append(t.SentAs, identity)
+	return t.SendBlocks(channel, thread, text, blocks...)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/troyxmccall/janet/database"
+
+	"github.com/aybabtme/log"
+)
+
+// runBackups backs up the database into dir every interval
+// until the context is cancelled.
+func runBackups(ctx context.Context, db *database.DB, dir string, interval time.Duration, keep int, ll *log.Log) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			path, err := db.BackupDir(ctx, dir, keep)
+			if err != nil {
+				ll.Err(err).KV("dir", dir).Error("could not back up sqlite db")
+				continue
+			}
+
+			ll.KV("path", path).Info("backed up sqlite db")
+		}
+	}
+}
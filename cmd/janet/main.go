@@ -1,41 +1,27 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"strings"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
 	"github.com/troyxmccall/janet"
-	"github.com/troyxmccall/janet/database"
+	"github.com/troyxmccall/janet/config"
+	"github.com/troyxmccall/janet/health"
+	"github.com/troyxmccall/janet/metrics"
 	janetui "github.com/troyxmccall/janet/ui"
 	"github.com/troyxmccall/janet/ui/blankui"
 	"github.com/troyxmccall/janet/ui/webui"
 
 	"github.com/aybabtme/log"
-	"github.com/nlopes/slack"
 	"github.com/troyxmccall/envy"
 )
 
-// cli flags
-var (
-	token            = flag.String("token", "", "slack RTM token for Good Janet")
-	badJanetToken    = flag.String("badJanetToken", "", "slack RTM token for Bad Janet")
-	dbpath           = flag.String("db", "./db.sqlite3", "path to sqlite database")
-	maxpoints        = flag.Int("maxpoints", 6, "the maximum amount of points that users can give/take at once")
-	leaderboardlimit = flag.Int("leaderboardlimit", 10, "the default amount of users to list in the leaderboard")
-	debug            = flag.Bool("debug", false, "set debug mode")
-	webuitotp        = flag.String("webui.totp", "", "totp key")
-	webuipath        = flag.String("webui.path", "", "path to web UI files")
-	webuilistenaddr  = flag.String("webui.listenaddr", "", "address to listen and serve the web ui on")
-	webuiurl         = flag.String("webui.url", "", "url address for accessing the web ui")
-	motivate         = flag.Bool("motivate", true, "toggle motivate.im support")
-	blacklist        = make(janet.StringList, 0)
-	reactji          = flag.Bool("reactji", true, "use reactji as karma operations")
-	upvotereactji    = make(janet.StringList, 0)
-	downvotereactji  = make(janet.StringList, 0)
-	aliases          = make(janet.StringList, 0)
-	selfkarma        = flag.Bool("selfkarma", false, "allow users to add/remove karma to themselves")
-)
-
 func main() {
 	// logging
 
@@ -43,119 +29,261 @@ func main() {
 
 	// cli flags
 
-	flag.Var(&blacklist, "blacklist", "blacklist users from having karma operations applied on them")
-	flag.Var(&aliases, "alias", "alias different users to one user")
-	flag.Var(&upvotereactji, "reactji.upvote", "a list of reactjis to use for upvotes")
-	flag.Var(&downvotereactji, "reactji.downvote", "a list of reactjis to use for downvotes")
+	opts := defineFlags(flag.CommandLine)
 
 	envy.Parse("KB")
 	flag.Parse()
 
-	// startup
+	// config file
 
-	ll.Info("starting both janets")
+	explicit := explicitFlags(flag.CommandLine)
+	if *opts.config != "" {
+		file, err := config.Load(*opts.config)
+		if err != nil {
+			ll.Err(err).Fatal("could not load config file")
+		}
 
-	// reactjis
+		err = file.Apply(flag.CommandLine, explicit)
+		if err != nil {
+			ll.KV("path", *opts.config).Err(err).Fatal("could not apply config file")
+		}
+		opts.tenants = file.Tenants
+	}
 
-	// reactji defaults
-	if len(upvotereactji) == 0 {
-		upvotereactji.Set("+1")
-		upvotereactji.Set("thumbsup")
-		upvotereactji.Set("thumbsup_all")
+	err := opts.validate()
+	if err != nil {
+		ll.Err(err).Fatal("invalid configuration (see `janet -h` for help)")
 	}
-	if len(downvotereactji) == 0 {
-		downvotereactji.Set("-1")
-		downvotereactji.Set("thumbsdown")
+
+	// startup
+
+	tenants := newTenants(opts, ll)
+
+	for _, t := range tenants {
+		err := t.connect(*opts.debug)
+		if err != nil {
+			t.log.Err(err).Fatal("could not start tenant")
+		}
 	}
-	reactjiConfig := &janet.ReactjiConfig{
-		Enabled:  *reactji,
-		Upvote:   upvotereactji,
-		Downvote: downvotereactji,
+
+	// shutdown on SIGINT/SIGTERM
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// health checks
+
+	checker := health.New()
+
+	// janet
+
+	var ui janetui.Provider
+	if *opts.webuilistenaddr != "" {
+		webuiConfig := &webui.Config{
+			ListenAddr: *opts.webuilistenaddr,
+			URL:        *opts.webuiurl,
+			FilesPath:  *opts.webuipath,
+			Log:        ll.KV("provider", "webui"),
+			Debug:      *opts.debug,
+			Metrics:    *opts.metricsenabled && *opts.metricsaddr == "",
+			Health:     checker,
+			Tenants:    make(map[string]*webui.Tenant),
+		}
+
+		for _, t := range tenants {
+			if t.name != "" {
+				webuiConfig.Tenants[t.name] = t.webui()
+				continue
+			}
+
+			webuiConfig.TOTP = *t.opts.webuitotp
+			webuiConfig.DB = t.db
+			webuiConfig.LeaderboardLimit = *t.opts.leaderboardlimit
+			webuiConfig.Locale = t.locale()
+		}
+
+		provider, err := webui.New(webuiConfig)
+		if err != nil {
+			ll.Err(err).Fatal("could not initialize web ui")
+		}
+		ui = provider
+
+		for _, t := range tenants {
+			if t.name == "" {
+				t.startBot(provider, checker, *opts.debug)
+			} else {
+				t.startBot(provider.Tenant(t.name), checker, *opts.debug)
+			}
+		}
+	} else {
+		ui = blankui.New()
+
+		for _, t := range tenants {
+			t.startBot(ui, checker, *opts.debug)
+		}
 	}
 
-	// format aliases
-	aliasMap := make(janet.UserAliases, 0)
-	for k := range aliases {
-		users := strings.Split(k, "++")
-		if len(users) <= 1 {
-			ll.Fatal("invalid alias format. see documentation")
+	go func() {
+		if err := ui.Listen(); err != nil {
+			ll.Err(err).Error("web ui stopped")
+			stop()
 		}
+	}()
 
-		user := users[0]
-		for _, alias := range users[1:] {
-			aliasMap[alias] = user
+	// metrics and health endpoints on their own addresses.
+	// they share a server if the addresses are the same.
+
+	routers := make(map[string]*http.ServeMux)
+	router := func(addr string) *http.ServeMux {
+		if _, ok := routers[addr]; !ok {
+			routers[addr] = http.NewServeMux()
 		}
+
+		return routers[addr]
 	}
 
-	// database
+	if *opts.metricsenabled && *opts.metricsaddr != "" {
+		router(*opts.metricsaddr).Handle("/metrics", metrics.Handler())
+	}
 
-	db, err := database.New(&database.Config{
-		Path: *dbpath,
-	})
+	if *opts.healthaddr != "" {
+		router(*opts.healthaddr).Handle("/healthz", checker.LiveHandler())
+		router(*opts.healthaddr).Handle("/readyz", checker.ReadyHandler())
+	}
 
-	if err != nil {
-		ll.KV("path", *dbpath).Err(err).Fatal("could not open sqlite db")
+	var servers []*http.Server
+	for addr, r := range routers {
+		server := &http.Server{
+			Addr:    addr,
+			Handler: r,
+		}
+		servers = append(servers, server)
+
+		go func() {
+			ll.KV("address", server.Addr).Info("serving metrics and health endpoints")
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				ll.Err(err).KV("address", server.Addr).Error("http server stopped")
+				stop()
+			}
+		}()
+	}
+
+	// scheduled backups and webhook deliveries
+
+	var workers sync.WaitGroup
+	for _, t := range tenants {
+		t := t
+		if *opts.backupdir != "" {
+			workers.Add(1)
+			go func() {
+				defer workers.Done()
+				t.runBackups(ctx, *opts.backupdir, *opts.backupinterval, *opts.backupkeep)
+			}()
+		}
+
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			t.runWebhooks(ctx, *opts.webhookinterval)
+		}()
 	}
 
-	// slack
+	// reload the config file on SIGHUP
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	go func() {
+		// changes are reported against the last config
+		// that was loaded, so that they are only logged once
+		current, known := flag.CommandLine, opts.tenants
+
+		for range hup {
+			if *opts.config == "" {
+				ll.Info("got SIGHUP but no config file was passed, ignoring")
+				continue
+			}
+
+			reloaded, fs, err := reloadOptions(current, explicit, *opts.config)
+			if err != nil {
+				ll.Err(err).Error("could not reload config file, keeping the current config")
+				continue
+			}
+
+			for _, name := range restartRequired(current, fs) {
+				ll.KV("option", name).Info("option changed but requires a restart to take effect")
+			}
+
+			for _, name := range reloaded.tenantNames() {
+				if _, ok := known[name]; !ok {
+					ll.KV("tenant", name).Info("tenant was added to the config file but requires a restart to start")
+				}
+			}
 
-	if *token == "" {
-		ll.Fatal("please pass the slack RTM token (see `janet -h` for help)")
+			for _, t := range tenants {
+				t.reload(reloaded)
+			}
+			current, known = fs, reloaded.tenants
+			ll.KV("path", *opts.config).Info("reloaded config file")
+		}
+	}()
+
+	// all tenants' bots run at the same time, the first one to fail stops the others
+	errs := make(chan error, len(tenants))
+	for _, t := range tenants {
+		t := t
+		go func() {
+			err := t.bot.Listen(ctx)
+			if err != nil && t.name != "" {
+				err = fmt.Errorf("tenant %s: %v", t.name, err)
+			}
+			errs <- err
+		}()
 	}
 
-	if *badJanetToken == "" {
-		ll.Fatal("please pass a slack RTM token for badJanet (see `janet -h` for help)")
+	var listenErr error
+	for range tenants {
+		if err := <-errs; err != nil && listenErr == nil {
+			listenErr = err
+			stop()
+		}
 	}
 
-	//TODO: figure out a way to fix this
-	//our current logging library does not implement
-	//log.Logger
-	//slack.SetLogger(*ll)
+	// shutdown
 
-	slackConnection := slack.New(*token, slack.OptionDebug(*debug)).NewRTM()
-	go slackConnection.ManageConnection()
+	ll.Info("shutting down")
 
-	badJanetSlackConnection := slack.New(*badJanetToken, slack.OptionDebug(*debug)).NewRTM()
-	go badJanetSlackConnection.ManageConnection()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *opts.shutdowntimeout)
+	defer cancel()
 
-	// janet
+	if err := ui.Shutdown(shutdownCtx); err != nil {
+		ll.Err(err).Error("could not shut down web ui")
+	}
 
-	var ui janetui.Provider
-	if *webuipath != "" && *webuilistenaddr != "" {
-		ui, err = webui.New(&webui.Config{
-			ListenAddr:       *webuilistenaddr,
-			URL:              *webuiurl,
-			FilesPath:        *webuipath,
-			TOTP:             *webuitotp,
-			LeaderboardLimit: *leaderboardlimit,
-			Log:              ll.KV("provider", "webui"),
-			Debug:            *debug,
-			DB:               db,
-		})
+	for _, server := range servers {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			ll.Err(err).KV("address", server.Addr).Error("could not shut down http server")
+		}
+	}
 
-		if err != nil {
-			ll.Err(err).Fatal("could not initialize web ui")
+	for _, t := range tenants {
+		t.disconnect()
+	}
+
+	// don't close the databases in the middle of a backup or delivery
+	stop()
+	workers.Wait()
+
+	for _, t := range tenants {
+		if err := t.db.Close(); err != nil {
+			t.log.Err(err).Error("could not close sqlite db")
 		}
-	} else {
-		ui = blankui.New()
 	}
-	go ui.Listen()
-
-	bot := janet.New(&janet.Config{
-		Slack:            &janet.SlackChatService{*slackConnection},
-		BadJanetSlack:    &janet.SlackChatService{*badJanetSlackConnection},
-		UI:               ui,
-		Debug:            *debug,
-		MaxPoints:        *maxpoints,
-		LeaderboardLimit: *leaderboardlimit,
-		Log:              ll,
-		DB:               db,
-		UserBlacklist:    blacklist,
-		Reactji:          reactjiConfig,
-		Motivate:         *motivate,
-		Aliases:          aliasMap,
-		SelfPoints:       *selfkarma,
-	})
-
-	bot.Listen()
+
+	if listenErr != nil {
+		ll.Err(listenErr).Fatal("janet stopped")
+	}
+
+	ll.Info("goodbye")
 }
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/troyxmccall/janet"
+	"github.com/troyxmccall/janet/achievement"
+	"github.com/troyxmccall/janet/config"
+	"github.com/troyxmccall/janet/i18n"
+	"github.com/troyxmccall/janet/persona"
+)
+
+// options contains the values of all cli flags.
+type options struct {
+	config, token, badJanetToken, dbpath, messages      *string
+	locale                                              *string
+	maxpoints, leaderboardlimit                         *int
+	transfermax, transferdaily                          *int
+	debug, motivate, reactji, selfkarma, metricsenabled *bool
+	blocks, achievements                                *bool
+	webuitotp, webuipath, webuilistenaddr, webuiurl     *string
+	badjanetname, badjaneticon, badjanetprefix          *string
+	metricsaddr, healthaddr, backupdir                  *string
+	backupkeep                                          *int
+	shutdowntimeout, backupinterval, webhookinterval    *time.Duration
+	blacklist, upvotereactji, downvotereactji, aliases  janet.StringList
+	admins, digests, milestones                         janet.StringList
+
+	// fs is the FlagSet that the options are defined on
+	fs *flag.FlagSet
+
+	// tenants are the tenant sections of the config file
+	tenants map[string]*config.File
+}
+
+// workspaceFlags are the flags whose values belong to a Slack
+// workspace, which tenants do not inherit from the global options.
+var workspaceFlags = map[string]bool{
+	"token":         true,
+	"badJanetToken": true,
+	"db":            true,
+	"webui.totp":    true,
+	"blacklist":     true,
+	"admin":         true,
+	"alias":         true,
+	"digest":        true,
+}
+
+// reloadableFlags are the flags whose values can be changed
+// through the config file without restarting janet.
+var reloadableFlags = map[string]bool{
+	"maxpoints":              true,
+	"leaderboardlimit":       true,
+	"motivate":               true,
+	"selfkarma":              true,
+	"blocks":                 true,
+	"messages":               true,
+	"blacklist":              true,
+	"admin":                  true,
+	"alias":                  true,
+	"reactji":                true,
+	"reactji.upvote":         true,
+	"reactji.downvote":       true,
+	"achievements":           true,
+	"achievements.milestone": true,
+	"transfer.max":           true,
+	"transfer.daily":         true,
+}
+
+// defineFlags defines all cli flags on the passed FlagSet.
+func defineFlags(fs *flag.FlagSet) *options {
+	o := &options{
+		config:           fs.String("config", "", "path to a yaml or toml config file"),
+		token:            fs.String("token", "", "slack RTM token for Good Janet"),
+		badJanetToken:    fs.String("badJanetToken", "", "slack RTM token for Bad Janet. without it, Good Janet sends Bad Janet's replies"),
+		badjanetname:     fs.String("badjanet.name", "", "the name that Good Janet uses for Bad Janet's replies without a Bad Janet token"),
+		badjaneticon:     fs.String("badjanet.icon", "", "the emoji or image url that Good Janet uses for Bad Janet's replies without a Bad Janet token"),
+		badjanetprefix:   fs.String("badjanet.prefix", "", "text that Good Janet puts in front of Bad Janet's replies without a Bad Janet token"),
+		dbpath:           fs.String("db", "./db.sqlite3", "path to sqlite database"),
+		maxpoints:        fs.Int("maxpoints", 6, "the maximum amount of points that users can give/take at once"),
+		leaderboardlimit: fs.Int("leaderboardlimit", 10, "the default amount of users to list in the leaderboard"),
+		debug:            fs.Bool("debug", false, "set debug mode"),
+		webuitotp:        fs.String("webui.totp", "", "totp key"),
+		webuipath:        fs.String("webui.path", "", "path to a directory overriding the embedded web UI files"),
+		webuilistenaddr:  fs.String("webui.listenaddr", "", "address to listen and serve the web ui on"),
+		webuiurl:         fs.String("webui.url", "", "url address for accessing the web ui"),
+		motivate:         fs.Bool("motivate", true, "toggle motivate.im support"),
+		blacklist:        make(janet.StringList, 0),
+		reactji:          fs.Bool("reactji", true, "use reactji as karma operations"),
+		upvotereactji:    make(janet.StringList, 0),
+		downvotereactji:  make(janet.StringList, 0),
+		aliases:          make(janet.StringList, 0),
+		admins:           make(janet.StringList, 0),
+		digests:          make(janet.StringList, 0),
+		selfkarma:        fs.Bool("selfkarma", false, "allow users to add/remove karma to themselves"),
+		blocks:           fs.Bool("blocks", true, "format replies with slack's block kit instead of plain text"),
+		achievements:     fs.Bool("achievements", true, "award badges for karma milestones and announce them"),
+		milestones:       make(janet.StringList, 0),
+		transfermax:      fs.Int("transfer.max", 50, "the maximum amount of points that users can give from their balance at once. 0 turns transfers off"),
+		transferdaily:    fs.Int("transfer.daily", 100, "the maximum amount of points that users can give from their balance in 24 hours. 0 means no limit"),
+		locale:           fs.String("locale", i18n.DefaultTag, "the workspace's language, for users whose slack language janet does not speak"),
+		messages:         fs.String("messages", "", "path to a yaml or toml message catalog customizing the janets' replies and quotes"),
+		metricsenabled:   fs.Bool("metrics", false, "expose prometheus metrics on /metrics"),
+		metricsaddr:      fs.String("metrics.listenaddr", "", "address to serve prometheus metrics on. defaults to the web ui's address"),
+		healthaddr:       fs.String("health.listenaddr", "", "address to serve /healthz and /readyz on, in addition to the web ui"),
+		shutdowntimeout:  fs.Duration("shutdowntimeout", 10*time.Second, "how long to wait for in-flight requests when shutting down"),
+		backupdir:        fs.String("backup.dir", "", "directory to write scheduled database backups to. backups are disabled if empty"),
+		backupinterval:   fs.Duration("backup.interval", 24*time.Hour, "how often to back up the database"),
+		backupkeep:       fs.Int("backup.keep", 7, "the amount of backups to keep. 0 keeps all backups"),
+		webhookinterval:  fs.Duration("webhook.interval", 5*time.Second, "how often to deliver queued webhook events"),
+		fs:               fs,
+	}
+
+	fs.Var(&o.blacklist, "blacklist", "blacklist users from having karma operations applied on them")
+	fs.Var(&o.aliases, "alias", "alias different users to one user")
+	fs.Var(&o.admins, "admin", "users that may manage aliases and the blacklist from slack")
+	fs.Var(&o.digests, "digest", "post a karma digest to a channel on a schedule, e.g. \"C0123ABCD week 0 9 * * mon\"")
+	fs.Var(&o.upvotereactji, "reactji.upvote", "a list of reactjis to use for upvotes")
+	fs.Var(&o.downvotereactji, "reactji.downvote", "a list of reactjis to use for downvotes")
+	fs.Var(&o.milestones, "achievements.milestone", "a milestone that users can unlock: first, total:<points>, streak:<days> or generous")
+
+	return o
+}
+
+// validate checks the final values of all options, no matter
+// whether they come from flags, the environment or a config file.
+func (o *options) validate() error {
+	var problems []string
+
+	if *o.token == "" && len(o.tenants) == 0 {
+		problems = append(problems, "please pass the slack RTM token")
+	}
+
+	if *o.maxpoints < 1 {
+		problems = append(problems, "maxpoints must be at least 1")
+	}
+
+	if *o.leaderboardlimit < 1 {
+		problems = append(problems, "leaderboardlimit must be at least 1")
+	}
+
+	if *o.transfermax < 0 || *o.transferdaily < 0 {
+		problems = append(problems, "transfer.max and transfer.daily must not be negative")
+	}
+
+	for k := range o.aliases {
+		if len(strings.Split(k, "++")) <= 1 {
+			problems = append(problems, fmt.Sprintf("invalid alias format %q", k))
+		}
+	}
+
+	if _, err := o.digestList(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if _, err := i18n.Lookup(*o.locale); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if _, err := o.catalog(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if *o.backupdir != "" && *o.backupinterval <= 0 {
+		problems = append(problems, "backup.interval must be positive")
+	}
+
+	if *o.backupkeep < 0 {
+		problems = append(problems, "backup.keep must not be negative")
+	}
+
+	if *o.webhookinterval <= 0 {
+		problems = append(problems, "webhook.interval must be positive")
+	}
+
+	if _, err := o.milestoneList(); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if *o.metricsenabled && *o.metricsaddr == "" && *o.webuilistenaddr == "" {
+		problems = append(problems, "please pass -metrics.listenaddr or enable the web ui to expose metrics")
+	}
+
+	problems = append(problems, o.validateTenants()...)
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// validateTenants checks the options of all tenants, and that
+// they neither share a database nor a TOTP key.
+func (o *options) validateTenants() []string {
+	var problems []string
+
+	dbs := make(map[string]string)
+	totps := make(map[string]string)
+	if *o.token != "" {
+		dbs[*o.dbpath] = "the default workspace"
+		totps[*o.webuitotp] = "the default workspace"
+	}
+
+	for _, name := range o.tenantNames() {
+		tenant, err := o.tenantOptions(name)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("tenant %s: %v", name, err))
+			continue
+		}
+
+		if other, ok := dbs[*tenant.dbpath]; ok {
+			problems = append(problems, fmt.Sprintf("tenant %s uses the same db as %s", name, other))
+		}
+		dbs[*tenant.dbpath] = "tenant " + name
+
+		if *o.webuilistenaddr == "" {
+			continue
+		}
+		if *tenant.webuitotp == "" {
+			problems = append(problems, fmt.Sprintf("tenant %s: please pass a webui.totp key for the web ui", name))
+		} else if other, ok := totps[*tenant.webuitotp]; ok {
+			problems = append(problems, fmt.Sprintf("tenant %s uses the same webui.totp key as %s", name, other))
+		}
+		totps[*tenant.webuitotp] = "tenant " + name
+	}
+
+	return problems
+}
+
+// tenantNames returns the names of the tenants in order.
+func (o *options) tenantNames() []string {
+	names := make([]string, 0, len(o.tenants))
+	for name := range o.tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// tenantOptions returns the options of a tenant: the global options
+// apart from the workspaceFlags, overridden by the tenant's section of
+// the config file. Unless the tenant sets a db, its karma is kept in
+// a database of its own next to the global one.
+func (o *options) tenantOptions(name string) (*options, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	tenant := defineFlags(fs)
+
+	err := copyFlags(o.fs, fs, func(name string) bool { return !workspaceFlags[name] })
+	if err != nil {
+		return nil, err
+	}
+
+	err = fs.Set("db", filepath.Join(filepath.Dir(*o.dbpath), name+".sqlite3"))
+	if err != nil {
+		return nil, err
+	}
+
+	err = o.tenants[name].Apply(fs, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tenant.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return tenant, nil
+}
+
+// reactjiConfig returns the reactji config, using the
+// default reactjis if none are configured.
+func (o *options) reactjiConfig() *janet.ReactjiConfig {
+	upvote, downvote := o.upvotereactji, o.downvotereactji
+
+	// reactji defaults
+	if len(upvote) == 0 {
+		upvote = make(janet.StringList, 0)
+		upvote.Set("+1")
+		upvote.Set("thumbsup")
+		upvote.Set("thumbsup_all")
+	}
+	if len(downvote) == 0 {
+		downvote = make(janet.StringList, 0)
+		downvote.Set("-1")
+		downvote.Set("thumbsdown")
+	}
+
+	return &janet.ReactjiConfig{
+		Enabled:  *o.reactji,
+		Upvote:   upvote,
+		Downvote: downvote,
+	}
+}
+
+// milestoneList parses the milestones of the achievements, using
+// the default milestones if none are configured. It returns no
+// milestones if achievements are disabled.
+func (o *options) milestoneList() ([]*achievement.Milestone, error) {
+	if !*o.achievements {
+		return nil, nil
+	}
+
+	if len(o.milestones) == 0 {
+		return achievement.Default(), nil
+	}
+
+	milestones := make([]*achievement.Milestone, 0, len(o.milestones))
+	for spec := range o.milestones {
+		milestone, err := achievement.Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		milestones = append(milestones, milestone)
+	}
+	achievement.Sort(milestones)
+
+	return milestones, nil
+}
+
+// aliasMap formats the `main++alias1++alias2` aliases
+// as a map of alias -> main username.
+func (o *options) aliasMap() janet.UserAliases {
+	aliasMap := make(janet.UserAliases, 0)
+	for k := range o.aliases {
+		users := strings.Split(k, "++")
+
+		user := users[0]
+		for _, alias := range users[1:] {
+			aliasMap[alias] = user
+		}
+	}
+
+	return aliasMap
+}
+
+// digestList parses the scheduled digests, sorted by channel.
+func (o *options) digestList() ([]*janet.Digest, error) {
+	specs := make([]string, 0, len(o.digests))
+	for spec := range o.digests {
+		specs = append(specs, spec)
+	}
+	sort.Strings(specs)
+
+	digests := make([]*janet.Digest, 0, len(specs))
+	for _, spec := range specs {
+		digest, err := janet.ParseDigest(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		digests = append(digests, digest)
+	}
+
+	return digests, nil
+}
+
+// badJanetIdentity returns the identity that Good Janet
+// uses for Bad Janet's replies.
+func (o *options) badJanetIdentity() janet.Identity {
+	return janet.Identity{
+		Name:   *o.badjanetname,
+		Icon:   *o.badjaneticon,
+		Prefix: *o.badjanetprefix,
+	}
+}
+
+// catalog loads the message catalog, or returns
+// the default catalog if none is configured.
+func (o *options) catalog() (*persona.Catalog, error) {
+	if *o.messages == "" {
+		return persona.Default(), nil
+	}
+
+	return persona.Load(*o.messages)
+}
+
+// reloadableConfig returns a janet config containing the
+// settings that can be changed while janet is running.
+func (o *options) reloadableConfig() *janet.Config {
+	catalog, _ := o.catalog()          // validated
+	milestones, _ := o.milestoneList() // validated
+
+	return &janet.Config{
+		MaxPoints:        *o.maxpoints,
+		LeaderboardLimit: *o.leaderboardlimit,
+		Motivate:         *o.motivate,
+		SelfPoints:       *o.selfkarma,
+		Blocks:           *o.blocks,
+		UserBlacklist:    o.blacklist,
+		Admins:           o.admins,
+		Aliases:          o.aliasMap(),
+		Reactji:          o.reactjiConfig(),
+		Catalog:          catalog,
+		Achievements:     milestones,
+		Transfers: janet.TransferConfig{
+			Max:   *o.transfermax,
+			Daily: *o.transferdaily,
+		},
+	}
+}
+
+// explicitFlags returns the names of the flags that were passed
+// on the command line or through the environment.
+func explicitFlags(fs *flag.FlagSet) map[string]bool {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	return explicit
+}
+
+// reloadOptions re-reads the config file into a fresh set of
+// options. Flags that were passed on the command line or through
+// the environment keep their values.
+func reloadOptions(current *flag.FlagSet, explicit map[string]bool, path string) (*options, *flag.FlagSet, error) {
+	file, err := config.Load(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fs := flag.NewFlagSet(current.Name(), flag.ContinueOnError)
+	o := defineFlags(fs)
+
+	err = copyFlags(current, fs, func(name string) bool { return explicit[name] })
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = file.Apply(fs, explicit)
+	if err != nil {
+		return nil, nil, err
+	}
+	o.tenants = file.Tenants
+
+	err = o.validate()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return o, fs, nil
+}
+
+// copyFlags sets the flags that were set on one FlagSet on
+// another, if they pass the filter.
+func copyFlags(from, to *flag.FlagSet, filter func(name string) bool) error {
+	var err error
+	from.Visit(func(f *flag.Flag) {
+		if !filter(f.Name) || err != nil {
+			return
+		}
+
+		if list, ok := f.Value.(*janet.StringList); ok {
+			for value := range *list {
+				err = to.Set(f.Name, value)
+			}
+			return
+		}
+
+		err = to.Set(f.Name, f.Value.String())
+	})
+
+	return err
+}
+
+// restartRequired returns the names of the flags that changed
+// between the two FlagSets but cannot be reloaded.
+func restartRequired(current, reloaded *flag.FlagSet) []string {
+	var changed []string
+	current.VisitAll(func(f *flag.Flag) {
+		if reloadableFlags[f.Name] {
+			return
+		}
+
+		if r := reloaded.Lookup(f.Name); r != nil && r.Value.String() != f.Value.String() {
+			changed = append(changed, f.Name)
+		}
+	})
+
+	return changed
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/troyxmccall/janet"
+	"github.com/troyxmccall/janet/database"
+	"github.com/troyxmccall/janet/health"
+	"github.com/troyxmccall/janet/i18n"
+	janetui "github.com/troyxmccall/janet/ui"
+	"github.com/troyxmccall/janet/ui/webui"
+	"github.com/troyxmccall/janet/webhook"
+
+	"github.com/aybabtme/log"
+	"github.com/nlopes/slack"
+)
+
+// A tenant is a Slack workspace that janet serves, with its own
+// Slack connections, database and bot. The default workspace,
+// which is configured by the global options, has an empty name.
+type tenant struct {
+	name string
+	opts *options
+	log  *log.Log
+
+	db                   *database.DB
+	slack, badJanetSlack *slack.RTM
+	bot                  *janet.Bot
+}
+
+// newTenants returns the default workspace, if it has a token,
+// followed by the tenants of the config file.
+func newTenants(opts *options, ll *log.Log) []*tenant {
+	var tenants []*tenant
+	if *opts.token != "" {
+		tenants = append(tenants, &tenant{
+			opts: opts,
+			log:  ll,
+		})
+	}
+
+	for _, name := range opts.tenantNames() {
+		tenantOpts, _ := opts.tenantOptions(name) // validated
+
+		tenants = append(tenants, &tenant{
+			name: name,
+			opts: tenantOpts,
+			log:  ll.KV("tenant", name),
+		})
+	}
+
+	return tenants
+}
+
+// connect opens the tenant's database and connects its janets to Slack.
+func (t *tenant) connect(debug bool) error {
+	db, err := database.New(&database.Config{
+		Path: *t.opts.dbpath,
+	})
+	if err != nil {
+		return fmt.Errorf("could not open sqlite db %s: %v", *t.opts.dbpath, err)
+	}
+	t.db = db
+
+	//TODO: figure out a way to fix this
+	//our current logging library does not implement
+	//log.Logger
+	//slack.SetLogger(*ll)
+
+	t.slack = slack.New(*t.opts.token, slack.OptionDebug(debug)).NewRTM()
+	go t.slack.ManageConnection()
+
+	if *t.opts.badJanetToken != "" {
+		t.badJanetSlack = slack.New(*t.opts.badJanetToken, slack.OptionDebug(debug)).NewRTM()
+		go t.badJanetSlack.ManageConnection()
+		t.log.Info("starting both janets")
+	} else {
+		t.log.Info("starting good janet, who replies for bad janet")
+	}
+
+	return nil
+}
+
+// locale returns the language of the tenant's workspace.
+func (t *tenant) locale() *i18n.Locale {
+	locale, _ := i18n.Lookup(*t.opts.locale) // validated
+
+	return locale
+}
+
+// webui returns the web UI's view of the tenant.
+func (t *tenant) webui() *webui.Tenant {
+	return &webui.Tenant{
+		TOTP:             *t.opts.webuitotp,
+		DB:               t.db,
+		LeaderboardLimit: *t.opts.leaderboardlimit,
+		Locale:           t.locale(),
+	}
+}
+
+// startBot creates the tenant's bot and adds its health checks.
+func (t *tenant) startBot(ui janetui.Provider, checker *health.Checker, debug bool) {
+	botConfig := t.opts.reloadableConfig()
+	botConfig.Slack = &janet.SlackChatService{RTM: t.slack}
+	if t.badJanetSlack != nil {
+		botConfig.BadJanetSlack = &janet.SlackChatService{RTM: t.badJanetSlack}
+	}
+	botConfig.BadJanetIdentity = t.opts.badJanetIdentity()
+	botConfig.UI = ui
+	botConfig.Debug = debug
+	botConfig.Log = t.log
+	botConfig.DB = t.db
+	botConfig.Digests, _ = t.opts.digestList() // validated
+	botConfig.Locale = t.locale()
+
+	t.bot = janet.New(botConfig)
+
+	checker.Add(t.check("database"), t.db.Ping)
+	checker.Add(t.check("good_janet"), t.bot.GoodJanetHealth)
+	if t.badJanetSlack != nil {
+		checker.Add(t.check("bad_janet"), t.bot.BadJanetHealth)
+	}
+}
+
+// check returns the name of one of the tenant's health checks.
+func (t *tenant) check(name string) string {
+	if t.name == "" {
+		return name
+	}
+
+	return t.name + "." + name
+}
+
+// backupDir returns the directory of the tenant's backups, which
+// is a subdirectory of the backup directory for tenants.
+func (t *tenant) backupDir(dir string) string {
+	if t.name == "" {
+		return dir
+	}
+
+	return filepath.Join(dir, t.name)
+}
+
+// runBackups backs up the tenant's database until the context is cancelled.
+func (t *tenant) runBackups(ctx context.Context, dir string, interval time.Duration, keep int) {
+	runBackups(ctx, t.db, t.backupDir(dir), interval, keep, t.log.KV("provider", "backup"))
+}
+
+// runWebhooks delivers the tenant's webhook events until the context is cancelled.
+func (t *tenant) runWebhooks(ctx context.Context, interval time.Duration) {
+	sender := webhook.New(t.db, t.log.KV("provider", "webhook"))
+	sender.Interval = interval
+	sender.Run(ctx)
+}
+
+// reload applies the reloaded global options to the tenant's bot
+// and keeps them, so that later reloads are compared against them.
+func (t *tenant) reload(reloaded *options) {
+	opts := reloaded
+	if t.name != "" {
+		if _, ok := reloaded.tenants[t.name]; !ok {
+			t.log.Info("tenant was removed from the config file but requires a restart to stop")
+			return
+		}
+
+		var err error
+		opts, err = reloaded.tenantOptions(t.name)
+		if err != nil {
+			t.log.Err(err).Error("could not reload tenant, keeping its current config")
+			return
+		}
+
+		for _, name := range restartRequired(t.opts.fs, opts.fs) {
+			t.log.KV("option", name).Info("option changed but requires a restart to take effect")
+		}
+	}
+
+	t.bot.Reload(opts.reloadableConfig())
+	t.opts = opts
+}
+
+// disconnect disconnects the tenant's janets from Slack.
+func (t *tenant) disconnect() {
+	if err := t.slack.Disconnect(); err != nil {
+		t.log.Err(err).Error("could not disconnect from slack")
+	}
+
+	if t.badJanetSlack != nil {
+		if err := t.badJanetSlack.Disconnect(); err != nil {
+			t.log.Err(err).Error("could not disconnect bad janet from slack")
+		}
+	}
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"fmt"
 	"os"
+	"time"
 
 	"github.com/troyxmccall/janet"
 	"github.com/troyxmccall/janet/ctlcommands"
+	"github.com/troyxmccall/janet/importer"
 
 	"github.com/aybabtme/log"
 	"github.com/urfave/cli"
@@ -44,6 +47,22 @@ func main() {
 		Usage: "set debug mode",
 	}
 
+	output := cli.StringFlag{
+		Name:  "output, o",
+		Value: ctlcommands.OutputTable,
+		Usage: "output format: table, json or csv",
+	}
+
+	dryrun := cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "only print the records that would be inserted",
+	}
+
+	yes := cli.BoolFlag{
+		Name:  "yes, y",
+		Usage: "do not ask for confirmation",
+	}
+
 	leaderboardlimit := cli.IntFlag{
 		Name:  "leaderboardlimit",
 		Value: 10,
@@ -57,6 +76,7 @@ func main() {
 			Name:  "totp",
 			Usage: "generate a TOTP token",
 			Flags: []cli.Flag{
+				output,
 				cli.StringFlag{
 					Name:  "totp",
 					Usage: "totp key",
@@ -77,7 +97,7 @@ func main() {
 				},
 				cli.StringFlag{
 					Name:  "path",
-					Usage: "path to web UI files",
+					Usage: "path to a directory overriding the embedded web UI files",
 				},
 				cli.StringFlag{
 					Name:  "listenaddr",
@@ -87,6 +107,11 @@ func main() {
 					Name:  "url",
 					Usage: "url address for accessing the web ui",
 				},
+				cli.DurationFlag{
+					Name:  "shutdowntimeout",
+					Value: 10 * time.Second,
+					Usage: "how long to wait for in-flight requests when shutting down",
+				},
 			},
 			Action: cc.Serve,
 		},
@@ -100,6 +125,7 @@ func main() {
 			Usage: "add karma to a user",
 			Flags: []cli.Flag{
 				dbpath,
+				output,
 				cli.StringFlag{
 					Name: "from",
 				},
@@ -120,6 +146,7 @@ func main() {
 			Usage: "move a user's karma to another user",
 			Flags: []cli.Flag{
 				dbpath,
+				output,
 				cli.StringFlag{
 					Name: "from",
 				},
@@ -127,8 +154,11 @@ func main() {
 					Name: "to",
 				},
 				cli.StringFlag{
-					Name: "reason",
+					Name:  "reason",
+					Usage: "defaults to naming both users",
 				},
+				dryrun,
+				yes,
 			},
 			Action: cc.MigratePoints,
 		},
@@ -137,9 +167,16 @@ func main() {
 			Usage: "reset a user's karma",
 			Flags: []cli.Flag{
 				dbpath,
+				output,
 				cli.StringFlag{
 					Name: "user",
 				},
+				cli.StringFlag{
+					Name:  "reason",
+					Value: "janetctl resetting karma",
+				},
+				dryrun,
+				yes,
 			},
 			Action: cc.ResetPoints,
 		},
@@ -148,20 +185,119 @@ func main() {
 			Usage: "set a user's karma to a specific number",
 			Flags: []cli.Flag{
 				dbpath,
+				output,
 				cli.StringFlag{
 					Name: "user",
 				},
 				cli.IntFlag{
 					Name: "points",
 				},
+				cli.StringFlag{
+					Name:  "reason",
+					Value: "janetctl overriding karma",
+				},
+				dryrun,
+				yes,
 			},
 			Action: cc.SetPoints,
 		},
+		{
+			Name:  "rollback",
+			Usage: "undo the last migrate, reset, set or revoke",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+				dryrun,
+				yes,
+			},
+			Action: cc.Rollback,
+		},
+		{
+			Name:  "export",
+			Usage: "export all karma records with their timestamps",
+			Flags: []cli.Flag{
+				dbpath,
+				cli.StringFlag{
+					Name:  "format",
+					Usage: "csv or jsonl. defaults to the file's extension, or csv",
+				},
+				cli.StringFlag{
+					Name:  "file",
+					Usage: "file to write to. defaults to stdout",
+				},
+			},
+			Action: cc.ExportPoints,
+		},
+		{
+			Name:  "import",
+			Usage: "import karma records, skipping records that already exist",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+				cli.StringFlag{
+					Name:  "format",
+					Usage: "csv or jsonl. defaults to the file's extension, or csv",
+				},
+				cli.StringFlag{
+					Name:  "file",
+					Usage: "file to read from. defaults to stdin",
+				},
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "only print what would change",
+				},
+			},
+			Action: cc.ImportPoints,
+		},
+		{
+			Name:  "get",
+			Usage: "get a user's karma",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+				cli.StringFlag{
+					Name: "user",
+				},
+			},
+			Action: cc.GetPoints,
+		},
+		{
+			Name:  "top",
+			Usage: "list the users with the most karma",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+				cli.IntFlag{
+					Name:  "limit",
+					Value: 10,
+					Usage: "the amount of users to list",
+				},
+			},
+			Action: cc.GetLeaderboard,
+		},
+		{
+			Name:  "history",
+			Usage: "list the karma that a user gave and received, newest first",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+				cli.StringFlag{
+					Name: "user",
+				},
+				cli.IntFlag{
+					Name:  "limit",
+					Value: 20,
+					Usage: "the amount of records to list. 0 lists all records",
+				},
+			},
+			Action: cc.GetHistory,
+		},
 		{
 			Name:  "throwback",
 			Usage: "get a karma throwback for a user",
 			Flags: []cli.Flag{
 				dbpath,
+				output,
 				cli.StringFlag{
 					Name: "user",
 				},
@@ -170,6 +306,276 @@ func main() {
 		},
 	}
 
+	// importers
+
+	var importCommands []cli.Command
+	for _, name := range importer.Names() {
+		importCommands = append(importCommands, cli.Command{
+			Name:      name,
+			Usage:     fmt.Sprintf("import karma from %s", name),
+			ArgsUsage: "<file>",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+				cli.StringFlag{
+					Name:  "from",
+					Usage: "the giver of all imported karma. defaults to the importer's name",
+				},
+				cli.StringFlag{
+					Name:  "timestamp",
+					Usage: "RFC 3339 timestamp for karma without one. defaults to 1970-01-01T00:00:00Z",
+				},
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "only print what would change",
+				},
+			},
+			Action: cc.Import,
+		})
+	}
+
+	// database
+
+	dbCommands := []cli.Command{
+		{
+			Name:  "backup",
+			Usage: "back up the database while janet is running",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+				cli.StringFlag{
+					Name:  "file",
+					Usage: "path to write the backup to",
+				},
+				cli.StringFlag{
+					Name:  "dir",
+					Usage: "directory to write a timestamped backup to",
+				},
+				cli.IntFlag{
+					Name:  "keep",
+					Usage: "the amount of backups to keep in `dir`. 0 keeps all backups",
+				},
+			},
+			Action: cc.BackupDB,
+		},
+		{
+			Name:  "restore",
+			Usage: "restore the database from a backup. janet must not be running",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+				cli.StringFlag{
+					Name:  "file",
+					Usage: "path to the backup to restore",
+				},
+			},
+			Action: cc.RestoreDB,
+		},
+	}
+
+	// aliases
+
+	aliasCommands := []cli.Command{
+		{
+			Name:  "add",
+			Usage: "alias a username to a user",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+				cli.StringFlag{
+					Name: "alias",
+				},
+				cli.StringFlag{
+					Name: "user",
+				},
+			},
+			Action: cc.AddAlias,
+		},
+		{
+			Name:  "remove",
+			Usage: "remove an alias",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+				cli.StringFlag{
+					Name: "alias",
+				},
+			},
+			Action: cc.RemoveAlias,
+		},
+		{
+			Name:  "list",
+			Usage: "list all aliases",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+			},
+			Action: cc.ListAliases,
+		},
+	}
+
+	// blacklist
+
+	blacklistCommands := []cli.Command{
+		{
+			Name:  "add",
+			Usage: "blacklist a user from having karma operations applied on them",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+				cli.StringFlag{
+					Name: "user",
+				},
+			},
+			Action: cc.AddBlacklist,
+		},
+		{
+			Name:  "remove",
+			Usage: "remove a user from the blacklist",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+				cli.StringFlag{
+					Name: "user",
+				},
+			},
+			Action: cc.RemoveBlacklist,
+		},
+		{
+			Name:  "list",
+			Usage: "list all blacklisted users",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+			},
+			Action: cc.ListBlacklist,
+		},
+	}
+
+	// webhooks
+
+	webhookCommands := []cli.Command{
+		{
+			Name:  "add",
+			Usage: "post karma events to a url",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+				cli.StringFlag{
+					Name: "url",
+				},
+				cli.StringFlag{
+					Name:  "secret",
+					Usage: "the key that deliveries are signed with. generated if empty",
+				},
+				cli.StringSliceFlag{
+					Name:  "event",
+					Usage: "an event to subscribe to: karma.insert, karma.revoke or karma.milestone. defaults to all events",
+				},
+			},
+			Action: cc.AddWebhook,
+		},
+		{
+			Name:  "remove",
+			Usage: "remove a webhook",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+				cli.StringFlag{
+					Name: "url",
+				},
+			},
+			Action: cc.RemoveWebhook,
+		},
+		{
+			Name:  "list",
+			Usage: "list all webhooks",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+			},
+			Action: cc.ListWebhooks,
+		},
+		{
+			Name:  "log",
+			Usage: "list the latest deliveries of karma events",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+				cli.IntFlag{
+					Name:  "limit",
+					Value: 20,
+					Usage: "the amount of deliveries to list. 0 lists all deliveries",
+				},
+			},
+			Action: cc.ListDeliveries,
+		},
+	}
+
+	// rewards
+
+	rewardCommands := []cli.Command{
+		{
+			Name:  "add",
+			Usage: "add a reward to the catalog, or change an existing one",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+				cli.StringFlag{
+					Name:  "name",
+					Usage: "the single word that users redeem the reward with",
+				},
+				cli.IntFlag{
+					Name:  "cost",
+					Usage: "the amount of points that the reward costs",
+				},
+				cli.StringFlag{
+					Name: "description",
+				},
+			},
+			Action: cc.AddReward,
+		},
+		{
+			Name:  "remove",
+			Usage: "remove a reward from the catalog",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+				cli.StringFlag{
+					Name: "name",
+				},
+			},
+			Action: cc.RemoveReward,
+		},
+		{
+			Name:  "list",
+			Usage: "list the catalog",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+			},
+			Action: cc.ListRewards,
+		},
+		{
+			Name:  "history",
+			Usage: "list the latest redemptions",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+				cli.StringFlag{
+					Name:  "user",
+					Usage: "only list the redemptions of this user",
+				},
+				cli.IntFlag{
+					Name:  "limit",
+					Value: 20,
+					Usage: "the amount of redemptions to list. 0 lists all redemptions",
+				},
+			},
+			Action: cc.ListRedemptions,
+		},
+	}
+
 	// main app
 
 	app.Commands = []cli.Command{
@@ -181,7 +587,99 @@ func main() {
 			Name:        "webui",
 			Subcommands: webuiCommands,
 		},
+		{
+			Name:        "db",
+			Usage:       "back up and restore the database",
+			Subcommands: dbCommands,
+		},
+		{
+			Name:        "import",
+			Usage:       "import karma from other bots",
+			Subcommands: importCommands,
+		},
+		{
+			Name:      "replay",
+			Usage:     "backfill karma from an extracted slack export",
+			ArgsUsage: "<slack-export-dir>",
+			Flags: []cli.Flag{
+				dbpath,
+				output,
+				cli.StringFlag{
+					Name:  "since",
+					Usage: "only replay messages sent at or after this date or RFC 3339 timestamp",
+				},
+				cli.StringFlag{
+					Name:  "until",
+					Usage: "only replay messages sent before this date or RFC 3339 timestamp",
+				},
+				cli.IntFlag{
+					Name:  "maxpoints",
+					Value: 6,
+					Usage: "the maximum amount of points that users can give/take at once",
+				},
+				cli.BoolTFlag{
+					Name:  "motivate",
+					Usage: "toggle motivate.im support",
+				},
+				cli.BoolFlag{
+					Name:  "selfkarma",
+					Usage: "allow users to add/remove karma to themselves",
+				},
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "only print what would change",
+				},
+			},
+			Action: cc.Replay,
+		},
+		{
+			Name:  "tui",
+			Usage: "manage karma in an interactive terminal dashboard",
+			Flags: []cli.Flag{
+				dbpath,
+				leaderboardlimit,
+				cli.IntFlag{
+					Name:  "historylimit",
+					Value: 100,
+					Usage: "the amount of karma operations to list in the history",
+				},
+				cli.DurationFlag{
+					Name:  "refresh",
+					Value: 2 * time.Second,
+					Usage: "how often to reload the leaderboard and history",
+				},
+			},
+			Action: cc.Tui,
+		},
+		{
+			Name:        "alias",
+			Subcommands: aliasCommands,
+		},
+		{
+			Name:        "blacklist",
+			Subcommands: blacklistCommands,
+		},
+		{
+			Name:        "webhook",
+			Usage:       "manage the webhooks that karma events are posted to",
+			Subcommands: webhookCommands,
+		},
+		{
+			Name:        "reward",
+			Usage:       "manage the rewards that users can spend their karma on",
+			Subcommands: rewardCommands,
+		},
 	}
 
-	app.Run(os.Args)
+	// action errors are cli.ExitCoders, which exit with their
+	// own code. anything else is an invalid invocation.
+	app.OnUsageError = func(c *cli.Context, err error, isSubcommand bool) error {
+		return cli.NewExitError(err.Error(), ctlcommands.ExitUsage)
+	}
+
+	err := app.Run(os.Args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(ctlcommands.ExitUsage)
+	}
 }
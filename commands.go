@@ -0,0 +1,147 @@
+package janet
+
+import (
+	"regexp"
+
+	"github.com/nlopes/slack"
+	"github.com/troyxmccall/janet/metrics"
+)
+
+// A command is a chat command that one of the janets responds to.
+type command struct {
+	// name labels the command in the metrics
+	name   string
+	regexp *regexp.Regexp
+
+	// janet is the janet that owns the command. Bad Janet's
+	// commands are handled by Good Janet if there is no Bad Janet.
+	janet string
+
+	// mention is set if the first submatch of the regexp is an
+	// optional mention of a janet, in which case the command is
+	// ignored unless it mentions the janet that received it
+	mention bool
+
+	// handle runs the command, replying as whichJanet. Good Janet
+	// replies for Bad Janet if there is no Bad Janet.
+	handle func(b *Bot, ev *slack.MessageEvent, whichJanet string)
+}
+
+var (
+	// commands are matched before looking for karma operations,
+	// as they may contain slack users.
+	commands = []*command{
+		{
+			name:   "alias",
+			regexp: regexps.Alias,
+			janet:  "goodJanet",
+			handle: func(b *Bot, ev *slack.MessageEvent, _ string) { b.manageAliases(ev) },
+		},
+		{
+			name:   "blacklist",
+			regexp: regexps.Blacklist,
+			janet:  "goodJanet",
+			handle: func(b *Bot, ev *slack.MessageEvent, _ string) { b.manageBlacklist(ev) },
+		},
+		{
+			name:   "give",
+			regexp: regexps.Give,
+			janet:  "goodJanet",
+			handle: func(b *Bot, ev *slack.MessageEvent, _ string) { b.give(ev) },
+		},
+		{
+			name:    "roast",
+			regexp:  regexps.Roast,
+			janet:   "badJanet",
+			mention: true,
+			handle:  (*Bot).roast,
+		},
+		{
+			name:    "bottom",
+			regexp:  regexps.Bottom,
+			janet:   "badJanet",
+			mention: true,
+			handle:  (*Bot).printBottom,
+		},
+	}
+
+	// standaloneCommands are only matched if a message
+	// does not contain any karma operations.
+	standaloneCommands = []*command{
+		{
+			name:   "url",
+			regexp: regexps.URL,
+			janet:  "goodJanet",
+			handle: func(b *Bot, ev *slack.MessageEvent, _ string) { b.printURL(ev) },
+		},
+		{
+			name:   "leaderboard",
+			regexp: regexps.Leaderboard,
+			janet:  "goodJanet",
+			handle: func(b *Bot, ev *slack.MessageEvent, _ string) { b.printLeaderboard(ev) },
+		},
+		{
+			name:   "rewards",
+			regexp: regexps.Rewards,
+			janet:  "goodJanet",
+			handle: func(b *Bot, ev *slack.MessageEvent, _ string) { b.printRewards(ev) },
+		},
+		{
+			name:   "redeem",
+			regexp: regexps.Redeem,
+			janet:  "goodJanet",
+			handle: func(b *Bot, ev *slack.MessageEvent, _ string) { b.redeem(ev) },
+		},
+	}
+)
+
+// dispatch runs the first command that matches a message, if it is
+// one that the receiving janet responds to. It returns whether any of
+// the commands matched, so that the message is not handled twice.
+func (b *Bot) dispatch(ev *slack.MessageEvent, receiver string, commands []*command) bool {
+	for _, cmd := range commands {
+		match := cmd.regexp.FindStringSubmatch(ev.Text)
+		if match == nil {
+			continue
+		}
+
+		if b.responder(cmd.janet) != receiver {
+			return true
+		}
+
+		if cmd.mention && match[1] != "" && match[1] != b.connection(receiver).user() {
+			return true
+		}
+
+		metrics.Commands.WithLabelValues(cmd.name).Inc()
+
+		whichJanet := ""
+		if cmd.janet == "badJanet" {
+			whichJanet = "badJanet"
+		}
+		cmd.handle(b, ev, whichJanet)
+
+		return true
+	}
+
+	return false
+}
+
+// responder returns the janet that responds to the commands
+// of a janet, which is Good Janet if Bad Janet is not configured.
+func (b *Bot) responder(janet string) string {
+	if janet == "badJanet" && b.Config.BadJanetSlack == nil {
+		return "goodJanet"
+	}
+
+	return janet
+}
+
+// connection returns the connection state of a janet.
+func (b *Bot) connection(janet string) *connectionState {
+	if janet == "badJanet" {
+		return &b.badJanetConnection
+	}
+
+	return &b.goodJanetConnection
+}
@@ -0,0 +1,404 @@
+// Package config loads janet's configuration from a YAML or
+// TOML file. The file is a structured view of janet's cli flags:
+// every option maps to the flag of the same name and is applied
+// through it, so the flags' parsing and defaults are shared.
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// A File contains the options read from a config file.
+// Options that are missing from the file are nil.
+type File struct {
+	Token            *string             `yaml:"token" toml:"token"`
+	BadJanetToken    *string             `yaml:"badJanetToken" toml:"badJanetToken"`
+	BadJanet         *BadJanet           `yaml:"badjanet" toml:"badjanet"`
+	DB               *string             `yaml:"db" toml:"db"`
+	MaxPoints        *int                `yaml:"maxpoints" toml:"maxpoints"`
+	LeaderboardLimit *int                `yaml:"leaderboardlimit" toml:"leaderboardlimit"`
+	Debug            *bool               `yaml:"debug" toml:"debug"`
+	Motivate         *bool               `yaml:"motivate" toml:"motivate"`
+	SelfKarma        *bool               `yaml:"selfkarma" toml:"selfkarma"`
+	Blocks           *bool               `yaml:"blocks" toml:"blocks"`
+	Messages         *string             `yaml:"messages" toml:"messages"`
+	Locale           *string             `yaml:"locale" toml:"locale"`
+	ShutdownTimeout  *string             `yaml:"shutdowntimeout" toml:"shutdowntimeout"`
+	Blacklist        []string            `yaml:"blacklist" toml:"blacklist"`
+	Admins           []string            `yaml:"admins" toml:"admins"`
+	Aliases          map[string][]string `yaml:"aliases" toml:"aliases"`
+	Reactji          *Reactji            `yaml:"reactji" toml:"reactji"`
+	Achievements     *Achievements       `yaml:"achievements" toml:"achievements"`
+	Transfer         *Transfer           `yaml:"transfer" toml:"transfer"`
+	WebUI            *WebUI              `yaml:"webui" toml:"webui"`
+	Metrics          *Metrics            `yaml:"metrics" toml:"metrics"`
+	Health           *Health             `yaml:"health" toml:"health"`
+	Backup           *Backup             `yaml:"backup" toml:"backup"`
+	Webhook          *Webhook            `yaml:"webhook" toml:"webhook"`
+	Digests          []*Digest           `yaml:"digests" toml:"digests"`
+
+	// Tenants are the other workspaces that janet serves, keyed by
+	// name. Their options override the ones above, apart from the
+	// globalOptions, which apply to the whole process.
+	Tenants map[string]*File `yaml:"tenants" toml:"tenants"`
+}
+
+// globalOptions are the options that tenants cannot set.
+var globalOptions = map[string]bool{
+	"debug":              true,
+	"shutdowntimeout":    true,
+	"webui.listenaddr":   true,
+	"webui.url":          true,
+	"webui.path":         true,
+	"metrics":            true,
+	"metrics.listenaddr": true,
+	"health.listenaddr":  true,
+	"backup.dir":         true,
+	"backup.interval":    true,
+	"backup.keep":        true,
+	"webhook.interval":   true,
+}
+
+// tenantName matches the valid names of tenants, which are used
+// in file names and URLs.
+var tenantName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Reactji contains the options for reactji-based votes.
+type Reactji struct {
+	Enabled  *bool    `yaml:"enabled" toml:"enabled"`
+	Upvote   []string `yaml:"upvote" toml:"upvote"`
+	Downvote []string `yaml:"downvote" toml:"downvote"`
+}
+
+// Achievements contains the options for badges. Milestones
+// use the `first`, `total:<points>`, `streak:<days>` and
+// `generous` format.
+type Achievements struct {
+	Enabled    *bool    `yaml:"enabled" toml:"enabled"`
+	Milestones []string `yaml:"milestones" toml:"milestones"`
+}
+
+// Transfer contains the limits of the points that
+// users can give each other from their balance.
+type Transfer struct {
+	Max   *int `yaml:"max" toml:"max"`
+	Daily *int `yaml:"daily" toml:"daily"`
+}
+
+// BadJanet contains the options for Bad Janet's replies
+// when there is no Bad Janet token.
+type BadJanet struct {
+	Name   *string `yaml:"name" toml:"name"`
+	Icon   *string `yaml:"icon" toml:"icon"`
+	Prefix *string `yaml:"prefix" toml:"prefix"`
+}
+
+// WebUI contains the web UI options.
+type WebUI struct {
+	ListenAddr *string `yaml:"listenaddr" toml:"listenaddr"`
+	URL        *string `yaml:"url" toml:"url"`
+	TOTP       *string `yaml:"totp" toml:"totp"`
+	Path       *string `yaml:"path" toml:"path"`
+}
+
+// Metrics contains the Prometheus metrics options.
+type Metrics struct {
+	Enabled    *bool   `yaml:"enabled" toml:"enabled"`
+	ListenAddr *string `yaml:"listenaddr" toml:"listenaddr"`
+}
+
+// Health contains the health check options.
+type Health struct {
+	ListenAddr *string `yaml:"listenaddr" toml:"listenaddr"`
+}
+
+// Backup contains the scheduled backup options.
+type Backup struct {
+	Dir      *string `yaml:"dir" toml:"dir"`
+	Interval *string `yaml:"interval" toml:"interval"`
+	Keep     *int    `yaml:"keep" toml:"keep"`
+}
+
+// Webhook contains the outbound webhook options. The
+// webhooks themselves are managed with janetctl.
+type Webhook struct {
+	Interval *string `yaml:"interval" toml:"interval"`
+}
+
+// A Digest schedules a karma digest for a channel.
+type Digest struct {
+	Channel  string `yaml:"channel" toml:"channel"`
+	Window   string `yaml:"window" toml:"window"`
+	Schedule string `yaml:"schedule" toml:"schedule"`
+}
+
+// Load reads and validates a config file. The format is
+// determined by the file extension.
+func Load(path string) (*File, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &File{}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, file)
+	case ".toml":
+		var meta toml.MetaData
+		meta, err = toml.DecodeReader(bytes.NewReader(data), file)
+		if err == nil && len(meta.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown options %v", meta.Undecoded())
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported config format, please use .yaml, .yml or .toml", path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	err = file.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return file, nil
+}
+
+// Validate checks the options that can be checked without
+// knowing the values of the other flags.
+func (f *File) Validate() error {
+	problems := f.problems()
+
+	for name, tenant := range f.Tenants {
+		problems = append(problems, validateTenant(name, tenant)...)
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// problems returns the problems with the file's own options.
+func (f *File) problems() []string {
+	var problems []string
+
+	if f.MaxPoints != nil && *f.MaxPoints < 1 {
+		problems = append(problems, "maxpoints must be at least 1")
+	}
+
+	if f.LeaderboardLimit != nil && *f.LeaderboardLimit < 1 {
+		problems = append(problems, "leaderboardlimit must be at least 1")
+	}
+
+	if f.Transfer != nil && ((f.Transfer.Max != nil && *f.Transfer.Max < 0) || (f.Transfer.Daily != nil && *f.Transfer.Daily < 0)) {
+		problems = append(problems, "transfer.max and transfer.daily must not be negative")
+	}
+
+	if f.Backup != nil && f.Backup.Keep != nil && *f.Backup.Keep < 0 {
+		problems = append(problems, "backup.keep must not be negative")
+	}
+
+	for i, digest := range f.Digests {
+		if digest.Channel == "" || digest.Window == "" || digest.Schedule == "" {
+			problems = append(problems, fmt.Sprintf("digest %d must have a channel, a window and a schedule", i+1))
+		}
+	}
+
+	for user, aliases := range f.Aliases {
+		if user == "" || len(aliases) == 0 {
+			problems = append(problems, fmt.Sprintf("alias %q must have at least one alias", user))
+		}
+
+		for _, alias := range aliases {
+			if alias == "" || strings.Contains(alias, "++") {
+				problems = append(problems, fmt.Sprintf("alias %q of %q is invalid", alias, user))
+			}
+		}
+	}
+
+	return problems
+}
+
+func validateTenant(name string, tenant *File) []string {
+	if !tenantName.MatchString(name) {
+		return []string{fmt.Sprintf("invalid tenant name %q, please use lowercase letters, digits, - and _", name)}
+	}
+
+	if tenant == nil {
+		return []string{fmt.Sprintf("tenant %s has no options", name)}
+	}
+
+	if len(tenant.Tenants) > 0 {
+		return []string{fmt.Sprintf("tenant %s cannot have tenants", name)}
+	}
+
+	var problems []string
+	for option := range tenant.values() {
+		if globalOptions[option] {
+			problems = append(problems, fmt.Sprintf("tenant %s: %s cannot be set per tenant", name, option))
+		}
+	}
+
+	for _, problem := range tenant.problems() {
+		problems = append(problems, fmt.Sprintf("tenant %s: %s", name, problem))
+	}
+
+	return problems
+}
+
+// A FlagSetter sets flag values by name, e.g. *flag.FlagSet.
+type FlagSetter interface {
+	Set(name, value string) error
+}
+
+// Apply sets the flags for all options present in the file,
+// except for the flags listed in skip. This is used to give
+// flags passed on the command line or through the environment
+// precedence over the file.
+func (f *File) Apply(flags FlagSetter, skip map[string]bool) error {
+	values := f.values()
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if skip[name] {
+			continue
+		}
+
+		for _, value := range values[name] {
+			err := flags.Set(name, value)
+			if err != nil {
+				return fmt.Errorf("invalid value %q for %s: %v", value, name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// values returns the flag values for all options present
+// in the file, keyed by flag name.
+func (f *File) values() map[string][]string {
+	values := make(map[string][]string)
+
+	setString := func(name string, value *string) {
+		if value != nil {
+			values[name] = []string{*value}
+		}
+	}
+	setInt := func(name string, value *int) {
+		if value != nil {
+			values[name] = []string{strconv.Itoa(*value)}
+		}
+	}
+	setBool := func(name string, value *bool) {
+		if value != nil {
+			values[name] = []string{strconv.FormatBool(*value)}
+		}
+	}
+	setList := func(name string, value []string) {
+		if len(value) > 0 {
+			values[name] = value
+		}
+	}
+
+	setString("token", f.Token)
+	setString("badJanetToken", f.BadJanetToken)
+	setString("db", f.DB)
+	setInt("maxpoints", f.MaxPoints)
+	setInt("leaderboardlimit", f.LeaderboardLimit)
+	setBool("debug", f.Debug)
+	setBool("motivate", f.Motivate)
+	setBool("selfkarma", f.SelfKarma)
+	setBool("blocks", f.Blocks)
+	setString("messages", f.Messages)
+	setString("locale", f.Locale)
+	setString("shutdowntimeout", f.ShutdownTimeout)
+	setList("blacklist", f.Blacklist)
+	setList("admin", f.Admins)
+
+	// aliases use the `main++alias1++alias2` flag format
+	var aliases []string
+	for user, userAliases := range f.Aliases {
+		aliases = append(aliases, strings.Join(append([]string{user}, userAliases...), "++"))
+	}
+	sort.Strings(aliases)
+	setList("alias", aliases)
+
+	if f.Reactji != nil {
+		setBool("reactji", f.Reactji.Enabled)
+		setList("reactji.upvote", f.Reactji.Upvote)
+		setList("reactji.downvote", f.Reactji.Downvote)
+	}
+
+	if f.Achievements != nil {
+		setBool("achievements", f.Achievements.Enabled)
+		setList("achievements.milestone", f.Achievements.Milestones)
+	}
+
+	if f.Transfer != nil {
+		setInt("transfer.max", f.Transfer.Max)
+		setInt("transfer.daily", f.Transfer.Daily)
+	}
+
+	if f.BadJanet != nil {
+		setString("badjanet.name", f.BadJanet.Name)
+		setString("badjanet.icon", f.BadJanet.Icon)
+		setString("badjanet.prefix", f.BadJanet.Prefix)
+	}
+
+	if f.WebUI != nil {
+		setString("webui.listenaddr", f.WebUI.ListenAddr)
+		setString("webui.url", f.WebUI.URL)
+		setString("webui.totp", f.WebUI.TOTP)
+		setString("webui.path", f.WebUI.Path)
+	}
+
+	if f.Metrics != nil {
+		setBool("metrics", f.Metrics.Enabled)
+		setString("metrics.listenaddr", f.Metrics.ListenAddr)
+	}
+
+	if f.Health != nil {
+		setString("health.listenaddr", f.Health.ListenAddr)
+	}
+
+	if f.Backup != nil {
+		setString("backup.dir", f.Backup.Dir)
+		setString("backup.interval", f.Backup.Interval)
+		setInt("backup.keep", f.Backup.Keep)
+	}
+
+	if f.Webhook != nil {
+		setString("webhook.interval", f.Webhook.Interval)
+	}
+
+	// digests use the `<channel> <window> <schedule>` flag format
+	var digests []string
+	for _, digest := range f.Digests {
+		digests = append(digests, fmt.Sprintf("%s %s %s", digest.Channel, digest.Window, digest.Schedule))
+	}
+	setList("digest", digests)
+
+	return values
+}
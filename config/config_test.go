@@ -0,0 +1,337 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const yamlConfig = `
+token: xoxb-good
+db: /var/lib/janet/db.sqlite3
+maxpoints: 3
+motivate: false
+blacklist: [janet, slackbot]
+aliases:
+  troy: [tm, troyxmccall]
+reactji:
+  upvote: [tada]
+transfer:
+  daily: 20
+webui:
+  totp: TOTPTOTPTOTPTOTP
+digests:
+  - channel: C0123ABCD
+    window: week
+    schedule: "0 9 * * mon"
+tenants:
+  acme:
+    token: xoxb-acme
+    maxpoints: 5
+`
+
+const tomlConfig = `
+token = "xoxb-good"
+db = "/var/lib/janet/db.sqlite3"
+maxpoints = 3
+motivate = false
+blacklist = ["janet", "slackbot"]
+
+[aliases]
+troy = ["tm", "troyxmccall"]
+
+[reactji]
+upvote = ["tada"]
+
+[transfer]
+daily = 20
+
+[webui]
+totp = "TOTPTOTPTOTPTOTP"
+
+[[digests]]
+channel = "C0123ABCD"
+window = "week"
+schedule = "0 9 * * mon"
+
+[tenants.acme]
+token = "xoxb-acme"
+maxpoints = 5
+`
+
+// writeConfig writes a config file with the given name
+// to a temporary directory.
+func writeConfig(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	want := map[string][]string{
+		"token":          {"xoxb-good"},
+		"db":             {"/var/lib/janet/db.sqlite3"},
+		"maxpoints":      {"3"},
+		"motivate":       {"false"},
+		"blacklist":      {"janet", "slackbot"},
+		"alias":          {"troy++tm++troyxmccall"},
+		"reactji.upvote": {"tada"},
+		"transfer.daily": {"20"},
+		"webui.totp":     {"TOTPTOTPTOTPTOTP"},
+		"digest":         {"C0123ABCD week 0 9 * * mon"},
+	}
+
+	for name, content := range map[string]string{
+		"janet.yaml": yamlConfig,
+		"janet.yml":  yamlConfig,
+		"janet.toml": tomlConfig,
+	} {
+		file, err := Load(writeConfig(t, name, content))
+		if err != nil {
+			t.Errorf("%s: Load: %v", name, err)
+			continue
+		}
+
+		if got := file.values(); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: read %v; want %v", name, got, want)
+		}
+
+		acme, ok := file.Tenants["acme"]
+		if !ok {
+			t.Errorf("%s: did not read tenant acme", name)
+			continue
+		}
+		if got := acme.values(); !reflect.DeepEqual(got, map[string][]string{"token": {"xoxb-acme"}, "maxpoints": {"5"}}) {
+			t.Errorf("%s: read %v for tenant acme", name, got)
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, file, content, err string
+	}{
+		{
+			name:    "unknown yaml option",
+			file:    "janet.yaml",
+			content: "token: xoxb-good\nmaxpionts: 3\n",
+			err:     "field maxpionts not found",
+		},
+		{
+			name:    "unknown nested yaml option",
+			file:    "janet.yaml",
+			content: "webui:\n  port: 8080\n",
+			err:     "field port not found",
+		},
+		{
+			name:    "unknown toml option",
+			file:    "janet.toml",
+			content: "token = \"xoxb-good\"\nmaxpionts = 3\n",
+			err:     "unknown options [maxpionts]",
+		},
+		{
+			name:    "unknown nested toml option",
+			file:    "janet.toml",
+			content: "[webui]\nport = 8080\n",
+			err:     "unknown options [webui.port]",
+		},
+		{
+			name:    "wrong type",
+			file:    "janet.yaml",
+			content: "maxpoints: lots\n",
+			err:     "cannot unmarshal",
+		},
+		{
+			name:    "invalid yaml",
+			file:    "janet.yaml",
+			content: "token: [xoxb-good\n",
+			err:     "janet.yaml",
+		},
+		{
+			name:    "unsupported format",
+			file:    "janet.json",
+			content: `{"token": "xoxb-good"}`,
+			err:     "unsupported config format",
+		},
+		{
+			name:    "invalid options",
+			file:    "janet.yaml",
+			content: "maxpoints: 0\n",
+			err:     "invalid config: maxpoints must be at least 1",
+		},
+	} {
+		_, err := Load(writeConfig(t, tc.file, tc.content))
+		if err == nil {
+			t.Errorf("%s: loaded the config; want an error", tc.name)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: returned error %q; want it to contain %q", tc.name, err, tc.err)
+		}
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Errorf("missing file: loaded the config; want an error")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	zero, negative := 0, -1
+
+	for _, tc := range []struct {
+		name string
+		file *File
+		err  string
+	}{
+		{
+			name: "maxpoints",
+			file: &File{MaxPoints: &zero},
+			err:  "maxpoints must be at least 1",
+		},
+		{
+			name: "leaderboardlimit",
+			file: &File{LeaderboardLimit: &zero},
+			err:  "leaderboardlimit must be at least 1",
+		},
+		{
+			name: "transfer limits",
+			file: &File{Transfer: &Transfer{Daily: &negative}},
+			err:  "transfer.max and transfer.daily must not be negative",
+		},
+		{
+			name: "backup.keep",
+			file: &File{Backup: &Backup{Keep: &negative}},
+			err:  "backup.keep must not be negative",
+		},
+		{
+			name: "incomplete digest",
+			file: &File{Digests: []*Digest{{Channel: "C0123ABCD", Window: "week"}}},
+			err:  "digest 1 must have a channel, a window and a schedule",
+		},
+		{
+			name: "user without aliases",
+			file: &File{Aliases: map[string][]string{"troy": nil}},
+			err:  `alias "troy" must have at least one alias`,
+		},
+		{
+			name: "invalid alias",
+			file: &File{Aliases: map[string][]string{"troy": {"tm++troyxmccall"}}},
+			err:  `alias "tm++troyxmccall" of "troy" is invalid`,
+		},
+		{
+			name: "invalid tenant name",
+			file: &File{Tenants: map[string]*File{"Acme Corp": {}}},
+			err:  `invalid tenant name "Acme Corp"`,
+		},
+		{
+			name: "tenant without options",
+			file: &File{Tenants: map[string]*File{"acme": nil}},
+			err:  "tenant acme has no options",
+		},
+		{
+			name: "nested tenants",
+			file: &File{Tenants: map[string]*File{"acme": {Tenants: map[string]*File{"beta": {}}}}},
+			err:  "tenant acme cannot have tenants",
+		},
+		{
+			name: "global option in a tenant",
+			file: &File{Tenants: map[string]*File{"acme": {WebUI: &WebUI{ListenAddr: new(string)}}}},
+			err:  "tenant acme: webui.listenaddr cannot be set per tenant",
+		},
+		{
+			name: "invalid tenant option",
+			file: &File{Tenants: map[string]*File{"acme": {MaxPoints: &zero}}},
+			err:  "tenant acme: maxpoints must be at least 1",
+		},
+	} {
+		err := tc.file.Validate()
+		if err == nil {
+			t.Errorf("%s: returned nil; want an error", tc.name)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: returned error %q; want it to contain %q", tc.name, err, tc.err)
+		}
+	}
+
+	if err := (&File{}).Validate(); err != nil {
+		t.Errorf("empty file: returned error %v", err)
+	}
+}
+
+// list is a flag that can be passed more than once.
+type list []string
+
+func (l *list) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *list) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func TestApply(t *testing.T) {
+	file, err := Load(writeConfig(t, "janet.yaml", yamlConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var blacklist list
+	fs := flag.NewFlagSet("janet", flag.ContinueOnError)
+	token := fs.String("token", "", "")
+	db := fs.String("db", "./db.sqlite3", "")
+	maxpoints := fs.Int("maxpoints", 6, "")
+	motivate := fs.Bool("motivate", true, "")
+	fs.Var(&blacklist, "blacklist", "")
+
+	// flags that are not in the file keep their values,
+	// explicit flags win over the file
+	if err := fs.Parse([]string{"-token", "xoxb-flag"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// ignore the options that this flag set does not define
+	values := file.values()
+	skip := map[string]bool{"token": true}
+	for name := range values {
+		if fs.Lookup(name) == nil {
+			skip[name] = true
+		}
+	}
+
+	if err := file.Apply(fs, skip); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	if *token != "xoxb-flag" {
+		t.Errorf("Apply: token is %q; want the explicit flag", *token)
+	}
+	if *db != "/var/lib/janet/db.sqlite3" || *maxpoints != 3 || *motivate {
+		t.Errorf("Apply: set db %q, maxpoints %d and motivate %v; want the file's values", *db, *maxpoints, *motivate)
+	}
+	if !reflect.DeepEqual(blacklist, list{"janet", "slackbot"}) {
+		t.Errorf("Apply: set blacklist %v; want every value of the file", blacklist)
+	}
+}
+
+func TestApplyInvalidValue(t *testing.T) {
+	shutdowntimeout := "soon"
+	file := &File{ShutdownTimeout: &shutdowntimeout}
+
+	fs := flag.NewFlagSet("janet", flag.ContinueOnError)
+	fs.Duration("shutdowntimeout", 0, "")
+
+	err := file.Apply(fs, nil)
+	if err == nil || !strings.Contains(err.Error(), `invalid value "soon" for shutdowntimeout`) {
+		t.Errorf("Apply: returned %v; want an invalid value error", err)
+	}
+}
@@ -0,0 +1,81 @@
+package janet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// connectionState tracks whether a janet is connected to Slack.
+// It is updated by the listeners as RTM events come in.
+type connectionState struct {
+	connected bool
+	lastError error
+
+	// userID is the Slack ID of the janet's bot user
+	userID string
+
+	mutex sync.RWMutex
+}
+
+func (c *connectionState) setConnected(userID string) {
+	c.mutex.Lock()
+	c.connected = true
+	c.lastError = nil
+	if userID != "" {
+		c.userID = userID
+	}
+	c.mutex.Unlock()
+}
+
+// user returns the Slack ID of the janet's bot user, or an
+// empty string if the janet has not connected yet.
+func (c *connectionState) user() string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.userID
+}
+
+func (c *connectionState) setDisconnected(err error) {
+	c.mutex.Lock()
+	c.connected = false
+	if err != nil {
+		c.lastError = err
+	}
+	c.mutex.Unlock()
+}
+
+func (c *connectionState) setError(err error) {
+	c.mutex.Lock()
+	c.lastError = err
+	c.mutex.Unlock()
+}
+
+// check returns nil if the connection is alive.
+func (c *connectionState) check() error {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	switch {
+	case c.connected:
+		return nil
+	case c.lastError != nil:
+		return fmt.Errorf("not connected to slack: %v", c.lastError)
+	default:
+		return errors.New("not connected to slack")
+	}
+}
+
+// GoodJanetHealth returns an error if Good Janet is not
+// connected to Slack.
+func (b *Bot) GoodJanetHealth(ctx context.Context) error {
+	return b.goodJanetConnection.check()
+}
+
+// BadJanetHealth returns an error if Bad Janet is not
+// connected to Slack.
+func (b *Bot) BadJanetHealth(ctx context.Context) error {
+	return b.badJanetConnection.check()
+}
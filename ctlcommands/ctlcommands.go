@@ -1,7 +1,12 @@
 package ctlcommands
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/troyxmccall/janet/database"
@@ -12,227 +17,123 @@ import (
 	"github.com/urfave/cli"
 )
 
+// The exit codes of janetctl.
+const (
+	ExitError    = 1
+	ExitUsage    = 2
+	ExitNotFound = 3
+)
+
+// Commands implements the janetctl commands. Results are written
+// to Out, defaulting to stdout, and progress is logged to Logger.
+// Confirmations are read from In, defaulting to stdin. Errors are
+// returned as cli.ExitCoder with one of the exit codes.
 type Commands struct {
 	Logger *log.Log
+	Out    io.Writer
+	In     io.Reader
 }
 
-func (cc *Commands) Serve(c *cli.Context) error {
-	db := cc.getDB(c.String("db"))
-	TOTP := c.String("totp")
-
-	ui, err := webui.New(&webui.Config{
-		ListenAddr:       c.String("listenaddr"),
-		URL:              c.String("url"),
-		FilesPath:        c.String("path"),
-		TOTP:             TOTP,
-		LeaderboardLimit: c.Int("leaderboardlimit"),
-		Log:              cc.Logger.KV("provider", "webui"),
-		Debug:            c.Bool("debug"),
-		DB:               db,
-	})
-
-	if err != nil {
-		cc.Logger.Err(err).Fatal("could not initialize web ui")
-		return err
-	}
-
-	token, err := totp.GenerateCode(TOTP, time.Now())
-	if err != nil {
-		cc.Logger.Err(err).Fatal("could not generate totp token")
-	} else {
-		cc.Logger.KV("token", token).Info("generated totp token")
-	}
-
-	ui.Listen()
-	return nil
+// usageError is returned when a command is called with invalid arguments.
+func usageError(format string, a ...interface{}) error {
+	return cli.NewExitError(fmt.Sprintf(format, a...), ExitUsage)
 }
 
-func (cc *Commands) Mktotp(c *cli.Context) error {
-	TOTP := c.String("totp")
-	token, err := totp.GenerateCode(TOTP, time.Now())
-	if err != nil {
-		cc.Logger.Err(err).Fatal("could not generate token")
+// failed wraps errors that happened while running a command.
+func failed(err error, message string) error {
+	code := ExitError
+	switch err {
+	case database.ErrNoSuchUser, database.ErrNoSuchAlias, database.ErrNotBlacklisted, database.ErrNoOperation, database.ErrNoSuchWebhook, database.ErrNoSuchReward:
+		code = ExitNotFound
 	}
 
-	cc.Logger.KV("token", token).Info("generated token")
-
-	return nil
+	return cli.NewExitError(fmt.Sprintf("%s: %v", message, err), code)
 }
 
-func (cc *Commands) AddPoints(c *cli.Context) error {
-	var (
-		db     = cc.getDB(c.String("db"))
-		from   = c.String("from")
-		to     = c.String("to")
-		reason = c.String("reason")
-		points = c.Int("points")
-	)
-
-	if from == "" || to == "" {
-		cc.Logger.Fatal("please pass valid users to the `to` and `from` options")
-	}
-
-	if points == 0 {
-		cc.Logger.Fatal("you may not add 0 points to a user")
-	}
-
-	record := &database.Points{
-		From:   from,
-		To:     to,
-		Reason: reason,
-		Points: points,
-	}
-
-	err := db.InsertPoints(record)
-	if err != nil {
-		cc.Logger.Err(err).Fatal("could not insert record")
+// print writes a result in the format passed to `output`.
+func (cc *Commands) print(c *cli.Context, r *result) error {
+	out := cc.Out
+	if out == nil {
+		out = os.Stdout
 	}
 
-	cc.Logger.Info("inserted record")
-
-	return nil
+	return r.write(out, c.String("output"))
 }
 
-func (cc *Commands) MigratePoints(c *cli.Context) error {
-	var (
-		db   = cc.getDB(c.String("db"))
-		from = c.String("from")
-		to   = c.String("to")
-	)
-
-	if from == "" || to == "" {
-		cc.Logger.Fatal("please pass valid users to the `to` and `from` options")
-	}
+func (cc *Commands) getDB(c *cli.Context) (*database.DB, error) {
+	path := c.String("db")
+	db, err := database.New(&database.Config{
+		Path: path,
+	})
 
-	user, err := db.GetUser(from)
 	if err != nil {
-		cc.Logger.Err(err).KV("from", from).Fatal("could not look up user `from`")
+		return nil, failed(err, fmt.Sprintf("could not open sqlite db %s", path))
 	}
 
-	if user.Points == 0 {
-		cc.Logger.KV("from", from).Fatal("user does not have any points")
-	}
-
-	reason := fmt.Sprintf("migrating karma from %s to %s", from, to)
-	records := []*database.Points{
-		// remove points from `from`
-		{
-			From:   "janet",
-			To:     from,
-			Reason: reason,
-			Points: -user.Points,
-		},
-		// add points to `to`
-		{
-			From:   "janet",
-			To:     to,
-			Reason: reason,
-			Points: user.Points,
-		},
-	}
-
-	for _, record := range records {
-		err := db.InsertPoints(record)
-		if err != nil {
-			cc.Logger.Err(err).Fatal("could not insert record")
-		}
-	}
-
-	cc.Logger.KV("from", from).KV("to", to).KV("points", user.Points).Info("migrated karma")
-
-	return nil
+	return db, nil
 }
 
-func (cc *Commands) ResetPoints(c *cli.Context) error {
-	var (
-		db   = cc.getDB(c.String("db"))
-		name = c.String("user")
-	)
-
-	if name == "" {
-		cc.Logger.Fatal("please pass a valid user to the `user` option")
-	}
-
-	user, err := db.GetUser(name)
-	if err != nil {
-		cc.Logger.Err(err).KV("user", name).Fatal("could not look up user")
-	}
-
-	err = db.InsertPoints(&database.Points{
-		From:   "janet",
-		To:     name,
-		Points: -1 * user.Points,
-		Reason: "janetctl resetting karma",
-	})
-
+func (cc *Commands) Serve(c *cli.Context) error {
+	db, err := cc.getDB(c)
 	if err != nil {
-		cc.Logger.Err(err).Fatal("could not insert record")
+		return err
 	}
+	defer db.Close()
 
-	cc.Logger.KV("user", name).Info("reset karma")
-
-	return nil
-}
-
-func (cc *Commands) SetPoints(c *cli.Context) error {
-	var (
-		db     = cc.getDB(c.String("db"))
-		name   = c.String("user")
-		points = c.Int("points")
-	)
+	TOTP := c.String("totp")
 
-	if name == "" {
-		cc.Logger.Fatal("please pass a valid user to the `user` option")
-	}
+	ui, err := webui.New(&webui.Config{
+		ListenAddr:       c.String("listenaddr"),
+		URL:              c.String("url"),
+		FilesPath:        c.String("path"),
+		TOTP:             TOTP,
+		LeaderboardLimit: c.Int("leaderboardlimit"),
+		Log:              cc.Logger.KV("provider", "webui"),
+		Debug:            c.Bool("debug"),
+		DB:               db,
+	})
 
-	user, err := db.GetUser(name)
 	if err != nil {
-		cc.Logger.Err(err).KV("user", name).Fatal("could not look up user")
+		return failed(err, "could not initialize web ui")
 	}
 
-	err = db.InsertPoints(&database.Points{
-		From:   "janet",
-		To:     name,
-		Points: points - user.Points,
-		Reason: "janetctl overriding karma",
-	})
-
+	token, err := totp.GenerateCode(TOTP, time.Now())
 	if err != nil {
-		cc.Logger.Err(err).Fatal("could not insert record")
+		return failed(err, "could not generate totp token")
 	}
+	cc.Logger.KV("token", token).Info("generated totp token")
 
-	cc.Logger.KV("user", name).KV("points", points).Info("set karma")
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	return nil
-}
+	go func() {
+		<-ctx.Done()
 
-func (cc *Commands) GetThrowback(c *cli.Context) error {
-	var (
-		user = c.String("user")
-		db   = cc.getDB(c.String("db"))
-	)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), c.Duration("shutdowntimeout"))
+		defer cancel()
 
-	if user == "" {
-		cc.Logger.Fatal("please pass a valid user to the `user` option")
-	}
+		if err := ui.Shutdown(shutdownCtx); err != nil {
+			cc.Logger.Err(err).Error("could not shut down web ui")
+		}
+	}()
 
-	throwback, err := db.GetThrowback(user)
+	err = ui.Listen()
 	if err != nil {
-		cc.Logger.Err(err).Fatal("could not look up user data")
+		return failed(err, "could not serve web ui")
 	}
 
-	cc.Logger.KV("throwback", throwback).Info("got throwback")
 	return nil
 }
 
-func (cc *Commands) getDB(path string) *database.DB {
-	db, err := database.New(&database.Config{
-		Path: path,
-	})
-
+func (cc *Commands) Mktotp(c *cli.Context) error {
+	TOTP := c.String("totp")
+	token, err := totp.GenerateCode(TOTP, time.Now())
 	if err != nil {
-		cc.Logger.KV("path", path).Err(err).Fatal("could not open sqlite db")
+		return failed(err, "could not generate token")
 	}
 
-	return db
+	r := newResult("token")
+	r.add(token)
+
+	return cc.print(c, r)
 }
@@ -0,0 +1,89 @@
+package ctlcommands
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/troyxmccall/janet/database"
+
+	"github.com/urfave/cli"
+)
+
+func (cc *Commands) BackupDB(c *cli.Context) error {
+	var (
+		path = c.String("file")
+		dir  = c.String("dir")
+		keep = c.Int("keep")
+	)
+
+	if (path == "") == (dir == "") {
+		return usageError("please pass either the `file` or the `dir` option")
+	}
+
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if path != "" {
+		err = db.Backup(context.Background(), path)
+	} else {
+		path, err = db.BackupDir(context.Background(), dir, keep)
+	}
+
+	if err != nil {
+		return failed(err, "could not back up sqlite db")
+	}
+
+	r := newResult("backup")
+	r.add(path)
+
+	return cc.print(c, r)
+}
+
+func (cc *Commands) RestoreDB(c *cli.Context) error {
+	var (
+		path     = c.String("db")
+		backup   = c.String("file")
+		previous string
+	)
+
+	if backup == "" {
+		return usageError("please pass the backup to restore to the `file` option")
+	}
+
+	err := database.CheckIntegrity(backup)
+	if err != nil {
+		return failed(err, fmt.Sprintf("refusing to restore %s", backup))
+	}
+
+	// keep a copy of the current database, in case
+	// the wrong backup is restored
+	if _, err := os.Stat(path); err == nil {
+		db, err := cc.getDB(c)
+		if err != nil {
+			return err
+		}
+
+		previous = fmt.Sprintf("%s.%s.bak", path, time.Now().UTC().Format("20060102-150405"))
+		err = db.Backup(context.Background(), previous)
+		db.Close()
+
+		if err != nil {
+			return failed(err, "could not back up the current sqlite db")
+		}
+	}
+
+	err = database.Restore(backup, path)
+	if err != nil {
+		return failed(err, "could not restore sqlite db")
+	}
+
+	r := newResult("db", "backup", "previous")
+	r.add(path, backup, previous)
+
+	return cc.print(c, r)
+}
@@ -0,0 +1,428 @@
+package ctlcommands
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/troyxmccall/janet/database"
+	"github.com/troyxmccall/janet/importer"
+	"github.com/troyxmccall/janet/karmaio"
+	"github.com/troyxmccall/janet/webhook"
+
+	"github.com/urfave/cli"
+)
+
+// pointsResult lists the karma records inserted by a command.
+func pointsResult(records ...*database.Points) *result {
+	r := newResult("from", "to", "points", "type", "reason")
+	for _, record := range records {
+		r.add(record.From, record.To, record.Points, record.Type, record.Reason)
+	}
+
+	return r
+}
+
+// recordsResult lists karma records with their timestamps.
+func recordsResult(records ...*database.Record) *result {
+	r := newResult("from", "to", "points", "type", "reason", "timestamp")
+	for _, record := range records {
+		r.add(record.From, record.To, record.Points.Points, record.Type, record.Reason, record.Timestamp)
+	}
+
+	return r
+}
+
+func (cc *Commands) AddPoints(c *cli.Context) error {
+	var (
+		from   = c.String("from")
+		to     = c.String("to")
+		reason = c.String("reason")
+		points = c.Int("points")
+	)
+
+	if from == "" || to == "" {
+		return usageError("please pass valid users to the `to` and `from` options")
+	}
+
+	if points == 0 {
+		return usageError("you may not add 0 points to a user")
+	}
+
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	record := &database.Points{
+		From:   from,
+		To:     to,
+		Reason: reason,
+		Points: points,
+		Type:   database.TypeEarned,
+	}
+
+	err = db.InsertPoints(record)
+	if err != nil {
+		return failed(err, "could not insert record")
+	}
+
+	err = webhook.SendKarma(db, webhook.EventInsert, record)
+	if err != nil {
+		cc.Logger.Err(err).Error("could not queue webhook events")
+	}
+
+	return cc.print(c, pointsResult(record))
+}
+
+// MigratePoints moves all of a user's karma to another user.
+func (cc *Commands) MigratePoints(c *cli.Context) error {
+	var (
+		from   = c.String("from")
+		to     = c.String("to")
+		reason = c.String("reason")
+	)
+
+	if from == "" || to == "" {
+		return usageError("please pass valid users to the `to` and `from` options")
+	}
+
+	if from == to {
+		return usageError("please pass different users to the `to` and `from` options")
+	}
+
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	op, err := db.PlanMigrate(from, to, reason)
+	if err != nil {
+		return planFailed(err, fmt.Sprintf("could not migrate the karma of %s", from))
+	}
+
+	// migrating to a new user is allowed, but might be a typo
+	if _, err := db.GetUser(to); err == database.ErrNoSuchUser {
+		cc.Logger.KV("to", to).Info("user does not have any karma yet")
+	}
+
+	return cc.applyOperation(c, db, op)
+}
+
+// ResetPoints resets a user's karma to 0.
+func (cc *Commands) ResetPoints(c *cli.Context) error {
+	name := c.String("user")
+	if name == "" {
+		return usageError("please pass a valid user to the `user` option")
+	}
+
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	op, err := db.PlanReset(name, c.String("reason"))
+	if err != nil {
+		return planFailed(err, fmt.Sprintf("could not reset the karma of %s", name))
+	}
+
+	return cc.applyOperation(c, db, op)
+}
+
+// SetPoints sets a user's karma to a specific number.
+func (cc *Commands) SetPoints(c *cli.Context) error {
+	var (
+		name   = c.String("user")
+		points = c.Int("points")
+	)
+
+	if name == "" {
+		return usageError("please pass a valid user to the `user` option")
+	}
+
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	op, err := db.PlanSet(name, points, c.String("reason"))
+	if err != nil {
+		return planFailed(err, fmt.Sprintf("could not set the karma of %s", name))
+	}
+
+	return cc.applyOperation(c, db, op)
+}
+
+func (cc *Commands) GetThrowback(c *cli.Context) error {
+	user := c.String("user")
+	if user == "" {
+		return usageError("please pass a valid user to the `user` option")
+	}
+
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	throwback, err := db.GetThrowback(user)
+	if err != nil {
+		return failed(err, fmt.Sprintf("could not look up a throwback for %s", user))
+	}
+
+	return cc.print(c, recordsResult(&database.Record{
+		Points:    throwback.Points,
+		Timestamp: throwback.Timestamp,
+	}))
+}
+
+// GetPoints prints a user's lifetime karma and balance.
+func (cc *Commands) GetPoints(c *cli.Context) error {
+	name := c.String("user")
+	if name == "" {
+		return usageError("please pass a valid user to the `user` option")
+	}
+
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	user, err := db.GetUser(name)
+	if err != nil {
+		return failed(err, fmt.Sprintf("could not look up user %s", name))
+	}
+
+	r := newResult("user", "points", "balance")
+	r.add(user.Name, user.Points, user.Balance)
+
+	return cc.print(c, r)
+}
+
+// GetLeaderboard prints the users with the most karma.
+func (cc *Commands) GetLeaderboard(c *cli.Context) error {
+	limit := c.Int("limit")
+	if limit < 1 {
+		return usageError("please pass a positive number to the `limit` option")
+	}
+
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	leaderboard, err := db.GetLeaderboard(limit)
+	if err != nil {
+		return failed(err, "could not look up the leaderboard")
+	}
+
+	r := newResult("rank", "user", "points")
+	for i, user := range leaderboard {
+		r.add(i+1, user.Name, user.Points)
+	}
+
+	return cc.print(c, r)
+}
+
+// GetHistory prints the karma that a user gave
+// and received, newest first.
+func (cc *Commands) GetHistory(c *cli.Context) error {
+	var (
+		name  = c.String("user")
+		limit = c.Int("limit")
+	)
+
+	if name == "" {
+		return usageError("please pass a valid user to the `user` option")
+	}
+
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	records, err := db.GetHistory(name, limit)
+	if err != nil {
+		return failed(err, fmt.Sprintf("could not look up the history of %s", name))
+	}
+	if len(records) == 0 {
+		return failed(database.ErrNoSuchUser, fmt.Sprintf("could not look up the history of %s", name))
+	}
+
+	return cc.print(c, recordsResult(records...))
+}
+
+func (cc *Commands) ExportPoints(c *cli.Context) error {
+	var (
+		path   = c.String("file")
+		format = c.String("format")
+	)
+
+	if format == "" {
+		format = karmaio.FormatFromPath(path)
+	}
+
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	records, err := db.GetRecords()
+	if err != nil {
+		return failed(err, "could not look up karma records")
+	}
+
+	out := os.Stdout
+	if path != "" && path != "-" {
+		out, err = os.Create(path)
+		if err != nil {
+			return failed(err, "could not create export file")
+		}
+		defer out.Close()
+	}
+
+	err = karmaio.Write(out, format, records)
+	if err == karmaio.ErrUnknownFormat {
+		return usageError("%v", err)
+	}
+	if err != nil {
+		return failed(err, "could not export karma records")
+	}
+
+	if out != os.Stdout {
+		err = out.Close()
+		if err != nil {
+			return failed(err, "could not write export file")
+		}
+	}
+
+	cc.Logger.KV("records", len(records)).KV("format", format).Info("exported karma")
+
+	return nil
+}
+
+func (cc *Commands) ImportPoints(c *cli.Context) error {
+	var (
+		path   = c.String("file")
+		format = c.String("format")
+		dryRun = c.Bool("dry-run")
+	)
+
+	if format == "" {
+		format = karmaio.FormatFromPath(path)
+	}
+
+	in := os.Stdin
+	if path != "" && path != "-" {
+		var err error
+		in, err = os.Open(path)
+		if err != nil {
+			return failed(err, "could not open import file")
+		}
+		defer in.Close()
+	}
+
+	records, err := karmaio.Read(in, format)
+	if err == karmaio.ErrUnknownFormat {
+		return usageError("%v", err)
+	}
+	if err != nil {
+		return failed(err, "could not read karma records")
+	}
+
+	return cc.importRecords(c, records, dryRun)
+}
+
+// importRecords imports records, prints the changes per
+// user and logs a summary.
+func (cc *Commands) importRecords(c *cli.Context, records []*database.Record, dryRun bool) error {
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	summary, err := db.ImportRecords(records, dryRun)
+	if err != nil {
+		return failed(err, "could not import karma records")
+	}
+
+	users := make([]string, 0, len(summary.Points))
+	for user := range summary.Points {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+
+	r := newResult("user", "points")
+	for _, user := range users {
+		r.add(user, summary.Points[user])
+	}
+
+	cc.Logger.
+		KV("read", summary.Read).
+		KV("inserted", summary.Inserted).
+		KV("skipped", summary.Skipped).
+		KV("dry-run", dryRun).
+		Info("imported karma")
+
+	return cc.print(c, r)
+}
+
+// Import imports karma from another bot, using the
+// importer with the same name as the command.
+func (cc *Commands) Import(c *cli.Context) error {
+	var (
+		name   = c.Command.Name
+		path   = c.Args().First()
+		from   = c.String("from")
+		dryRun = c.Bool("dry-run")
+	)
+
+	imp, err := importer.Get(name)
+	if err != nil {
+		return usageError("%v: %s", err, name)
+	}
+
+	if path == "" {
+		return usageError("please pass the file to import")
+	}
+
+	if from == "" {
+		from = name
+	}
+
+	// the importer falls back to importer.Epoch, which
+	// keeps re-imports of the same data idempotent
+	var timestamp time.Time
+	if t := c.String("timestamp"); t != "" {
+		timestamp, err = time.Parse(time.RFC3339, t)
+		if err != nil {
+			return usageError("please pass an RFC 3339 timestamp to the `timestamp` option")
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return failed(err, "could not open import file")
+	}
+	defer f.Close()
+
+	records, err := imp(f, &importer.Options{
+		From:      from,
+		Timestamp: timestamp,
+	})
+	if err != nil {
+		return failed(err, "could not read import file")
+	}
+
+	return cc.importRecords(c, records, dryRun)
+}
@@ -0,0 +1,144 @@
+package ctlcommands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/urfave/cli"
+)
+
+func (cc *Commands) AddAlias(c *cli.Context) error {
+	var (
+		alias = strings.ToLower(c.String("alias"))
+		user  = strings.ToLower(c.String("user"))
+	)
+
+	if alias == "" || user == "" {
+		return usageError("please pass valid users to the `alias` and `user` options")
+	}
+
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.InsertAlias(alias, user)
+	if err != nil {
+		return failed(err, "could not insert alias")
+	}
+
+	r := newResult("alias", "user")
+	r.add(alias, user)
+
+	return cc.print(c, r)
+}
+
+func (cc *Commands) RemoveAlias(c *cli.Context) error {
+	alias := strings.ToLower(c.String("alias"))
+	if alias == "" {
+		return usageError("please pass a valid user to the `alias` option")
+	}
+
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.DeleteAlias(alias)
+	if err != nil {
+		return failed(err, fmt.Sprintf("could not remove alias %s", alias))
+	}
+
+	r := newResult("alias")
+	r.add(alias)
+
+	return cc.print(c, r)
+}
+
+func (cc *Commands) ListAliases(c *cli.Context) error {
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	aliases, err := db.GetAliases()
+	if err != nil {
+		return failed(err, "could not look up aliases")
+	}
+
+	r := newResult("alias", "user")
+	for _, alias := range aliases {
+		r.add(alias.Alias, alias.User)
+	}
+
+	return cc.print(c, r)
+}
+
+func (cc *Commands) AddBlacklist(c *cli.Context) error {
+	user := strings.ToLower(c.String("user"))
+	if user == "" {
+		return usageError("please pass a valid user to the `user` option")
+	}
+
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.InsertBlacklist(user)
+	if err != nil {
+		return failed(err, "could not blacklist user")
+	}
+
+	r := newResult("user")
+	r.add(user)
+
+	return cc.print(c, r)
+}
+
+func (cc *Commands) RemoveBlacklist(c *cli.Context) error {
+	user := strings.ToLower(c.String("user"))
+	if user == "" {
+		return usageError("please pass a valid user to the `user` option")
+	}
+
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.DeleteBlacklist(user)
+	if err != nil {
+		return failed(err, fmt.Sprintf("could not remove %s from the blacklist", user))
+	}
+
+	r := newResult("user")
+	r.add(user)
+
+	return cc.print(c, r)
+}
+
+func (cc *Commands) ListBlacklist(c *cli.Context) error {
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	users, err := db.GetBlacklist()
+	if err != nil {
+		return failed(err, "could not look up the blacklist")
+	}
+
+	r := newResult("user")
+	for _, user := range users {
+		r.add(user)
+	}
+
+	return cc.print(c, r)
+}
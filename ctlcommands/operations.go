@@ -0,0 +1,91 @@
+package ctlcommands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/troyxmccall/janet/database"
+	"github.com/troyxmccall/janet/webhook"
+
+	"github.com/urfave/cli"
+)
+
+// applyOperation prints the records of a planned operation and,
+// unless `dry-run` is passed, applies it once the user confirmed
+// it or passed `yes`.
+func (cc *Commands) applyOperation(c *cli.Context, db *database.DB, op *database.Operation) error {
+	err := cc.print(c, pointsResult(op.Records...))
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("dry-run") {
+		return nil
+	}
+
+	if !c.Bool("yes") && !cc.confirm(fmt.Sprintf("insert these %d records?", len(op.Records))) {
+		return cli.NewExitError("aborted, nothing was changed", ExitError)
+	}
+
+	err = db.Apply(op)
+	if err != nil {
+		return failed(err, "could not insert records")
+	}
+
+	cc.Logger.KV("operation", op.ID).KV("kind", op.Kind).KV("records", len(op.Records)).Info("applied operation")
+
+	err = webhook.SendOperation(db, op)
+	if err != nil {
+		cc.Logger.Err(err).Error("could not queue webhook events")
+	}
+
+	return nil
+}
+
+// confirm asks a yes/no question on stderr and reads the answer.
+// Anything but yes, including no answer at all, means no.
+func (cc *Commands) confirm(question string) bool {
+	in := cc.In
+	if in == nil {
+		in = os.Stdin
+	}
+
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+// planFailed wraps errors that happened while planning an operation.
+func planFailed(err error, message string) error {
+	if err == database.ErrNoChange {
+		return cli.NewExitError(fmt.Sprintf("%s: nothing to change", message), ExitError)
+	}
+
+	return failed(err, message)
+}
+
+// Rollback undoes the last migrate, reset, set or revoke.
+func (cc *Commands) Rollback(c *cli.Context) error {
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	op, err := db.PlanRollback()
+	if err != nil {
+		return failed(err, "could not look up the last operation")
+	}
+
+	cc.Logger.KV("operation", op.Reverts).Info("rolling back operation")
+
+	return cc.applyOperation(c, db, op)
+}
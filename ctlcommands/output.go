@@ -0,0 +1,128 @@
+package ctlcommands
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// The output formats of all commands.
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputCSV   = "csv"
+)
+
+// A result is the output of a command: rows of values
+// with named columns.
+type result struct {
+	columns []string
+	rows    [][]interface{}
+}
+
+func newResult(columns ...string) *result {
+	return &result{
+		columns: columns,
+	}
+}
+
+func (r *result) add(values ...interface{}) {
+	r.rows = append(r.rows, values)
+}
+
+// write writes the result to w. Tables are meant to be read by
+// people, json and csv by scripts. json is an array of objects
+// with the columns as keys, in order. Timestamps are always in UTC.
+func (r *result) write(w io.Writer, format string) error {
+	switch format {
+	case OutputTable, "":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(r.columns, "\t")))
+		for _, row := range r.rows {
+			fmt.Fprintln(tw, strings.Join(r.strings(row), "\t"))
+		}
+
+		return tw.Flush()
+
+	case OutputCSV:
+		cw := csv.NewWriter(w)
+		err := cw.Write(r.columns)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range r.rows {
+			err := cw.Write(r.strings(row))
+			if err != nil {
+				return err
+			}
+		}
+
+		cw.Flush()
+		return cw.Error()
+
+	case OutputJSON:
+		var buf bytes.Buffer
+		buf.WriteString("[")
+		for i, row := range r.rows {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n  {")
+
+			for j, column := range r.columns {
+				if j > 0 {
+					buf.WriteString(", ")
+				}
+
+				key, err := json.Marshal(column)
+				if err != nil {
+					return err
+				}
+				v := row[j]
+				if t, ok := v.(time.Time); ok {
+					v = t.UTC()
+				}
+				value, err := json.Marshal(v)
+				if err != nil {
+					return err
+				}
+
+				buf.Write(key)
+				buf.WriteString(": ")
+				buf.Write(value)
+			}
+
+			buf.WriteString("}")
+		}
+		if len(r.rows) > 0 {
+			buf.WriteString("\n")
+		}
+		buf.WriteString("]\n")
+
+		_, err := buf.WriteTo(w)
+		return err
+
+	default:
+		return usageError("unknown output format %q, please use table, json or csv", format)
+	}
+}
+
+func (r *result) strings(row []interface{}) []string {
+	values := make([]string, len(row))
+	for i, value := range row {
+		switch v := value.(type) {
+		case time.Time:
+			values[i] = v.UTC().Format(time.RFC3339)
+		default:
+			values[i] = fmt.Sprint(v)
+		}
+	}
+
+	return values
+}
@@ -0,0 +1,125 @@
+package ctlcommands
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/troyxmccall/janet/database"
+
+	"github.com/urfave/cli"
+)
+
+func TestResultWrite(t *testing.T) {
+	r := newResult("name", "points", "timestamp")
+	r.add("troy", 5, time.Date(2019, 1, 14, 10, 0, 0, 0, time.FixedZone("CET", 3600)))
+	r.add("alex, jr.", -2, time.Date(2019, 1, 14, 9, 30, 0, 0, time.UTC))
+
+	for _, tc := range []struct {
+		format string
+		want   string
+	}{
+		{
+			format: OutputTable,
+			want: "NAME       POINTS  TIMESTAMP\n" +
+				"troy       5       2019-01-14T09:00:00Z\n" +
+				"alex, jr.  -2      2019-01-14T09:30:00Z\n",
+		},
+		{
+			format: "",
+			want: "NAME       POINTS  TIMESTAMP\n" +
+				"troy       5       2019-01-14T09:00:00Z\n" +
+				"alex, jr.  -2      2019-01-14T09:30:00Z\n",
+		},
+		{
+			format: OutputCSV,
+			want: "name,points,timestamp\n" +
+				"troy,5,2019-01-14T09:00:00Z\n" +
+				"\"alex, jr.\",-2,2019-01-14T09:30:00Z\n",
+		},
+		{
+			format: OutputJSON,
+			want: "[\n" +
+				`  {"name": "troy", "points": 5, "timestamp": "2019-01-14T09:00:00Z"},` + "\n" +
+				`  {"name": "alex, jr.", "points": -2, "timestamp": "2019-01-14T09:30:00Z"}` + "\n" +
+				"]\n",
+		},
+	} {
+		var buf bytes.Buffer
+		if err := r.write(&buf, tc.format); err != nil {
+			t.Errorf("%q: returned error %v", tc.format, err)
+			continue
+		}
+
+		if got := buf.String(); got != tc.want {
+			t.Errorf("%q: wrote\n%s\nwant\n%s", tc.format, got, tc.want)
+		}
+	}
+}
+
+func TestResultWriteEmpty(t *testing.T) {
+	r := newResult("name", "points")
+
+	for format, want := range map[string]string{
+		OutputTable: "NAME  POINTS\n",
+		OutputCSV:   "name,points\n",
+		OutputJSON:  "[]\n",
+	} {
+		var buf bytes.Buffer
+		if err := r.write(&buf, format); err != nil {
+			t.Errorf("%q: returned error %v", format, err)
+			continue
+		}
+
+		if got := buf.String(); got != want {
+			t.Errorf("%q: wrote %q; want %q", format, got, want)
+		}
+	}
+}
+
+func TestResultWriteUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	err := newResult("name").write(&buf, "xml")
+
+	if exit, ok := err.(cli.ExitCoder); !ok || exit.ExitCode() != ExitUsage {
+		t.Errorf("returned %v; want a usage error", err)
+	}
+	if buf.Len() > 0 {
+		t.Errorf("wrote %q for an unknown format", buf.String())
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"usage", usageError("please pass the %s option", "user"), ExitUsage},
+		{"no such user", failed(database.ErrNoSuchUser, "could not get user"), ExitNotFound},
+		{"no such alias", failed(database.ErrNoSuchAlias, "could not delete alias"), ExitNotFound},
+		{"not blacklisted", failed(database.ErrNotBlacklisted, "could not remove user"), ExitNotFound},
+		{"no operation", failed(database.ErrNoOperation, "could not roll back"), ExitNotFound},
+		{"no such webhook", failed(database.ErrNoSuchWebhook, "could not delete webhook"), ExitNotFound},
+		{"no such reward", failed(database.ErrNoSuchReward, "could not get reward"), ExitNotFound},
+		{"other", failed(errors.New("disk full"), "could not add karma"), ExitError},
+	} {
+		exit, ok := tc.err.(cli.ExitCoder)
+		if !ok {
+			t.Errorf("%s: returned %T; want a cli.ExitCoder", tc.name, tc.err)
+			continue
+		}
+
+		if exit.ExitCode() != tc.code {
+			t.Errorf("%s: exits with %d; want %d", tc.name, exit.ExitCode(), tc.code)
+		}
+	}
+
+	if got, want := failed(errors.New("disk full"), "could not add karma").Error(), "could not add karma: disk full"; got != want {
+		t.Errorf("failed: returned %q; want %q", got, want)
+	}
+	if got, want := usageError("please pass the %s option", "user").Error(), "please pass the user option"; got != want {
+		t.Errorf("usageError: returned %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,250 @@
+package ctlcommands
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/troyxmccall/janet"
+	"github.com/troyxmccall/janet/database"
+	"github.com/troyxmccall/janet/slackexport"
+	"github.com/troyxmccall/janet/ui/blankui"
+
+	"github.com/nlopes/slack"
+	"github.com/urfave/cli"
+)
+
+// exportChatService is a janet.ChatService that looks users up in
+// a slack export and discards everything that janet would send.
+type exportChatService struct {
+	export *slackexport.Export
+}
+
+func (e *exportChatService) IncomingEventsChan() chan slack.RTMEvent {
+	return nil
+}
+
+func (e *exportChatService) NewOutgoingMessage(text string, channel string, options ...slack.RTMsgOption) *slack.OutgoingMessage {
+	return &slack.OutgoingMessage{
+		Type:    "message",
+		Channel: channel,
+		Text:    text,
+	}
+}
+
+func (e *exportChatService) SendMessage(msg *slack.OutgoingMessage) {}
+
+func (e *exportChatService) SendBlocks(channel, thread, text string, blocks ...slack.Block) error {
+	return nil
+}
+
+func (e *exportChatService) SendAs(channel, thread, text string, identity *janet.Identity, blocks ...slack.Block) error {
+	return nil
+}
+
+func (e *exportChatService) OpenIMChannel(user string) (bool, bool, string, error) {
+	return false, false, user, nil
+}
+
+func (e *exportChatService) GetUserInfo(user string) (*slack.User, error) {
+	info, ok := e.export.Users[user]
+	if !ok {
+		return nil, fmt.Errorf("user %s is not in the slack export", user)
+	}
+
+	return info, nil
+}
+
+func (e *exportChatService) GetUsers() ([]slack.User, error) {
+	users := make([]slack.User, 0, len(e.export.Users))
+	for _, user := range e.export.Users {
+		users = append(users, *user)
+	}
+
+	return users, nil
+}
+
+// replayDB inserts karma with the timestamp of the
+// message that is being replayed.
+type replayDB struct {
+	*database.DB
+
+	timestamp time.Time
+	dryRun    bool
+	inserted  int
+
+	// untracked counts the karma without a message that
+	// has been matched by replayed karma, per operation
+	untracked map[untrackedKey]int
+	matched   int
+}
+
+type untrackedKey struct {
+	to        string
+	points    int
+	timestamp time.Time
+}
+
+// InsertPoints skips karma that was recorded before janet kept track
+// of messages: operations without a message that gave the same user
+// the same points in the same second as the replayed message.
+func (r *replayDB) InsertPoints(points *database.Points) error {
+	key := untrackedKey{points.To, points.Points, r.timestamp.Truncate(time.Second)}
+	untracked, err := r.DB.CountUntracked(key.to, key.points, key.timestamp)
+	if err != nil {
+		return err
+	}
+	if r.untracked[key] < untracked {
+		r.untracked[key]++
+		r.matched++
+		return nil
+	}
+
+	r.inserted++
+	if r.dryRun {
+		return nil
+	}
+
+	return r.DB.InsertRecord(&database.Record{
+		Points:    *points,
+		Timestamp: r.timestamp,
+	})
+}
+
+// GetUser pretends that users exist in dry-run mode, where
+// their first karma is never inserted.
+func (r *replayDB) GetUser(name string) (*database.User, error) {
+	user, err := r.DB.GetUser(name)
+	if err == database.ErrNoSuchUser && r.dryRun {
+		return &database.User{Name: name}, nil
+	}
+
+	return user, err
+}
+
+// QueueEvent does not queue anything, since replayed
+// karma is history rather than news for webhooks.
+func (r *replayDB) QueueEvent(event string, payload []byte) error {
+	return nil
+}
+
+// Replay backfills karma from a slack export. Messages whose karma
+// has already been recorded by janet are skipped, and so is karma
+// that was recorded without its message (see replayDB.InsertPoints).
+func (cc *Commands) Replay(c *cli.Context) error {
+	var (
+		path   = c.Args().First()
+		dryRun = c.Bool("dry-run")
+	)
+
+	if path == "" {
+		return usageError("please pass the path to an extracted slack export")
+	}
+
+	since, err := parseTime(c.String("since"))
+	if err != nil {
+		return usageError("please pass an RFC 3339 timestamp or a date to the `since` option")
+	}
+	until, err := parseTime(c.String("until"))
+	if err != nil {
+		return usageError("please pass an RFC 3339 timestamp or a date to the `until` option")
+	}
+
+	export, err := slackexport.Open(path)
+	if err != nil {
+		return failed(err, fmt.Sprintf("could not open slack export %s", path))
+	}
+
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var (
+		rdb = &replayDB{
+			DB:        db,
+			dryRun:    dryRun,
+			untracked: make(map[untrackedKey]int),
+		}
+		chat = &exportChatService{
+			export: export,
+		}
+		bot = janet.New(&janet.Config{
+			Slack:      chat,
+			UI:         blankui.New(),
+			DB:         rdb,
+			Log:        cc.Logger.KV("provider", "replay"),
+			MaxPoints:  c.Int("maxpoints"),
+			Motivate:   c.Bool("motivate"),
+			SelfPoints: c.Bool("selfkarma"),
+		})
+		r             = newResult("channel", "ts", "timestamp", "text")
+		read, skipped int
+	)
+
+	for _, channel := range export.Channels {
+		messages, err := export.Messages(channel)
+		if err != nil {
+			return failed(err, fmt.Sprintf("could not read the messages of %s", channel.Name))
+		}
+
+		for _, msg := range messages {
+			timestamp, err := slackexport.ParseTimestamp(msg.Timestamp)
+			if err != nil {
+				cc.Logger.Err(err).KV("channel", channel.Name).KV("ts", msg.Timestamp).Error("skipping message with invalid timestamp")
+				continue
+			}
+
+			if (!since.IsZero() && timestamp.Before(since)) || (!until.IsZero() && !timestamp.Before(until)) {
+				continue
+			}
+
+			read++
+			if msg.User == "" {
+				continue
+			}
+
+			recorded, err := db.HasMessage(database.MessageID(msg.Channel, msg.Timestamp))
+			if err != nil {
+				return failed(err, "could not look up recorded messages")
+			}
+			if recorded {
+				skipped++
+				continue
+			}
+
+			before := rdb.inserted
+			rdb.timestamp = timestamp
+			bot.ReplayMessage(&slack.MessageEvent{Msg: msg})
+
+			if rdb.inserted > before {
+				r.add(channel.Name, msg.Timestamp, timestamp, msg.Text)
+			}
+		}
+	}
+
+	cc.Logger.
+		KV("read", read).
+		KV("replayed", len(r.rows)).
+		KV("skipped", skipped).
+		KV("untracked", rdb.matched).
+		KV("inserted", rdb.inserted).
+		KV("dry-run", dryRun).
+		Info("replayed slack export")
+
+	return cc.print(c, r)
+}
+
+// parseTime parses an RFC 3339 timestamp or a date.
+// An empty string is the zero time.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,117 @@
+package ctlcommands
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/troyxmccall/janet/database"
+
+	"github.com/aybabtme/log"
+	"github.com/urfave/cli"
+)
+
+// newContext returns the context that a command is run with
+// when janetctl is called with flags and args.
+func newContext(t *testing.T, flags map[string]string, args ...string) *cli.Context {
+	set := flag.NewFlagSet("janetctl", flag.ContinueOnError)
+	for name, value := range flags {
+		set.String(name, value, "")
+	}
+
+	if err := set.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+
+	return cli.NewContext(nil, set, nil)
+}
+
+func newCommands() (*Commands, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	return &Commands{
+		Logger: log.KV("test", true),
+		Out:    out,
+	}, out
+}
+
+func TestReplay(t *testing.T) {
+	export := t.TempDir()
+	for name, content := range map[string]string{
+		"users.json":    `[{"id": "U100", "name": "troy"}, {"id": "U200", "name": "alex"}]`,
+		"channels.json": `[{"id": "C100", "name": "general"}]`,
+		"general/2019-01-01.json": `[
+			{"type": "message", "user": "U200", "text": "<@U100>++ recorded", "ts": "1546300800.000100"},
+			{"type": "message", "user": "U200", "text": "<@U100>++ untracked", "ts": "1546300860.000100"},
+			{"type": "message", "user": "U200", "text": "<@U100>++ missing", "ts": "1546300920.000100"}
+		]`,
+	} {
+		path := filepath.Join(export, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "db.sqlite3")
+	db, err := database.New(&database.Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first message was recorded by janet, the second one
+	// before janet kept track of messages
+	for _, record := range []*database.Record{
+		{
+			Points:    database.Points{From: "alex", To: "troy", Points: 1, Message: "C100/1546300800.000100"},
+			Timestamp: time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Points:    database.Points{From: "alex", To: "troy", Points: 1},
+			Timestamp: time.Date(2019, 1, 1, 0, 1, 0, 0, time.UTC),
+		},
+	} {
+		if err := db.InsertRecord(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	cc, out := newCommands()
+	err = cc.Replay(newContext(t, map[string]string{"db": path, "maxpoints": "6", "output": "csv"}, export))
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+
+	if got := out.String(); !strings.Contains(got, "missing") || strings.Contains(got, "recorded") || strings.Contains(got, "untracked") {
+		t.Errorf("Replay: printed %q; want only the missing message", got)
+	}
+
+	db, err = database.New(&database.Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	user, err := db.GetUser("troy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Points != 3 {
+		t.Errorf("Replay: troy has %d points; want 3", user.Points)
+	}
+
+	recorded, err := db.HasMessage("C100/1546300920.000100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !recorded {
+		t.Errorf("Replay: did not record the message of the missing karma")
+	}
+}
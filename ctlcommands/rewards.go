@@ -0,0 +1,113 @@
+package ctlcommands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/troyxmccall/janet/database"
+
+	"github.com/urfave/cli"
+)
+
+// AddReward adds a reward to the catalog, or changes
+// the cost and description of an existing one.
+func (cc *Commands) AddReward(c *cli.Context) error {
+	reward := &database.Reward{
+		Name:        strings.ToLower(c.String("name")),
+		Cost:        c.Int("cost"),
+		Description: c.String("description"),
+	}
+
+	if reward.Name == "" || strings.ContainsAny(reward.Name, " \t\n") {
+		return usageError("please pass a single word to the `name` option, e.g. coffee or day-off")
+	}
+	if reward.Cost < 1 {
+		return usageError("please pass a positive amount of points to the `cost` option")
+	}
+
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.InsertReward(reward)
+	if err != nil {
+		return failed(err, "could not add reward")
+	}
+
+	r := newResult("name", "cost", "description")
+	r.add(reward.Name, reward.Cost, reward.Description)
+
+	return cc.print(c, r)
+}
+
+func (cc *Commands) RemoveReward(c *cli.Context) error {
+	name := strings.ToLower(c.String("name"))
+	if name == "" {
+		return usageError("please pass the reward's name to the `name` option")
+	}
+
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.DeleteReward(name)
+	if err != nil {
+		return failed(err, fmt.Sprintf("could not remove reward %s", name))
+	}
+
+	r := newResult("name")
+	r.add(name)
+
+	return cc.print(c, r)
+}
+
+func (cc *Commands) ListRewards(c *cli.Context) error {
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rewards, err := db.GetRewards()
+	if err != nil {
+		return failed(err, "could not look up rewards")
+	}
+
+	r := newResult("name", "cost", "description")
+	for _, reward := range rewards {
+		r.add(reward.Name, reward.Cost, reward.Description)
+	}
+
+	return cc.print(c, r)
+}
+
+// ListRedemptions prints the redemptions of
+// one or all users, newest first.
+func (cc *Commands) ListRedemptions(c *cli.Context) error {
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	redemptions, err := db.GetRedemptions(strings.ToLower(c.String("user")), c.Int("limit"))
+	if err != nil {
+		return failed(err, "could not look up redemptions")
+	}
+
+	r := newResult("id", "user", "reward", "cost", "status", "admin", "note", "created", "decided")
+	for _, d := range redemptions {
+		var decided interface{} = ""
+		if !d.Decided.IsZero() {
+			decided = d.Decided
+		}
+
+		r.add(d.ID, d.User, d.Reward, d.Cost, d.Status, d.Admin, d.Note, d.Created, decided)
+	}
+
+	return cc.print(c, r)
+}
@@ -0,0 +1,42 @@
+package ctlcommands
+
+import (
+	"github.com/troyxmccall/janet/tui"
+
+	"github.com/urfave/cli"
+)
+
+// Tui runs the interactive terminal dashboard.
+func (cc *Commands) Tui(c *cli.Context) error {
+	var (
+		leaderboardLimit = c.Int("leaderboardlimit")
+		historyLimit     = c.Int("historylimit")
+		refresh          = c.Duration("refresh")
+	)
+
+	if leaderboardLimit < 1 {
+		return usageError("please pass a positive number to the `leaderboardlimit` option")
+	}
+
+	if refresh <= 0 {
+		return usageError("please pass a positive duration to the `refresh` option")
+	}
+
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = tui.New(&tui.Config{
+		DB:               db,
+		LeaderboardLimit: leaderboardLimit,
+		HistoryLimit:     historyLimit,
+		RefreshInterval:  refresh,
+	}).Run()
+	if err != nil {
+		return failed(err, "could not run the terminal ui")
+	}
+
+	return nil
+}
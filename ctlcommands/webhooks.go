@@ -0,0 +1,149 @@
+package ctlcommands
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/troyxmccall/janet/database"
+	"github.com/troyxmccall/janet/webhook"
+
+	"github.com/urfave/cli"
+)
+
+// AddWebhook subscribes a URL to karma events. A secret is
+// generated if none is passed, and printed so that the
+// receiver can verify the signatures.
+func (cc *Commands) AddWebhook(c *cli.Context) error {
+	hook := &database.Webhook{
+		URL:    c.String("url"),
+		Secret: c.String("secret"),
+		Events: c.StringSlice("event"),
+	}
+
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return usageError("please pass an http or https url to the `url` option")
+	}
+
+	for _, event := range hook.Events {
+		if !validEvent(event) {
+			return usageError("unknown event %q, please use %s", event, strings.Join(webhook.Events, ", "))
+		}
+	}
+
+	if hook.Secret == "" {
+		key := make([]byte, 32)
+		_, err := rand.Read(key)
+		if err != nil {
+			return failed(err, "could not generate a secret")
+		}
+		hook.Secret = hex.EncodeToString(key)
+	}
+
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.InsertWebhook(hook)
+	if err != nil {
+		return failed(err, "could not add webhook")
+	}
+
+	r := newResult("url", "secret", "events")
+	r.add(hook.URL, hook.Secret, events(hook))
+
+	return cc.print(c, r)
+}
+
+func validEvent(event string) bool {
+	for _, e := range webhook.Events {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+// events lists the events of a webhook for people.
+func events(hook *database.Webhook) string {
+	if len(hook.Events) == 0 {
+		return "all"
+	}
+
+	return strings.Join(hook.Events, ",")
+}
+
+func (cc *Commands) RemoveWebhook(c *cli.Context) error {
+	u := c.String("url")
+	if u == "" {
+		return usageError("please pass the webhook's url to the `url` option")
+	}
+
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = db.DeleteWebhook(u)
+	if err != nil {
+		return failed(err, fmt.Sprintf("could not remove webhook %s", u))
+	}
+
+	r := newResult("url")
+	r.add(u)
+
+	return cc.print(c, r)
+}
+
+func (cc *Commands) ListWebhooks(c *cli.Context) error {
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	hooks, err := db.GetWebhooks()
+	if err != nil {
+		return failed(err, "could not look up webhooks")
+	}
+
+	r := newResult("url", "events")
+	for _, hook := range hooks {
+		r.add(hook.URL, events(hook))
+	}
+
+	return cc.print(c, r)
+}
+
+// ListDeliveries prints the delivery log, newest first.
+func (cc *Commands) ListDeliveries(c *cli.Context) error {
+	db, err := cc.getDB(c)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	deliveries, err := db.GetDeliveries(c.Int("limit"))
+	if err != nil {
+		return failed(err, "could not look up deliveries")
+	}
+
+	r := newResult("id", "url", "event", "status", "attempts", "response", "error", "created", "delivered")
+	for _, d := range deliveries {
+		var delivered interface{} = ""
+		if !d.Delivered.IsZero() {
+			delivered = d.Delivered
+		}
+
+		r.add(d.ID, d.URL, d.Event, d.Status, d.Attempts, d.Response, d.Error, d.Created, delivered)
+	}
+
+	return cc.print(c, r)
+}
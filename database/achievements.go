@@ -0,0 +1,127 @@
+package database
+
+import (
+	"time"
+
+	"github.com/troyxmccall/janet/metrics"
+)
+
+// An Achievement is a badge that a user earned.
+type Achievement struct {
+	User, Badge string
+	Timestamp   time.Time
+}
+
+// UserStats are the numbers that achievements are based on.
+// Only karma given to a user by someone else counts.
+type UserStats struct {
+	// Received is the amount of karma operations
+	// that gave the user points.
+	Received int
+
+	// Streak is the amount of days in a row, up to and
+	// including today, on which the user received points.
+	Streak int
+}
+
+func (db *DB) createAchievementsTable() error {
+	_, err := db.SQL.Exec("create table if not exists achievements (`user` text not null, `badge` text not null, `timestamp` text not null default (datetime('now')), primary key (`user`, `badge`))")
+	return err
+}
+
+// InsertAchievement records that a user earned a badge and returns
+// whether they had not earned it before.
+func (db *DB) InsertAchievement(user, badge string) (bool, error) {
+	defer metrics.ObserveQuery("insert_achievement", time.Now())
+
+	res, err := db.SQL.Exec("insert or ignore into achievements (`user`, `badge`) values(?, ?)", user, badge)
+	if err != nil {
+		return false, err
+	}
+
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return inserted > 0, nil
+}
+
+// GetAchievements returns the badges that a user earned, oldest first.
+func (db *DB) GetAchievements(user string) ([]*Achievement, error) {
+	defer metrics.ObserveQuery("get_achievements", time.Now())
+
+	rows, err := db.SQL.Query("select `user`, `badge`, `timestamp` from achievements where `user` = ? order by `timestamp`, `rowid`", user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var achievements []*Achievement
+	for rows.Next() {
+		var (
+			achievement = &Achievement{}
+			timestamp   string
+		)
+
+		err := rows.Scan(&achievement.User, &achievement.Badge, &timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		achievement.Timestamp, err = time.Parse(TimestampFormat, timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		achievements = append(achievements, achievement)
+	}
+
+	return achievements, rows.Err()
+}
+
+// received restricts a query to the karma operations that gave
+// a user points, not counting karma from themselves.
+const received = "`to` = ?1 and `points` > 0 and `from` != ?1 and " + earned
+
+// GetUserStats returns the numbers that a user's achievements are
+// based on. Days start at midnight in now's time zone.
+func (db *DB) GetUserStats(user string, now time.Time) (*UserStats, error) {
+	defer metrics.ObserveQuery("get_user_stats", time.Now())
+
+	stats := &UserStats{}
+	err := db.SQL.QueryRow("select count(*) from karma where "+received, user).Scan(&stats.Received)
+	if err != nil {
+		return nil, err
+	}
+
+	// the timestamps are in UTC, the days are local
+	_, offset := now.Zone()
+	rows, err := db.SQL.Query(
+		"select distinct date(`timestamp`, ?2 || ' seconds') as `day` from karma where "+received+" order by `day` desc",
+		user, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	day := now.Format("2006-01-02")
+	for rows.Next() {
+		var d string
+		err := rows.Scan(&d)
+		if err != nil {
+			return nil, err
+		}
+
+		if d != day {
+			break
+		}
+
+		stats.Streak++
+		t, _ := time.Parse("2006-01-02", day)
+		day = t.AddDate(0, 0, -1).Format("2006-01-02")
+	}
+
+	return stats, rows.Err()
+}
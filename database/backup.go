@@ -0,0 +1,188 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/troyxmccall/janet/metrics"
+)
+
+// backupFormat is the timestamp in the names of the backups in a
+// backup directory, so that they sort chronologically.
+const backupFormat = "20060102-150405"
+
+// Backup writes a consistent copy of the database to path while it
+// is in use, using VACUUM INTO. The file must not exist yet.
+func (db *DB) Backup(ctx context.Context, path string) error {
+	defer metrics.ObserveQuery("backup", time.Now())
+
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup %s already exists", path)
+	}
+
+	// write to a temporary file first, so that an interrupted
+	// backup never looks like a complete one
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+
+	_, err := db.SQL.ExecContext(ctx, "vacuum into ?", tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+// BackupDir writes a timestamped backup into dir and then removes
+// all but the newest keep backups. keep < 1 keeps all backups.
+// It returns the path of the new backup.
+func (db *DB) BackupDir(ctx context.Context, dir string, keep int) (string, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("janet-%s.sqlite3", time.Now().UTC().Format(backupFormat)))
+	err = db.Backup(ctx, path)
+	if err != nil {
+		return "", err
+	}
+
+	if keep < 1 {
+		return path, nil
+	}
+
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return path, err
+	}
+
+	for len(backups) > keep {
+		err := os.Remove(backups[0])
+		if err != nil {
+			return path, err
+		}
+		backups = backups[1:]
+	}
+
+	return path, nil
+}
+
+// ListBackups returns the backups in dir, oldest first.
+func ListBackups(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "janet-*.sqlite3"))
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, file := range files {
+		timestamp := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "janet-"), ".sqlite3")
+		if _, err := time.Parse(backupFormat, timestamp); err == nil {
+			backups = append(backups, file)
+		}
+	}
+	sort.Strings(backups)
+
+	return backups, nil
+}
+
+// CheckIntegrity checks that the file at path is an intact
+// janet database.
+func CheckIntegrity(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+
+	sqlite, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?mode=ro", path))
+	if err != nil {
+		return err
+	}
+	defer sqlite.Close()
+
+	rows, err := sqlite.Query("pragma integrity_check")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var result string
+		err := rows.Scan(&result)
+		if err != nil {
+			return err
+		}
+
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+	}
+
+	var tables int
+	err = sqlite.QueryRow("select count(*) from sqlite_master where `type` = 'table' and `name` = 'karma'").Scan(&tables)
+	if err != nil {
+		return err
+	}
+	if tables == 0 {
+		return errors.New("database does not contain any karma")
+	}
+
+	return nil
+}
+
+// Restore replaces the database at path with a backup after checking
+// the backup's integrity. janet must not be running while restoring.
+func Restore(backup, path string) error {
+	err := CheckIntegrity(backup)
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(backup)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// copy next to the database and rename, so that the
+	// database is never left half-written
+	tmp := path + ".restore"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, src)
+	if err == nil {
+		err = dst.Sync()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		os.Remove(path + suffix)
+	}
+
+	return os.Rename(tmp, path)
+}
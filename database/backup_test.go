@@ -0,0 +1,130 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "janet.sqlite3")
+
+	db, err := New(&Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	insertRecords(t, db, &Points{From: "alex", To: "troy", Points: 5})
+
+	backup := filepath.Join(dir, "backup.sqlite3")
+	if err := db.Backup(context.Background(), backup); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if err := db.Backup(context.Background(), backup); err == nil {
+		t.Errorf("Backup: overwrote an existing backup")
+	}
+	if _, err := os.Stat(backup + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Backup: left its temporary file behind")
+	}
+
+	if err := CheckIntegrity(backup); err != nil {
+		t.Fatalf("CheckIntegrity: %v", err)
+	}
+
+	// karma given after the backup is lost by restoring
+	insertRecords(t, db, &Points{From: "alex", To: "troy", Points: 3})
+	db.Close()
+
+	if err := Restore(backup, path); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if _, err := os.Stat(path + ".restore"); !os.IsNotExist(err) {
+		t.Errorf("Restore: left its temporary file behind")
+	}
+
+	db, err = New(&Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if got := balances(t, db, "troy"); !reflect.DeepEqual(got, map[string][2]int{"troy": {5, 5}}) {
+		t.Errorf("Restore: restored %v; want troy with 5 points", got)
+	}
+}
+
+func TestBackupDir(t *testing.T) {
+	db := newTestDB(t)
+	dir := filepath.Join(t.TempDir(), "backups")
+
+	// older backups, and a file that is not a backup
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"janet-20190101-000000.sqlite3", "janet-20190102-000000.sqlite3", "janet-20190103-000000.sqlite3", "janet-latest.sqlite3"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path, err := db.BackupDir(context.Background(), dir, 2)
+	if err != nil {
+		t.Fatalf("BackupDir: %v", err)
+	}
+
+	backups, err := ListBackups(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{filepath.Join(dir, "janet-20190103-000000.sqlite3"), path}; !reflect.DeepEqual(backups, want) {
+		t.Errorf("BackupDir: kept %v; want %v", backups, want)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "janet-latest.sqlite3")); err != nil {
+		t.Errorf("BackupDir: removed a file that is not a backup: %v", err)
+	}
+	if err := CheckIntegrity(path); err != nil {
+		t.Errorf("CheckIntegrity: %v", err)
+	}
+}
+
+func TestCheckIntegrity(t *testing.T) {
+	dir := t.TempDir()
+
+	garbage := filepath.Join(dir, "garbage.sqlite3")
+	if err := ioutil.WriteFile(garbage, []byte("this is not a database, but it is long enough to look like one"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	other := filepath.Join(dir, "other.sqlite3")
+	sqlite, err := sql.Open("sqlite3", other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sqlite.Exec("create table notes (`text` text)"); err != nil {
+		t.Fatal(err)
+	}
+	sqlite.Close()
+
+	for name, path := range map[string]string{
+		"missing":     filepath.Join(dir, "missing.sqlite3"),
+		"not sqlite":  garbage,
+		"not janet's": other,
+	} {
+		if err := CheckIntegrity(path); err == nil {
+			t.Errorf("%s: CheckIntegrity returned nil; want an error", name)
+		}
+
+		target := filepath.Join(dir, "janet.sqlite3")
+		if err := Restore(path, target); err == nil {
+			t.Errorf("%s: Restore returned nil; want an error", name)
+		}
+		if _, err := os.Stat(target); !os.IsNotExist(err) {
+			t.Errorf("%s: Restore wrote the database", name)
+		}
+	}
+}
@@ -1,11 +1,15 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
+	"github.com/troyxmccall/janet/metrics"
+
 	"github.com/aybabtme/log"
 
 	// import the sqlite3 driver
@@ -30,6 +34,23 @@ type DB struct {
 type Points struct {
 	From, To, Reason string
 	Points           int
+
+	// Message identifies the Slack message that the karma
+	// operation came from, if any. See MessageID.
+	Message string
+
+	// Type is the type of the record, TypeEarned if empty.
+	Type string
+}
+
+// MessageID returns the identifier of a Slack message
+// that is stored with its karma operations.
+func MessageID(channel, timestamp string) string {
+	if timestamp == "" {
+		return ""
+	}
+
+	return channel + "/" + timestamp
 }
 
 // Throwback is a karma operation that has happened
@@ -39,19 +60,60 @@ type Throwback struct {
 	Timestamp time.Time
 }
 
+// A Record is a karma operation together with the
+// time at which it happened.
+type Record struct {
+	Points
+
+	Timestamp time.Time
+}
+
+// An ImportSummary describes the changes made by ImportRecords.
+type ImportSummary struct {
+	Read, Inserted, Skipped int
+
+	// Points contains the change in points per user.
+	Points map[string]int
+}
+
 // The Leaderboard lists the top X users.
 type Leaderboard []*User
 
 // A User is an entry in the Leaderboard.
 type User struct {
-	Name   string
+	Name string
+
+	// Points is the user's lifetime karma, which includes
+	// admin adjustments and migrations but is not reduced
+	// by spending.
 	Points int
+
+	// Balance is the karma that the user can spend.
+	// It is only set by GetUser.
+	Balance int
+}
+
+// An Alias maps an alternative username to a user.
+type Alias struct {
+	Alias, User string
 }
 
+// TimestampFormat is the format in which sqlite
+// stores karma timestamps, always in UTC.
+const TimestampFormat = "2006-01-02 15:04:05"
+
 // ErrNoSuchUser is returned when a user lookup
 // is performed on a non-existent user
 var ErrNoSuchUser = errors.New("no such user")
 
+// ErrNoSuchAlias is returned when an alias lookup
+// is performed on a non-existent alias
+var ErrNoSuchAlias = errors.New("no such alias")
+
+// ErrNotBlacklisted is returned when removing a user
+// that is not blacklisted from the blacklist
+var ErrNotBlacklisted = errors.New("user is not blacklisted")
+
 // New returns a new instance of a janet database
 // and initializes it
 func New(config *Config) (*DB, error) {
@@ -103,37 +165,150 @@ func (db *DB) createTable() error {
 		return err
 	}
 
-	return nil
+	err = db.addColumn("karma", "message", "text")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.SQL.Exec("create index if not exists idx_message on karma(`message`);")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.SQL.Exec("create table if not exists aliases (`alias` text primary key, `user` text not null)")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.SQL.Exec("create table if not exists blacklist (`user` text primary key)")
+	if err != nil {
+		return err
+	}
+
+	err = db.createOperationsTable()
+	if err != nil {
+		return err
+	}
+
+	err = db.createLedger()
+	if err != nil {
+		return err
+	}
+
+	err = db.createWebhooksTables()
+	if err != nil {
+		return err
+	}
+
+	err = db.createAchievementsTable()
+	if err != nil {
+		return err
+	}
+
+	return db.createRewardsTables()
+}
+
+// addColumn adds a column to a table that was created
+// by an older version of janet.
+func (db *DB) addColumn(table, column, definition string) error {
+	rows, err := db.SQL.Query("select `name` from pragma_table_info(?)", table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		err := rows.Scan(&name)
+		if err != nil {
+			return err
+		}
+
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.SQL.Exec(fmt.Sprintf("alter table `%s` add column `%s` %s", table, column, definition))
+
+	return err
 }
 
 // InsertPoints inserts a Points object into the database.
 func (db *DB) InsertPoints(points *Points) error {
-	stmt, err := db.SQL.Prepare("insert into karma (`from`, `to`, `reason`, `points`) values(?, ?, ?, ?)")
+	defer metrics.ObserveQuery("insert_points", time.Now())
+
+	stmt, err := db.SQL.Prepare("insert into karma (`from`, `to`, `reason`, `points`, `message`, `type`) values(?, ?, ?, ?, nullif(?, ''), ?)")
 
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(points.From, points.To, points.Reason, points.Points)
+	_, err = stmt.Exec(points.From, points.To, points.Reason, points.Points, points.Message, recordType(points))
 
 	return err
 }
 
-// GetUser returns info about a user.
-func (db *DB) GetUser(name string) (*User, error) {
-	stmt, err := db.SQL.Prepare("select count(`to`) as `count` from karma where `to` = ?")
+// InsertRecord inserts a karma operation with its original timestamp.
+func (db *DB) InsertRecord(record *Record) error {
+	defer metrics.ObserveQuery("insert_record", time.Now())
+
+	_, err := db.SQL.Exec(
+		"insert into karma (`from`, `to`, `reason`, `points`, `message`, `type`, `timestamp`) values(?, ?, ?, ?, nullif(?, ''), ?, ?)",
+		record.From, record.To, record.Reason, record.Points.Points, record.Message, recordType(&record.Points),
+		record.Timestamp.UTC().Format(TimestampFormat),
+	)
+
+	return err
+}
+
+// HasMessage checks whether any karma operations
+// from a Slack message have been recorded.
+func (db *DB) HasMessage(message string) (bool, error) {
+	defer metrics.ObserveQuery("has_message", time.Now())
+
+	var count int
+	err := db.SQL.QueryRow("select count(*) from karma where `message` = ?", message).Scan(&count)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	defer stmt.Close()
+
+	return count > 0, nil
+}
+
+// CountUntracked counts the karma operations without a Slack message
+// that gave a user points at the same second as timestamp. These are
+// operations recorded before janet kept track of messages.
+func (db *DB) CountUntracked(to string, points int, timestamp time.Time) (int, error) {
+	defer metrics.ObserveQuery("count_untracked", time.Now())
+
+	var count int
+	err := db.SQL.QueryRow(
+		"select count(*) from karma where `message` is null and `to` = ? and `points` = ? and `timestamp` = ?",
+		to, points, timestamp.UTC().Format(TimestampFormat),
+	).Scan(&count)
+
+	return count, err
+}
+
+// GetUser returns info about a user, including
+// their lifetime karma and their balance.
+func (db *DB) GetUser(name string) (*User, error) {
+	defer metrics.ObserveQuery("get_user", time.Now())
 
 	user := &User{
 		Name: name,
 	}
 
 	var userExists int
-	err = stmt.QueryRow(user.Name).Scan(&userExists)
+	err := db.SQL.QueryRow(
+		"select count(*), coalesce(sum(case when "+lifetime+" then `points` else 0 end), 0), coalesce(sum(`points`), 0) from karma where `to` = ?",
+		user.Name,
+	).Scan(&userExists, &user.Points, &user.Balance)
 	if err != nil {
 		return nil, err
 	}
@@ -141,23 +316,28 @@ func (db *DB) GetUser(name string) (*User, error) {
 		return nil, ErrNoSuchUser
 	}
 
-	stmt, err = db.SQL.Prepare("select sum(`points`) as `points` from karma where `to` = ?")
-	if err != nil {
-		return nil, err
-	}
-	defer stmt.Close()
+	return user, nil
+}
 
-	err = stmt.QueryRow(user.Name).Scan(&user.Points)
-	if err != nil {
-		return nil, err
-	}
+// querier is implemented by sql.DB and sql.Tx.
+type querier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
 
-	return user, nil
+// getBalance returns the karma that a user can spend,
+// which is 0 for users that do not exist.
+func getBalance(q querier, user string) (int, error) {
+	var balance int
+	err := q.QueryRow("select coalesce(sum(`points`), 0) from karma where `to` = ?", user).Scan(&balance)
+
+	return balance, err
 }
 
 // GetLeaderboard returns the leaderboard with the top X users.
 func (db *DB) GetLeaderboard(limit int) (Leaderboard, error) {
-	rows, err := db.SQL.Query("select `to`, sum(`points`) as `points` from karma group by `to` order by `points` desc limit ?", limit)
+	defer metrics.ObserveQuery("get_leaderboard", time.Now())
+
+	rows, err := db.SQL.Query("select `to`, sum(`points`) as `points` from karma where "+lifetime+" group by `to` order by `points` desc limit ?", limit)
 	if err != nil {
 		return nil, err
 	}
@@ -178,11 +358,24 @@ func (db *DB) GetLeaderboard(limit int) (Leaderboard, error) {
 	return leaderboard, nil
 }
 
+// GetBottom returns the users who had the most karma taken from
+// them by other users, with the amount of points taken.
+func (db *DB) GetBottom(limit int) (Leaderboard, error) {
+	defer metrics.ObserveQuery("get_bottom", time.Now())
+
+	return db.queryLeaderboard(
+		"select `to`, -sum(`points`) as `taken` from karma where `points` < 0 and "+earned+" group by `to` order by `taken` desc, `to` limit ?",
+		limit,
+	)
+}
+
 // GetTotalPoints returns the amount of points given or taken
-// for all users.
+// for all users, not counting spent points.
 func (db *DB) GetTotalPoints() (int, error) {
+	defer metrics.ObserveQuery("get_total_points", time.Now())
+
 	var res int
-	err := db.SQL.QueryRow("select sum(abs(`points`)) from karma").Scan(&res)
+	err := db.SQL.QueryRow("select coalesce(sum(abs(`points`)), 0) from karma where " + lifetime).Scan(&res)
 
 	if err != nil {
 		return 0, err
@@ -193,12 +386,14 @@ func (db *DB) GetTotalPoints() (int, error) {
 
 // GetThrowback returns a random karma operation on a specific user
 func (db *DB) GetThrowback(user string) (*Throwback, error) {
+	defer metrics.ObserveQuery("get_throwback", time.Now())
+
 	var (
 		record    = &Throwback{}
 		timestamp = ""
 	)
 
-	err := db.SQL.QueryRow("select `from`, `to`, `reason`, `points`, `timestamp` from karma where `to` = ? and `id` >= (abs(random()) % (select max(`id`) from karma)) limit 1", user).Scan(&record.From, &record.To, &record.Reason, &record.Points.Points, &timestamp)
+	err := db.SQL.QueryRow("select `from`, `to`, `reason`, `points`, `type`, `timestamp` from karma where `to` = ? and `id` >= (abs(random()) % (select max(`id`) from karma)) limit 1", user).Scan(&record.From, &record.To, &record.Reason, &record.Points.Points, &record.Type, &timestamp)
 	switch err {
 	case nil:
 	case sql.ErrNoRows:
@@ -207,10 +402,343 @@ func (db *DB) GetThrowback(user string) (*Throwback, error) {
 		return nil, err
 	}
 
-	record.Timestamp, err = time.Parse("2006-01-02 15:04:05", timestamp)
+	record.Timestamp, err = time.Parse(TimestampFormat, timestamp)
 	if err != nil {
 		return nil, err
 	}
 
 	return record, nil
 }
+
+// InsertAlias aliases a username to a user, replacing
+// any existing alias with the same name.
+func (db *DB) InsertAlias(alias, user string) error {
+	defer metrics.ObserveQuery("insert_alias", time.Now())
+
+	_, err := db.SQL.Exec("insert or replace into aliases (`alias`, `user`) values(?, ?)", alias, user)
+
+	return err
+}
+
+// DeleteAlias removes an alias.
+func (db *DB) DeleteAlias(alias string) error {
+	defer metrics.ObserveQuery("delete_alias", time.Now())
+
+	res, err := db.SQL.Exec("delete from aliases where `alias` = ?", alias)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNoSuchAlias
+	}
+
+	return nil
+}
+
+// GetAlias returns the user that an alias belongs to.
+func (db *DB) GetAlias(alias string) (string, error) {
+	defer metrics.ObserveQuery("get_alias", time.Now())
+
+	var user string
+	err := db.SQL.QueryRow("select `user` from aliases where `alias` = ?", alias).Scan(&user)
+	switch err {
+	case nil:
+		return user, nil
+	case sql.ErrNoRows:
+		return "", ErrNoSuchAlias
+	default:
+		return "", err
+	}
+}
+
+// GetAliases returns all aliases, ordered by user.
+func (db *DB) GetAliases() ([]*Alias, error) {
+	defer metrics.ObserveQuery("get_aliases", time.Now())
+
+	rows, err := db.SQL.Query("select `alias`, `user` from aliases order by `user`, `alias`")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var aliases []*Alias
+	for rows.Next() {
+		alias := &Alias{}
+		err := rows.Scan(&alias.Alias, &alias.User)
+
+		if err != nil {
+			return nil, err
+		}
+
+		aliases = append(aliases, alias)
+	}
+
+	return aliases, rows.Err()
+}
+
+// InsertBlacklist blacklists a user.
+func (db *DB) InsertBlacklist(user string) error {
+	defer metrics.ObserveQuery("insert_blacklist", time.Now())
+
+	_, err := db.SQL.Exec("insert or ignore into blacklist (`user`) values(?)", user)
+
+	return err
+}
+
+// DeleteBlacklist removes a user from the blacklist.
+func (db *DB) DeleteBlacklist(user string) error {
+	defer metrics.ObserveQuery("delete_blacklist", time.Now())
+
+	res, err := db.SQL.Exec("delete from blacklist where `user` = ?", user)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNotBlacklisted
+	}
+
+	return nil
+}
+
+// IsBlacklisted checks whether a user is blacklisted.
+func (db *DB) IsBlacklisted(user string) (bool, error) {
+	defer metrics.ObserveQuery("is_blacklisted", time.Now())
+
+	var count int
+	err := db.SQL.QueryRow("select count(*) from blacklist where `user` = ?", user).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// GetBlacklist returns all blacklisted users in
+// alphabetical order.
+func (db *DB) GetBlacklist() ([]string, error) {
+	defer metrics.ObserveQuery("get_blacklist", time.Now())
+
+	rows, err := db.SQL.Query("select `user` from blacklist order by `user`")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []string
+	for rows.Next() {
+		var user string
+		err := rows.Scan(&user)
+
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// GetRecords returns all karma operations, oldest first.
+func (db *DB) GetRecords() ([]*Record, error) {
+	defer metrics.ObserveQuery("get_records", time.Now())
+
+	rows, err := db.SQL.Query("select `from`, `to`, coalesce(`reason`, ''), `points`, `type`, coalesce(`message`, ''), `timestamp` from karma order by `timestamp`, `id`")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRecords(rows)
+}
+
+// scanRecords reads karma records from rows of `from`, `to`,
+// `reason`, `points`, `type`, `message` and `timestamp`.
+func scanRecords(rows *sql.Rows) ([]*Record, error) {
+	var records []*Record
+	for rows.Next() {
+		var (
+			record    = &Record{}
+			timestamp string
+		)
+
+		err := rows.Scan(&record.From, &record.To, &record.Reason, &record.Points.Points, &record.Type, &record.Message, &timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		record.Timestamp, err = time.Parse(TimestampFormat, timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
+
+// GetHistory returns the karma operations that a user gave or
+// received, newest first. limit < 1 returns all operations.
+func (db *DB) GetHistory(user string, limit int) ([]*Record, error) {
+	defer metrics.ObserveQuery("get_history", time.Now())
+
+	if limit < 1 {
+		limit = -1
+	}
+
+	rows, err := db.SQL.Query("select `from`, `to`, coalesce(`reason`, ''), `points`, `type`, coalesce(`message`, ''), `timestamp` from karma where `to` = ? or `from` = ? order by `timestamp` desc, `id` desc limit ?", user, user, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRecords(rows)
+}
+
+// SearchRecords returns the karma operations whose giver, receiver
+// or reason contain query, newest first. limit < 1 returns all
+// matching operations.
+func (db *DB) SearchRecords(query string, limit int) ([]*Record, error) {
+	defer metrics.ObserveQuery("search_records", time.Now())
+
+	if limit < 1 {
+		limit = -1
+	}
+
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(query) + "%"
+	rows, err := db.SQL.Query("select `from`, `to`, coalesce(`reason`, ''), `points`, `type`, coalesce(`message`, ''), `timestamp` from karma where `from` like ?1 escape '\\' or `to` like ?1 escape '\\' or `reason` like ?1 escape '\\' order by `timestamp` desc, `id` desc limit ?2", pattern, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRecords(rows)
+}
+
+// ImportRecords inserts karma operations with their original
+// timestamps in a single transaction. Records that already exist
+// are skipped, so importing the same records twice is a no-op.
+// Identical records are counted, so that operations which happened
+// more than once in the same second are all kept. Records are
+// compared by giver, receiver, reason, points and timestamp, but
+// not by type or message, which older records do not have.
+// Inserted records without a type are classified like the records
+// of older versions of janet. If dryRun is set, the transaction is
+// rolled back and only the summary is returned.
+func (db *DB) ImportRecords(records []*Record, dryRun bool) (*ImportSummary, error) {
+	defer metrics.ObserveQuery("import_records", time.Now())
+
+	tx, err := db.SQL.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	count, err := tx.Prepare("select count(*) from karma where `from` = ? and `to` = ? and coalesce(`reason`, '') = ? and `points` = ? and `timestamp` = ?")
+	if err != nil {
+		return nil, err
+	}
+	defer count.Close()
+
+	insert, err := tx.Prepare("insert into karma (`from`, `to`, `reason`, `points`, `message`, `type`, `timestamp`) values(?, ?, ?, ?, nullif(?, ''), nullif(?, ''), ?)")
+	if err != nil {
+		return nil, err
+	}
+	defer insert.Close()
+
+	var (
+		summary = &ImportSummary{
+			Points: make(map[string]int),
+		}
+		existing = make(map[Record]int)
+		seen     = make(map[Record]int)
+	)
+
+	for _, record := range records {
+		summary.Read++
+
+		// the key holds the columns that the count query matches
+		key := Record{
+			Points: Points{
+				From:   record.From,
+				To:     record.To,
+				Reason: record.Reason,
+				Points: record.Points.Points,
+			},
+			Timestamp: record.Timestamp.UTC().Truncate(time.Second),
+		}
+		timestamp := key.Timestamp.Format(TimestampFormat)
+
+		if _, ok := existing[key]; !ok {
+			var n int
+			err := count.QueryRow(key.From, key.To, key.Reason, key.Points.Points, timestamp).Scan(&n)
+			if err != nil {
+				return nil, err
+			}
+			existing[key] = n
+		}
+
+		seen[key]++
+		if seen[key] <= existing[key] {
+			summary.Skipped++
+			continue
+		}
+
+		_, err := insert.Exec(key.From, key.To, key.Reason, key.Points.Points, record.Message, record.Type, timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		summary.Inserted++
+		summary.Points[key.To] += key.Points.Points
+	}
+
+	if dryRun {
+		return summary, nil
+	}
+
+	_, err = tx.Exec(classifyRecords)
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, tx.Commit()
+}
+
+// Ping checks that the database is reachable and writable.
+func (db *DB) Ping(ctx context.Context) error {
+	defer metrics.ObserveQuery("ping", time.Now())
+
+	err := db.SQL.PingContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// this does not change anything but makes sqlite acquire a
+	// write lock, which fails if the file is not writable
+	_, err = tx.ExecContext(ctx, "delete from karma where 0")
+
+	return err
+}
+
+// Close closes the underlying sqlite3 database.
+func (db *DB) Close() error {
+	return db.SQL.Close()
+}
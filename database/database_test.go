@@ -0,0 +1,46 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestDB returns a new database in a temporary file.
+func newTestDB(t *testing.T) *DB {
+	db, err := New(&Config{Path: filepath.Join(t.TempDir(), "janet.sqlite3")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// insertRecords inserts records given by janet with the given types.
+func insertRecords(t *testing.T, db *DB, records ...*Points) {
+	for _, record := range records {
+		err := db.InsertRecord(&Record{Points: *record, Timestamp: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// balances returns the lifetime karma and the balance of users.
+func balances(t *testing.T, db *DB, names ...string) map[string][2]int {
+	got := make(map[string][2]int)
+	for _, name := range names {
+		user, err := db.GetUser(name)
+		if err == ErrNoSuchUser {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got[name] = [2]int{user.Points, user.Balance}
+	}
+
+	return got
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"time"
+
+	"github.com/troyxmccall/janet/metrics"
+)
+
+// A Digest summarizes the karma operations in a time window.
+// Only karma given or taken by users counts; e.g. resets,
+// migrations and spent points are left out.
+type Digest struct {
+	Since, Until time.Time
+
+	// Movers are the users whose karma changed the most,
+	// up or down. Their points are the net change.
+	Movers Leaderboard
+
+	// Gainers are the users who gained the most karma.
+	Gainers Leaderboard
+
+	// Givers are the users who gave others the most karma.
+	Givers Leaderboard
+
+	// Points is the amount of points given or taken
+	// in Operations karma operations.
+	Points, Operations int
+}
+
+// window restricts a query to the karma given or
+// taken by users in [since, until).
+const window = "`timestamp` >= ? and `timestamp` < ? and " + earned
+
+// GetDigest returns a digest of the karma operations in [since,
+// until), listing up to limit users in each of its leaderboards.
+func (db *DB) GetDigest(since, until time.Time, limit int) (*Digest, error) {
+	defer metrics.ObserveQuery("get_digest", time.Now())
+
+	var (
+		digest = &Digest{
+			Since: since,
+			Until: until,
+		}
+		err error
+	)
+
+	digest.Movers, err = db.GetMovers(since, until, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	digest.Gainers, err = db.GetGainers(since, until, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	digest.Givers, err = db.GetGivers(since, until, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.SQL.QueryRow(
+		"select coalesce(sum(abs(`points`)), 0), count(*) from karma where "+window,
+		timestamps(since, until)...,
+	).Scan(&digest.Points, &digest.Operations)
+	if err != nil {
+		return nil, err
+	}
+
+	return digest, nil
+}
+
+// GetMovers returns the users whose karma changed the most in
+// [since, until), up or down, with their net change.
+func (db *DB) GetMovers(since, until time.Time, limit int) (Leaderboard, error) {
+	defer metrics.ObserveQuery("get_movers", time.Now())
+
+	return db.queryLeaderboard(
+		"select `to`, sum(`points`) as `change` from karma where "+window+" group by `to` having `change` != 0 order by abs(`change`) desc, `to` limit ?",
+		append(timestamps(since, until), limit)...,
+	)
+}
+
+// GetGainers returns the users who gained the
+// most karma in [since, until).
+func (db *DB) GetGainers(since, until time.Time, limit int) (Leaderboard, error) {
+	defer metrics.ObserveQuery("get_gainers", time.Now())
+
+	return db.queryLeaderboard(
+		"select `to`, sum(`points`) as `change` from karma where "+window+" group by `to` having `change` > 0 order by `change` desc, `to` limit ?",
+		append(timestamps(since, until), limit)...,
+	)
+}
+
+// GetGivers returns the users who gave others the most karma
+// in [since, until). Taking karma does not count.
+func (db *DB) GetGivers(since, until time.Time, limit int) (Leaderboard, error) {
+	defer metrics.ObserveQuery("get_givers", time.Now())
+
+	return db.queryLeaderboard(
+		"select `from`, sum(`points`) as `given` from karma where "+window+" and `points` > 0 and `from` != `to` group by `from` order by `given` desc, `from` limit ?",
+		append(timestamps(since, until), limit)...,
+	)
+}
+
+func (db *DB) queryLeaderboard(query string, args ...interface{}) (Leaderboard, error) {
+	rows, err := db.SQL.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var leaderboard Leaderboard
+	for rows.Next() {
+		user := &User{}
+		err := rows.Scan(&user.Name, &user.Points)
+		if err != nil {
+			return nil, err
+		}
+
+		leaderboard = append(leaderboard, user)
+	}
+
+	return leaderboard, rows.Err()
+}
+
+// timestamps formats the bounds of a time window
+// the way sqlite stores karma timestamps.
+func timestamps(since, until time.Time) []interface{} {
+	return []interface{}{
+		since.UTC().Format(TimestampFormat),
+		until.UTC().Format(TimestampFormat),
+	}
+}
@@ -0,0 +1,66 @@
+package database
+
+// The types of karma records. Together, the records are a ledger:
+// a user's balance is the sum of all of their records, while their
+// lifetime karma leaves out what they spent and transferred.
+const (
+	// TypeEarned records karma given or taken by users.
+	TypeEarned = "earned"
+
+	// TypeSpent records points spent on a reward,
+	// or refunded when a redemption is denied.
+	TypeSpent = "spent"
+
+	// TypeAdjustment records an admin changing a user's
+	// karma, e.g. with a reset, set or revoke.
+	TypeAdjustment = "adjustment"
+
+	// TypeMigration records karma moved from one user to another.
+	TypeMigration = "migration"
+
+	// TypeTransfer records points that a user gave another
+	// user from their balance.
+	TypeTransfer = "transfer"
+)
+
+// lifetime restricts a query to the records
+// that count towards lifetime karma.
+const lifetime = "`type` not in ('" + TypeSpent + "', '" + TypeTransfer + "')"
+
+// earned restricts a query to karma given or taken by users.
+const earned = "`type` = '" + TypeEarned + "'"
+
+// recordType returns the type of a record that is inserted.
+func recordType(points *Points) string {
+	if points.Type == "" {
+		return TypeEarned
+	}
+
+	return points.Type
+}
+
+// classifyRecords sets the type of the records that do not have
+// one yet: records of older versions of janet and imported ones.
+// Records of admin operations get the type of the operation, or of
+// the operation that a rollback reverts. Records given by janet
+// without an operation are recognized by their reasons.
+const classifyRecords = "update karma set `type` = case" +
+	" when `operation` is not null then coalesce((" +
+	"select case when o.`kind` = 'migrate' or r.`kind` = 'migrate' then 'migration' else 'adjustment' end" +
+	" from operations as o left join operations as r on r.`id` = o.`reverts` where o.`id` = karma.`operation`" +
+	"), 'adjustment')" +
+	" when `from` = 'janet' and (`reason` like 'redeeming %' or `reason` like 'refunding %') then 'spent'" +
+	" when `from` = 'janet' and `reason` like 'migrating karma from %' then 'migration'" +
+	" when `from` = 'janet' then 'adjustment'" +
+	" else 'earned' end" +
+	" where `type` is null"
+
+func (db *DB) createLedger() error {
+	err := db.addColumn("karma", "type", "text")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.SQL.Exec(classifyRecords)
+	return err
+}
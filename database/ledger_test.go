@@ -0,0 +1,246 @@
+package database
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClassifyRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "janet.sqlite3")
+
+	// the schema and records of a database written before
+	// records had a type
+	old, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, stmt := range []string{
+		"create table karma (`id` integer primary key, `from` text not null, `to` text not null, `points` integer not null, `reason` text, `timestamp` text not null default (datetime('now')), `message` text, `operation` integer)",
+		"create table operations (`id` integer primary key, `kind` text not null, `reverts` integer, `timestamp` text not null default (datetime('now')))",
+		"insert into operations (`id`, `kind`, `reverts`) values (1, 'migrate', null), (2, 'rollback', 1), (3, 'reset', null), (4, 'rollback', 3)",
+		"insert into karma (`id`, `from`, `to`, `points`, `reason`, `operation`) values" +
+			" (1, 'alex', 'troy', 1, 'for the review', null)," +
+			" (2, 'janet', 'troy', -5, 'redeeming coffee', null)," +
+			" (3, 'janet', 'troy', 5, 'refunding coffee', null)," +
+			" (4, 'janet', 'alex', 3, 'migrating karma from bob to alex', null)," +
+			" (5, 'janet', 'troy', 2, 'overriding karma', null)," +
+			" (6, 'janet', 'bob', -3, 'moving on', 1)," +
+			" (7, 'janet', 'alex', 3, 'moving on', 1)," +
+			" (8, 'janet', 'bob', 3, 'rolling back migrate #1', 2)," +
+			" (9, 'janet', 'troy', -1, 'resetting karma', 3)," +
+			" (10, 'janet', 'troy', 1, 'rolling back reset #3', 4)," +
+			" (11, 'janet', 'troy', 1, 'lost operation', 99)," +
+			" (12, 'alex', 'troy', 1, null, null)",
+	} {
+		if _, err := old.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old.Close()
+
+	db, err := New(&Config{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.SQL.Query("select `type` from karma order by `id`")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var types []string
+	for rows.Next() {
+		var kind sql.NullString
+		if err := rows.Scan(&kind); err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, kind.String)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		TypeEarned,
+		TypeSpent,
+		TypeSpent,
+		TypeMigration,
+		TypeAdjustment,
+		TypeMigration,
+		TypeMigration,
+		TypeMigration,
+		TypeAdjustment,
+		TypeAdjustment,
+		TypeAdjustment,
+		TypeEarned,
+	}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("classified records as %v; want %v", types, want)
+	}
+}
+
+func TestImportRecordsClassifiesAndKeepsMessages(t *testing.T) {
+	db := newTestDB(t)
+	timestamp := time.Date(2019, 1, 14, 9, 0, 0, 0, time.UTC)
+
+	_, err := db.ImportRecords([]*Record{
+		{Points: Points{From: "alex", To: "troy", Points: 2, Message: "C0123/1547456400.000100"}, Timestamp: timestamp},
+		{Points: Points{From: "janet", To: "troy", Points: -1, Reason: "redeeming coffee"}, Timestamp: timestamp},
+		{Points: Points{From: "troy", To: "alex", Points: 1, Type: TypeTransfer}, Timestamp: timestamp},
+	}, false)
+	if err != nil {
+		t.Fatalf("ImportRecords: %v", err)
+	}
+
+	records, err := db.GetRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Points
+	for _, record := range records {
+		got = append(got, record.Points)
+	}
+
+	want := []Points{
+		{From: "alex", To: "troy", Points: 2, Type: TypeEarned, Message: "C0123/1547456400.000100"},
+		{From: "janet", To: "troy", Points: -1, Reason: "redeeming coffee", Type: TypeSpent},
+		{From: "troy", To: "alex", Points: 1, Type: TypeTransfer},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("imported %+v; want %+v", got, want)
+	}
+}
+
+func TestImportRecordsSkipsExisting(t *testing.T) {
+	timestamp := time.Date(2019, 1, 14, 9, 0, 0, 0, time.UTC)
+	record := func(points int, kind, message string) *Record {
+		return &Record{
+			Points:    Points{From: "alex", To: "troy", Points: points, Reason: "for the review", Type: kind, Message: message},
+			Timestamp: timestamp,
+		}
+	}
+
+	for _, tc := range []struct {
+		name               string
+		existing, imported []*Record
+		inserted, skipped  int
+	}{
+		{
+			name:     "new records",
+			imported: []*Record{record(1, "", ""), record(2, "", "")},
+			inserted: 2,
+		},
+		{
+			name:     "importing twice",
+			existing: []*Record{record(1, "", ""), record(2, "", "")},
+			imported: []*Record{record(1, "", ""), record(2, "", "")},
+			skipped:  2,
+		},
+		{
+			name:     "identical records in the same second",
+			existing: []*Record{record(1, "", "")},
+			imported: []*Record{record(1, "", ""), record(1, "", "")},
+			inserted: 1,
+			skipped:  1,
+		},
+		{
+			name:     "records that only differ in type and message",
+			existing: []*Record{record(1, "", "")},
+			imported: []*Record{record(1, TypeEarned, "C0123/1547456400.000100"), record(1, TypeEarned, "C0123/1547456400.000200")},
+			inserted: 1,
+			skipped:  1,
+		},
+		{
+			name:     "exported records of a classified database",
+			existing: []*Record{record(1, "", "C0123/1547456400.000100")},
+			imported: []*Record{record(1, TypeEarned, "C0123/1547456400.000100")},
+			skipped:  1,
+		},
+	} {
+		db := newTestDB(t)
+		if _, err := db.ImportRecords(tc.existing, false); err != nil {
+			t.Fatal(err)
+		}
+
+		summary, err := db.ImportRecords(tc.imported, false)
+		if err != nil {
+			t.Errorf("%s: ImportRecords: %v", tc.name, err)
+			continue
+		}
+
+		if summary.Inserted != tc.inserted || summary.Skipped != tc.skipped {
+			t.Errorf("%s: inserted %d and skipped %d records; want %d and %d", tc.name, summary.Inserted, summary.Skipped, tc.inserted, tc.skipped)
+		}
+
+		records, err := db.GetRecords()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := len(tc.existing) + tc.inserted; len(records) != want {
+			t.Errorf("%s: database has %d records; want %d", tc.name, len(records), want)
+		}
+	}
+}
+
+func TestLifetimeAndEarned(t *testing.T) {
+	db := newTestDB(t)
+	insertRecords(t, db,
+		&Points{From: "alex", To: "troy", Points: 10},
+		&Points{From: "alex", To: "troy", Points: -2},
+		&Points{From: "janet", To: "troy", Points: -3, Type: TypeAdjustment},
+		&Points{From: "janet", To: "troy", Points: -4, Reason: "redeeming coffee", Type: TypeSpent},
+		&Points{From: "troy", To: "troy", Points: -5, Type: TypeTransfer},
+		&Points{From: "troy", To: "alex", Points: 5, Type: TypeTransfer},
+		&Points{From: "janet", To: "alex", Points: 1, Type: TypeMigration},
+	)
+
+	troy, err := db.GetUser("troy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if troy.Points != 5 || troy.Balance != -4 {
+		t.Errorf("GetUser: troy has %d points and a balance of %d; want 5 and -4", troy.Points, troy.Balance)
+	}
+
+	alex, err := db.GetUser("alex")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alex.Points != 1 || alex.Balance != 6 {
+		t.Errorf("GetUser: alex has %d points and a balance of %d; want 1 and 6", alex.Points, alex.Balance)
+	}
+
+	leaderboard, err := db.GetLeaderboard(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Leaderboard{{Name: "troy", Points: 5}, {Name: "alex", Points: 1}}
+	if !reflect.DeepEqual(leaderboard, want) {
+		t.Errorf("GetLeaderboard: returned %+v; want %+v", leaderboard, want)
+	}
+
+	// only karma taken by users counts, not adjustments or spending
+	bottom, err := db.GetBottom(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = Leaderboard{{Name: "troy", Points: 2}}
+	if !reflect.DeepEqual(bottom, want) {
+		t.Errorf("GetBottom: returned %+v; want %+v", bottom, want)
+	}
+
+	total, err := db.GetTotalPoints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 16 {
+		t.Errorf("GetTotalPoints: returned %d; want 16", total)
+	}
+}
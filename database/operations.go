@@ -0,0 +1,284 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/troyxmccall/janet/metrics"
+)
+
+// The kinds of admin operations.
+const (
+	OperationMigrate  = "migrate"
+	OperationReset    = "reset"
+	OperationSet      = "set"
+	OperationRevoke   = "revoke"
+	OperationRollback = "rollback"
+)
+
+// An Operation is an admin change to the karma of one or more users,
+// made of compensating records given by janet. Operations are planned
+// first, so that their records can be reviewed, and then applied as a
+// whole, so that they can be rolled back as a whole.
+type Operation struct {
+	ID      int64
+	Kind    string
+	Records []*Points
+
+	// Reverts is the ID of the operation that a rollback undoes.
+	Reverts int64
+}
+
+// ErrNoChange is returned when planning an operation
+// that would not change anyone's karma.
+var ErrNoChange = errors.New("operation does not change any karma")
+
+// ErrNoOperation is returned when there is no
+// operation left to roll back.
+var ErrNoOperation = errors.New("no operation to roll back")
+
+func (db *DB) createOperationsTable() error {
+	_, err := db.SQL.Exec("create table if not exists operations (`id` integer primary key, `kind` text not null, `reverts` integer, `timestamp` text not null default (datetime('now')))")
+	if err != nil {
+		return err
+	}
+
+	err = db.addColumn("karma", "operation", "integer")
+	if err != nil {
+		return err
+	}
+
+	_, err = db.SQL.Exec("create index if not exists idx_operation on karma(`operation`);")
+	return err
+}
+
+// PlanMigrate plans moving all of from's karma to another user,
+// who does not need to have any karma yet. An empty reason is
+// replaced by one naming both users. The lifetime karma is moved
+// first, followed by the points that from spent and transferred, if
+// any, each with its own type, so that both the lifetime karma and
+// the balance move.
+func (db *DB) PlanMigrate(from, to, reason string) (*Operation, error) {
+	if from == to {
+		return nil, ErrNoChange
+	}
+
+	user, err := db.GetUser(from)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Points == 0 && user.Balance == 0 {
+		return nil, ErrNoChange
+	}
+
+	if reason == "" {
+		reason = fmt.Sprintf("migrating karma from %s to %s", from, to)
+	}
+
+	op := &Operation{
+		Kind: OperationMigrate,
+		Records: []*Points{
+			{From: "janet", To: from, Reason: reason, Points: -user.Points, Type: TypeMigration},
+			{From: "janet", To: to, Reason: reason, Points: user.Points, Type: TypeMigration},
+		},
+	}
+
+	var spent, transfers int
+	err = db.SQL.QueryRow(
+		"select coalesce(sum(case when `type` = ?1 then `points` else 0 end), 0), coalesce(sum(case when `type` = ?2 then `points` else 0 end), 0) from karma where `to` = ?3",
+		TypeSpent, TypeTransfer, from,
+	).Scan(&spent, &transfers)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, moved := range []struct {
+		kind   string
+		points int
+	}{{TypeSpent, spent}, {TypeTransfer, transfers}} {
+		if moved.points == 0 {
+			continue
+		}
+
+		op.Records = append(op.Records,
+			&Points{From: "janet", To: from, Reason: reason, Points: -moved.points, Type: moved.kind},
+			&Points{From: "janet", To: to, Reason: reason, Points: moved.points, Type: moved.kind},
+		)
+	}
+
+	return op, nil
+}
+
+// PlanReset plans resetting a user's lifetime karma to 0. Their
+// balance changes by the same amount.
+func (db *DB) PlanReset(name, reason string) (*Operation, error) {
+	return db.plan(OperationReset, name, 0, reason, "resetting karma")
+}
+
+// PlanSet plans setting a user's lifetime karma to points. Their
+// balance changes by the same amount.
+func (db *DB) PlanSet(name string, points int, reason string) (*Operation, error) {
+	return db.plan(OperationSet, name, points, reason, "overriding karma")
+}
+
+func (db *DB) plan(kind, name string, points int, reason, defaultReason string) (*Operation, error) {
+	user, err := db.GetUser(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Points == points {
+		return nil, ErrNoChange
+	}
+
+	if reason == "" {
+		reason = defaultReason
+	}
+
+	return &Operation{
+		Kind: kind,
+		Records: []*Points{
+			{From: "janet", To: name, Reason: reason, Points: points - user.Points, Type: TypeAdjustment},
+		},
+	}, nil
+}
+
+// PlanRevoke plans taking back the points of a karma operation.
+// Revoking karma that users gave is an adjustment, other records
+// are revoked with their own type.
+func (db *DB) PlanRevoke(record *Record) (*Operation, error) {
+	if record.Points.Points == 0 {
+		return nil, ErrNoChange
+	}
+
+	kind := record.Type
+	if kind == "" || kind == TypeEarned {
+		kind = TypeAdjustment
+	}
+
+	return &Operation{
+		Kind: OperationRevoke,
+		Records: []*Points{
+			{
+				From:   "janet",
+				To:     record.To,
+				Reason: fmt.Sprintf("revoking %+d from %s", record.Points.Points, record.From),
+				Points: -record.Points.Points,
+				Type:   kind,
+			},
+		},
+	}, nil
+}
+
+// PlanRollback plans undoing the newest operation
+// that has not been rolled back yet.
+func (db *DB) PlanRollback() (*Operation, error) {
+	defer metrics.ObserveQuery("plan_rollback", time.Now())
+
+	var (
+		id   int64
+		kind string
+	)
+
+	err := db.SQL.QueryRow(
+		"select `id`, `kind` from operations as o where `kind` != ? and not exists (select 1 from operations as r where r.`reverts` = o.`id`) order by `id` desc limit 1",
+		OperationRollback,
+	).Scan(&id, &kind)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoOperation
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.SQL.Query("select `to`, `points`, `type` from karma where `operation` = ? order by `id`", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	op := &Operation{
+		Kind:    OperationRollback,
+		Reverts: id,
+	}
+
+	reason := fmt.Sprintf("rolling back %s #%d", kind, id)
+	for rows.Next() {
+		record := &Points{
+			From:   "janet",
+			Reason: reason,
+		}
+
+		err := rows.Scan(&record.To, &record.Points, &record.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		record.Points = -record.Points
+		op.Records = append(op.Records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// Apply inserts the records of a planned operation in a single
+// transaction and sets its ID.
+func (db *DB) Apply(op *Operation) error {
+	defer metrics.ObserveQuery("apply_operation", time.Now())
+
+	tx, err := db.SQL.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if op.Reverts != 0 {
+		var reverted int
+		err := tx.QueryRow("select count(*) from operations where `reverts` = ?", op.Reverts).Scan(&reverted)
+		if err != nil {
+			return err
+		}
+
+		// someone else rolled it back in the meantime
+		if reverted > 0 {
+			return ErrNoOperation
+		}
+	}
+
+	result, err := tx.Exec("insert into operations (`kind`, `reverts`) values(?, nullif(?, 0))", op.Kind, op.Reverts)
+	if err != nil {
+		return err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	insert, err := tx.Prepare("insert into karma (`from`, `to`, `reason`, `points`, `type`, `operation`) values(?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	for _, record := range op.Records {
+		_, err := insert.Exec(record.From, record.To, record.Reason, record.Points, recordType(record), id)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	op.ID = id
+	return nil
+}
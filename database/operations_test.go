@@ -0,0 +1,175 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanMigrate(t *testing.T) {
+	db := newTestDB(t)
+	insertRecords(t, db,
+		&Points{From: "alex", To: "troy", Points: 10},
+		&Points{From: "janet", To: "troy", Points: -3, Reason: "redeeming coffee", Type: TypeSpent},
+		&Points{From: "troy", To: "troy", Points: -2, Type: TypeTransfer},
+		&Points{From: "troy", To: "alex", Points: 2, Type: TypeTransfer},
+	)
+
+	op, err := db.PlanMigrate("troy", "tmccall", "")
+	if err != nil {
+		t.Fatalf("PlanMigrate: %v", err)
+	}
+
+	const reason = "migrating karma from troy to tmccall"
+	want := []*Points{
+		{From: "janet", To: "troy", Reason: reason, Points: -10, Type: TypeMigration},
+		{From: "janet", To: "tmccall", Reason: reason, Points: 10, Type: TypeMigration},
+		{From: "janet", To: "troy", Reason: reason, Points: 3, Type: TypeSpent},
+		{From: "janet", To: "tmccall", Reason: reason, Points: -3, Type: TypeSpent},
+		{From: "janet", To: "troy", Reason: reason, Points: 2, Type: TypeTransfer},
+		{From: "janet", To: "tmccall", Reason: reason, Points: -2, Type: TypeTransfer},
+	}
+	if op.Kind != OperationMigrate || !reflect.DeepEqual(op.Records, want) {
+		t.Fatalf("PlanMigrate: planned %s %+v; want %s %+v", op.Kind, op.Records, OperationMigrate, want)
+	}
+
+	if err := db.Apply(op); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if op.ID == 0 {
+		t.Errorf("Apply: did not set the operation's ID")
+	}
+
+	got := balances(t, db, "troy", "tmccall", "alex")
+	if !reflect.DeepEqual(got, map[string][2]int{
+		"troy":    {0, 0},
+		"tmccall": {10, 5},
+		"alex":    {0, 2},
+	}) {
+		t.Errorf("migrating moved karma to %v; want all of troy's lifetime karma and balance moved to tmccall", got)
+	}
+
+	if _, err := db.PlanMigrate("troy", "tmccall", ""); err != ErrNoChange {
+		t.Errorf("PlanMigrate: migrating again returned %v; want %v", err, ErrNoChange)
+	}
+}
+
+func TestPlanMigrateNoChange(t *testing.T) {
+	db := newTestDB(t)
+	insertRecords(t, db, &Points{From: "alex", To: "troy", Points: 1})
+
+	if _, err := db.PlanMigrate("troy", "troy", ""); err != ErrNoChange {
+		t.Errorf("PlanMigrate: migrating to the same user returned %v; want %v", err, ErrNoChange)
+	}
+	if _, err := db.PlanMigrate("nobody", "troy", ""); err != ErrNoSuchUser {
+		t.Errorf("PlanMigrate: migrating an unknown user returned %v; want %v", err, ErrNoSuchUser)
+	}
+}
+
+func TestPlanRollback(t *testing.T) {
+	db := newTestDB(t)
+	insertRecords(t, db,
+		&Points{From: "alex", To: "troy", Points: 10},
+		&Points{From: "janet", To: "troy", Points: -3, Reason: "redeeming coffee", Type: TypeSpent},
+	)
+
+	if _, err := db.PlanRollback(); err != ErrNoOperation {
+		t.Fatalf("PlanRollback: returned %v without operations; want %v", err, ErrNoOperation)
+	}
+
+	migrate, err := db.PlanMigrate("troy", "tmccall", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Apply(migrate); err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := db.PlanSet("tmccall", 20, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Apply(set); err != nil {
+		t.Fatal(err)
+	}
+
+	// operations are rolled back newest first
+	for _, tc := range []struct {
+		reverts  *Operation
+		balances map[string][2]int
+	}{
+		{
+			reverts: set,
+			balances: map[string][2]int{
+				"troy":    {0, 0},
+				"tmccall": {10, 7},
+			},
+		},
+		{
+			reverts: migrate,
+			balances: map[string][2]int{
+				"troy":    {10, 7},
+				"tmccall": {0, 0},
+			},
+		},
+	} {
+		op, err := db.PlanRollback()
+		if err != nil {
+			t.Fatalf("PlanRollback: %v", err)
+		}
+		if op.Kind != OperationRollback || op.Reverts != tc.reverts.ID || len(op.Records) != len(tc.reverts.Records) {
+			t.Fatalf("PlanRollback: planned %+v; want a rollback of %s #%d", op, tc.reverts.Kind, tc.reverts.ID)
+		}
+
+		// the types are kept, so that the balance is restored as well
+		for i, record := range op.Records {
+			if record.Points != -tc.reverts.Records[i].Points || record.Type != tc.reverts.Records[i].Type {
+				t.Errorf("PlanRollback: planned %+v to revert %+v", record, tc.reverts.Records[i])
+			}
+		}
+
+		if err := db.Apply(op); err != nil {
+			t.Fatalf("Apply: %v", err)
+		}
+
+		if got := balances(t, db, "troy", "tmccall"); !reflect.DeepEqual(got, tc.balances) {
+			t.Errorf("rolling back %s: left %v; want %v", tc.reverts.Kind, got, tc.balances)
+		}
+	}
+
+	if _, err := db.PlanRollback(); err != ErrNoOperation {
+		t.Errorf("PlanRollback: returned %v after rolling back everything; want %v", err, ErrNoOperation)
+	}
+}
+
+func TestApplyRollbackTwice(t *testing.T) {
+	db := newTestDB(t)
+	insertRecords(t, db, &Points{From: "alex", To: "troy", Points: 10})
+
+	reset, err := db.PlanReset("troy", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Apply(reset); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := db.PlanRollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := db.PlanRollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Apply(first); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if err := db.Apply(second); err != ErrNoOperation {
+		t.Errorf("Apply: rolling back twice returned %v; want %v", err, ErrNoOperation)
+	}
+
+	if got := balances(t, db, "troy"); !reflect.DeepEqual(got, map[string][2]int{"troy": {10, 10}}) {
+		t.Errorf("rolling back twice left %v; want troy with 10 points", got)
+	}
+}
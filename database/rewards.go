@@ -0,0 +1,314 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/troyxmccall/janet/metrics"
+)
+
+// The states of a redemption.
+const (
+	RedemptionPending  = "pending"
+	RedemptionApproved = "approved"
+	RedemptionDenied   = "denied"
+)
+
+// A Reward is a perk from the catalog that users can
+// spend their karma on.
+type Reward struct {
+	Name, Description string
+	Cost              int
+}
+
+// A Redemption is a user's request to spend karma on a reward.
+// The points are debited when the request is made and refunded
+// if an admin denies it.
+type Redemption struct {
+	ID           int64
+	User, Reward string
+	Cost         int
+	Status       string
+
+	// Admin is the admin that approved or denied the
+	// redemption, and Note their reason, if any.
+	Admin, Note string
+
+	Created, Decided time.Time
+}
+
+// ErrNoSuchReward is returned when a reward
+// lookup is performed on a non-existent reward
+var ErrNoSuchReward = errors.New("no such reward")
+
+// ErrNoSuchRedemption is returned when deciding
+// on a redemption that does not exist
+var ErrNoSuchRedemption = errors.New("no such redemption")
+
+// ErrAlreadyDecided is returned when deciding on a
+// redemption that was approved or denied before
+var ErrAlreadyDecided = errors.New("redemption was already decided")
+
+// ErrInsufficientPoints is returned when a user does
+// not have enough karma to redeem a reward
+var ErrInsufficientPoints = errors.New("not enough karma")
+
+func (db *DB) createRewardsTables() error {
+	_, err := db.SQL.Exec("create table if not exists rewards (`name` text primary key, `cost` integer not null, `description` text not null default '')")
+	if err != nil {
+		return err
+	}
+
+	schema := strings.Replace(
+		`create table if not exists redemptions (
+			^id^ integer primary key,
+			^user^ text not null,
+			^reward^ text not null,
+			^cost^ integer not null,
+			^status^ text not null default 'pending',
+			^admin^ text not null default '',
+			^note^ text not null default '',
+			^created^ text not null default (datetime('now')),
+			^decided^ text
+		)`,
+		"^", "`", -1)
+
+	_, err = db.SQL.Exec(schema)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.SQL.Exec("create index if not exists idx_redemption_user on redemptions(`user`);")
+	return err
+}
+
+// InsertReward adds a reward to the catalog, replacing
+// any existing reward with the same name.
+func (db *DB) InsertReward(reward *Reward) error {
+	defer metrics.ObserveQuery("insert_reward", time.Now())
+
+	_, err := db.SQL.Exec(
+		"insert or replace into rewards (`name`, `cost`, `description`) values(?, ?, ?)",
+		reward.Name, reward.Cost, reward.Description,
+	)
+
+	return err
+}
+
+// DeleteReward removes a reward from the catalog. Pending
+// redemptions of the reward can still be decided.
+func (db *DB) DeleteReward(name string) error {
+	defer metrics.ObserveQuery("delete_reward", time.Now())
+
+	res, err := db.SQL.Exec("delete from rewards where `name` = ?", name)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNoSuchReward
+	}
+
+	return nil
+}
+
+// GetRewards returns the catalog, cheapest first.
+func (db *DB) GetRewards() ([]*Reward, error) {
+	defer metrics.ObserveQuery("get_rewards", time.Now())
+
+	rows, err := db.SQL.Query("select `name`, `cost`, `description` from rewards order by `cost`, `name`")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rewards []*Reward
+	for rows.Next() {
+		reward := &Reward{}
+		err := rows.Scan(&reward.Name, &reward.Cost, &reward.Description)
+		if err != nil {
+			return nil, err
+		}
+
+		rewards = append(rewards, reward)
+	}
+
+	return rewards, rows.Err()
+}
+
+// Redeem debits the cost of a reward from a user's karma and
+// records a pending redemption, in a single transaction. If the
+// user cannot afford the reward, the unsaved redemption is
+// returned with ErrInsufficientPoints.
+func (db *DB) Redeem(user, reward string) (*Redemption, error) {
+	defer metrics.ObserveQuery("redeem", time.Now())
+
+	tx, err := db.SQL.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	redemption := &Redemption{
+		User:   user,
+		Reward: reward,
+		Status: RedemptionPending,
+	}
+
+	err = tx.QueryRow("select `cost` from rewards where `name` = ?", reward).Scan(&redemption.Cost)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoSuchReward
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := getBalance(tx, user)
+	if err != nil {
+		return nil, err
+	}
+	if balance < redemption.Cost {
+		return redemption, ErrInsufficientPoints
+	}
+
+	res, err := tx.Exec("insert into redemptions (`user`, `reward`, `cost`) values(?, ?, ?)", user, reward, redemption.Cost)
+	if err != nil {
+		return nil, err
+	}
+
+	redemption.ID, err = res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(
+		"insert into karma (`from`, `to`, `reason`, `points`, `type`) values('janet', ?, ?, ?, ?)",
+		user, fmt.Sprintf("redeeming %s (#%d)", reward, redemption.ID), -redemption.Cost, TypeSpent,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	redemption.Created = time.Now().UTC().Truncate(time.Second)
+
+	return redemption, tx.Commit()
+}
+
+// DecideRedemption approves or denies a pending redemption on
+// behalf of an admin. The points of a denied redemption are
+// refunded in the same transaction.
+func (db *DB) DecideRedemption(id int64, admin string, approve bool, note string) (*Redemption, error) {
+	defer metrics.ObserveQuery("decide_redemption", time.Now())
+
+	tx, err := db.SQL.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(selectRedemptions+" where `id` = ?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	redemptions, err := scanRedemptions(rows)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(redemptions) == 0 {
+		return nil, ErrNoSuchRedemption
+	}
+
+	redemption := redemptions[0]
+	if redemption.Status != RedemptionPending {
+		return redemption, ErrAlreadyDecided
+	}
+
+	redemption.Status = RedemptionDenied
+	if approve {
+		redemption.Status = RedemptionApproved
+	}
+	redemption.Admin = admin
+	redemption.Note = note
+	redemption.Decided = time.Now().UTC().Truncate(time.Second)
+
+	_, err = tx.Exec(
+		"update redemptions set `status` = ?, `admin` = ?, `note` = ?, `decided` = ? where `id` = ?",
+		redemption.Status, admin, note, redemption.Decided.Format(TimestampFormat), id,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if !approve {
+		_, err = tx.Exec(
+			"insert into karma (`from`, `to`, `reason`, `points`, `type`) values('janet', ?, ?, ?, ?)",
+			redemption.User, fmt.Sprintf("refunding %s (#%d)", redemption.Reward, id), redemption.Cost, TypeSpent,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return redemption, tx.Commit()
+}
+
+const selectRedemptions = "select `id`, `user`, `reward`, `cost`, `status`, `admin`, `note`, `created`, coalesce(`decided`, '') from redemptions"
+
+// GetRedemptions returns the redemptions of a user, or of all
+// users if user is empty, newest first. limit < 1 returns all
+// redemptions.
+func (db *DB) GetRedemptions(user string, limit int) ([]*Redemption, error) {
+	defer metrics.ObserveQuery("get_redemptions", time.Now())
+
+	if limit < 1 {
+		limit = -1
+	}
+
+	rows, err := db.SQL.Query(selectRedemptions+" where ?1 = '' or `user` = ?1 order by `id` desc limit ?2", user, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRedemptions(rows)
+}
+
+func scanRedemptions(rows *sql.Rows) ([]*Redemption, error) {
+	var redemptions []*Redemption
+	for rows.Next() {
+		var (
+			r                = &Redemption{}
+			created, decided string
+		)
+
+		err := rows.Scan(&r.ID, &r.User, &r.Reward, &r.Cost, &r.Status, &r.Admin, &r.Note, &created, &decided)
+		if err != nil {
+			return nil, err
+		}
+
+		r.Created, err = time.Parse(TimestampFormat, created)
+		if err != nil {
+			return nil, err
+		}
+
+		if decided != "" {
+			r.Decided, err = time.Parse(TimestampFormat, decided)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		redemptions = append(redemptions, r)
+	}
+
+	return redemptions, rows.Err()
+}
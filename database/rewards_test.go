@@ -0,0 +1,202 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+// spentRecords returns the spent records of the ledger.
+func spentRecords(t *testing.T, db *DB) []Points {
+	records, err := db.GetRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var spent []Points
+	for _, record := range records {
+		if record.Type == TypeSpent {
+			spent = append(spent, record.Points)
+		}
+	}
+
+	return spent
+}
+
+func TestRedeem(t *testing.T) {
+	db := newTestDB(t)
+	insertRecords(t, db,
+		&Points{From: "alex", To: "troy", Points: 10},
+		&Points{From: "troy", To: "troy", Points: -2, Type: TypeTransfer},
+		&Points{From: "troy", To: "alex", Points: 2, Type: TypeTransfer},
+	)
+	for _, reward := range []*Reward{{Name: "coffee", Cost: 5}, {Name: "lunch", Cost: 4}} {
+		if err := db.InsertReward(reward); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name, user, reward string
+		id                 int64
+		cost               int
+		err                error
+	}{
+		{
+			name:   "unknown reward",
+			user:   "troy",
+			reward: "vacation",
+			err:    ErrNoSuchReward,
+		},
+		{
+			name:   "within the balance",
+			user:   "troy",
+			reward: "coffee",
+			id:     1,
+			cost:   5,
+		},
+		{
+			// troy has earned 10 points, but only 3 are left
+			name:   "over the balance",
+			user:   "troy",
+			reward: "lunch",
+			cost:   4,
+			err:    ErrInsufficientPoints,
+		},
+		{
+			name:   "without any karma",
+			user:   "bob",
+			reward: "lunch",
+			cost:   4,
+			err:    ErrInsufficientPoints,
+		},
+	} {
+		redemption, err := db.Redeem(tc.user, tc.reward)
+		if err != tc.err {
+			t.Errorf("%s: returned error %v; want %v", tc.name, err, tc.err)
+			continue
+		}
+		if tc.err == ErrNoSuchReward {
+			continue
+		}
+
+		if redemption.ID != tc.id || redemption.Cost != tc.cost || redemption.Status != RedemptionPending {
+			t.Errorf("%s: returned %+v; want a pending redemption #%d costing %d", tc.name, redemption, tc.id, tc.cost)
+		}
+	}
+
+	// only the successful redemption is debited
+	if got, want := spentRecords(t, db), []Points{
+		{From: "janet", To: "troy", Points: -5, Reason: "redeeming coffee (#1)", Type: TypeSpent},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Redeem: spent %+v; want %+v", got, want)
+	}
+
+	if got := balances(t, db, "troy", "alex"); !reflect.DeepEqual(got, map[string][2]int{
+		"troy": {10, 3},
+		"alex": {0, 2},
+	}) {
+		t.Errorf("Redeem: left %v; want troy with 10 points and a balance of 3", got)
+	}
+
+	redemptions, err := db.GetRedemptions("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(redemptions) != 1 || redemptions[0].User != "troy" || redemptions[0].Reward != "coffee" || redemptions[0].Created.IsZero() {
+		t.Errorf("GetRedemptions: returned %+v; want the coffee of troy", redemptions)
+	}
+}
+
+func TestDecideRedemption(t *testing.T) {
+	db := newTestDB(t)
+	insertRecords(t, db, &Points{From: "alex", To: "troy", Points: 10})
+	if err := db.InsertReward(&Reward{Name: "coffee", Cost: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := db.Redeem("troy", "coffee"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		id      int64
+		approve bool
+		status  string
+		err     error
+	}{
+		{
+			name:    "approve",
+			id:      1,
+			approve: true,
+			status:  RedemptionApproved,
+		},
+		{
+			name:   "deny",
+			id:     2,
+			status: RedemptionDenied,
+		},
+		{
+			name:   "deny after approving",
+			id:     1,
+			status: RedemptionApproved,
+			err:    ErrAlreadyDecided,
+		},
+		{
+			name:    "approve after denying",
+			id:      2,
+			approve: true,
+			status:  RedemptionDenied,
+			err:     ErrAlreadyDecided,
+		},
+		{
+			name: "unknown redemption",
+			id:   3,
+			err:  ErrNoSuchRedemption,
+		},
+	} {
+		redemption, err := db.DecideRedemption(tc.id, "admin", tc.approve, "note of "+tc.name)
+		if err != tc.err {
+			t.Errorf("%s: returned error %v; want %v", tc.name, err, tc.err)
+			continue
+		}
+		if tc.err == ErrNoSuchRedemption {
+			continue
+		}
+
+		if redemption.Status != tc.status {
+			t.Errorf("%s: returned a redemption that is %s; want %s", tc.name, redemption.Status, tc.status)
+		}
+		if tc.err == nil && (redemption.Admin != "admin" || redemption.Note != "note of "+tc.name || redemption.Decided.IsZero()) {
+			t.Errorf("%s: returned %+v; want it to be decided by admin", tc.name, redemption)
+		}
+	}
+
+	// the denied redemption is refunded exactly once
+	if got, want := spentRecords(t, db), []Points{
+		{From: "janet", To: "troy", Points: -4, Reason: "redeeming coffee (#1)", Type: TypeSpent},
+		{From: "janet", To: "troy", Points: -4, Reason: "redeeming coffee (#2)", Type: TypeSpent},
+		{From: "janet", To: "troy", Points: 4, Reason: "refunding coffee (#2)", Type: TypeSpent},
+	}; !reflect.DeepEqual(got, want) {
+		t.Errorf("DecideRedemption: spent %+v; want %+v", got, want)
+	}
+
+	if got := balances(t, db, "troy"); !reflect.DeepEqual(got, map[string][2]int{"troy": {10, 6}}) {
+		t.Errorf("DecideRedemption: left %v; want troy with a balance of 6", got)
+	}
+
+	// the stored decision matches the returned one
+	redemptions, err := db.GetRedemptions("troy", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statuses []string
+	for _, redemption := range redemptions {
+		statuses = append(statuses, redemption.Status)
+	}
+	if !reflect.DeepEqual(statuses, []string{RedemptionDenied, RedemptionApproved}) {
+		t.Errorf("GetRedemptions: returned redemptions that are %v; want denied and approved", statuses)
+	}
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/troyxmccall/janet/metrics"
+)
+
+// A TransferSummary describes the giver of a transfer
+// once the transfer has been made or refused.
+type TransferSummary struct {
+	// Balance is the giver's balance.
+	Balance int
+
+	// Given is the amount of points that the giver
+	// transferred in the past 24 hours.
+	Given int
+}
+
+// ErrTransferLimit is returned when a transfer would take
+// the points a user gave in the past 24 hours over the limit
+var ErrTransferLimit = errors.New("transfer limit reached")
+
+// transferred restricts a query to the transfers given by
+// a user in the past 24 hours, which debit their own balance.
+const transferred = "`from` = ?1 and `to` = ?1 and `type` = '" + TypeTransfer + "' and `timestamp` >= datetime('now', '-1 day')"
+
+// Transfer moves points from the balance of points.From to
+// points.To in a single transaction: the giver is debited by a
+// record to themselves and the receiver credited by a record from
+// the giver, both with the transfer type. If the giver's balance is
+// too low, or the transfer would take the points they gave in the
+// past 24 hours over limit, the summary is returned together with
+// ErrInsufficientPoints or ErrTransferLimit. limit < 1 means no limit.
+func (db *DB) Transfer(points *Points, limit int) (*TransferSummary, error) {
+	defer metrics.ObserveQuery("transfer", time.Now())
+
+	tx, err := db.SQL.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	summary := &TransferSummary{}
+
+	summary.Balance, err = getBalance(tx, points.From)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow("select coalesce(-sum(`points`), 0) from karma where "+transferred, points.From).Scan(&summary.Given)
+	if err != nil {
+		return nil, err
+	}
+
+	if summary.Balance < points.Points {
+		return summary, ErrInsufficientPoints
+	}
+	if limit > 0 && summary.Given+points.Points > limit {
+		return summary, ErrTransferLimit
+	}
+
+	insert, err := tx.Prepare("insert into karma (`from`, `to`, `reason`, `points`, `message`, `type`) values(?, ?, ?, ?, nullif(?, ''), ?)")
+	if err != nil {
+		return nil, err
+	}
+	defer insert.Close()
+
+	_, err = insert.Exec(points.From, points.From, points.Reason, -points.Points, points.Message, TypeTransfer)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = insert.Exec(points.From, points.To, points.Reason, points.Points, points.Message, TypeTransfer)
+	if err != nil {
+		return nil, err
+	}
+
+	summary.Balance -= points.Points
+	summary.Given += points.Points
+
+	return summary, tx.Commit()
+}
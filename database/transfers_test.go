@@ -0,0 +1,113 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTransfer(t *testing.T) {
+	db := newTestDB(t)
+	insertRecords(t, db, &Points{From: "alex", To: "troy", Points: 20})
+
+	// transfers older than a day do not count towards the limit
+	for _, record := range []*Record{
+		{Points: Points{From: "troy", To: "troy", Points: -4, Type: TypeTransfer}, Timestamp: time.Now().Add(-25 * time.Hour)},
+		{Points: Points{From: "troy", To: "alex", Points: 4, Type: TypeTransfer}, Timestamp: time.Now().Add(-25 * time.Hour)},
+		{Points: Points{From: "troy", To: "troy", Points: -3, Type: TypeTransfer}, Timestamp: time.Now().Add(-23 * time.Hour)},
+		{Points: Points{From: "troy", To: "alex", Points: 3, Type: TypeTransfer}, Timestamp: time.Now().Add(-23 * time.Hour)},
+	} {
+		if err := db.InsertRecord(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		points  int
+		summary TransferSummary
+		err     error
+	}{
+		{
+			name:    "within the limit",
+			points:  5,
+			summary: TransferSummary{Balance: 8, Given: 8},
+		},
+		{
+			name:    "over the limit",
+			points:  3,
+			summary: TransferSummary{Balance: 8, Given: 8},
+			err:     ErrTransferLimit,
+		},
+		{
+			name:    "up to the limit",
+			points:  2,
+			summary: TransferSummary{Balance: 6, Given: 10},
+		},
+	} {
+		summary, err := db.Transfer(&Points{From: "troy", To: "alex", Points: tc.points, Reason: "for lunch"}, 10)
+		if err != tc.err {
+			t.Errorf("%s: returned error %v; want %v", tc.name, err, tc.err)
+			continue
+		}
+		if summary == nil || *summary != tc.summary {
+			t.Errorf("%s: returned %+v; want %+v", tc.name, summary, tc.summary)
+		}
+	}
+
+	// transfers move the balance, but not lifetime karma
+	if got := balances(t, db, "troy", "alex"); !reflect.DeepEqual(got, map[string][2]int{
+		"troy": {20, 6},
+		"alex": {0, 14},
+	}) {
+		t.Errorf("transfers left %v; want troy with a balance of 6 and alex with 14", got)
+	}
+}
+
+func TestTransferInsufficientPoints(t *testing.T) {
+	db := newTestDB(t)
+	insertRecords(t, db,
+		&Points{From: "alex", To: "troy", Points: 5},
+		&Points{From: "janet", To: "troy", Points: -3, Reason: "redeeming coffee", Type: TypeSpent},
+	)
+
+	summary, err := db.Transfer(&Points{From: "troy", To: "alex", Points: 3}, 0)
+	if err != ErrInsufficientPoints {
+		t.Fatalf("Transfer: returned error %v; want %v", err, ErrInsufficientPoints)
+	}
+	if *summary != (TransferSummary{Balance: 2}) {
+		t.Errorf("Transfer: returned %+v; want a balance of 2", summary)
+	}
+
+	if got := balances(t, db, "troy", "alex"); !reflect.DeepEqual(got, map[string][2]int{"troy": {5, 2}}) {
+		t.Errorf("a refused transfer left %v; want troy's balance unchanged", got)
+	}
+}
+
+func TestTransferRecords(t *testing.T) {
+	db := newTestDB(t)
+	insertRecords(t, db, &Points{From: "alex", To: "troy", Points: 5})
+
+	_, err := db.Transfer(&Points{From: "troy", To: "alex", Points: 2, Reason: "for lunch", Message: "C0123/1547456400.000100"}, 0)
+	if err != nil {
+		t.Fatalf("Transfer: %v", err)
+	}
+
+	records, err := db.GetRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []Points
+	for _, record := range records[1:] {
+		got = append(got, record.Points)
+	}
+
+	want := []Points{
+		{From: "troy", To: "troy", Reason: "for lunch", Points: -2, Type: TypeTransfer, Message: "C0123/1547456400.000100"},
+		{From: "troy", To: "alex", Reason: "for lunch", Points: 2, Type: TypeTransfer, Message: "C0123/1547456400.000100"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Transfer: recorded %+v; want %+v", got, want)
+	}
+}
@@ -0,0 +1,309 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/troyxmccall/janet/metrics"
+)
+
+// The states of a webhook delivery.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// A Webhook is a URL that karma events are posted to.
+type Webhook struct {
+	URL, Secret string
+
+	// Events are the events that the webhook is subscribed
+	// to. A webhook without events gets all of them.
+	Events []string
+}
+
+// Subscribed checks whether the webhook gets an event.
+func (w *Webhook) Subscribed(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+
+	return false
+}
+
+// A Delivery is an attempt to post an event to a webhook,
+// which is retried until it succeeds or gives up.
+type Delivery struct {
+	ID         int64
+	URL, Event string
+	Payload    []byte
+
+	// Secret is the secret of the webhook. It is only
+	// set for the deliveries returned by DueDeliveries.
+	Secret string
+
+	Status   string
+	Attempts int
+
+	// Response is the HTTP status code of the last
+	// attempt, or 0 if the request failed.
+	Response int
+	Error    string
+
+	Created, NextAttempt time.Time
+	Delivered            time.Time
+}
+
+// ErrNoSuchWebhook is returned when removing
+// a webhook that does not exist
+var ErrNoSuchWebhook = errors.New("no such webhook")
+
+func (db *DB) createWebhooksTables() error {
+	_, err := db.SQL.Exec("create table if not exists webhooks (`url` text primary key, `secret` text not null, `events` text not null default '')")
+	if err != nil {
+		return err
+	}
+
+	schema := strings.Replace(
+		`create table if not exists webhook_deliveries (
+			^id^ integer primary key,
+			^url^ text not null,
+			^event^ text not null,
+			^payload^ blob not null,
+			^status^ text not null default 'pending',
+			^attempts^ integer not null default 0,
+			^response^ integer not null default 0,
+			^error^ text not null default '',
+			^created^ text not null default (datetime('now')),
+			^next_attempt^ text not null default (datetime('now')),
+			^delivered^ text
+		)`,
+		"^", "`", -1)
+
+	_, err = db.SQL.Exec(schema)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.SQL.Exec("create index if not exists idx_delivery_status on webhook_deliveries(`status`, `next_attempt`);")
+	return err
+}
+
+// InsertWebhook adds a webhook, replacing any
+// existing webhook with the same URL.
+func (db *DB) InsertWebhook(webhook *Webhook) error {
+	defer metrics.ObserveQuery("insert_webhook", time.Now())
+
+	_, err := db.SQL.Exec(
+		"insert or replace into webhooks (`url`, `secret`, `events`) values(?, ?, ?)",
+		webhook.URL, webhook.Secret, strings.Join(webhook.Events, ","),
+	)
+
+	return err
+}
+
+// DeleteWebhook removes a webhook. Its pending
+// deliveries are marked as failed.
+func (db *DB) DeleteWebhook(url string) error {
+	defer metrics.ObserveQuery("delete_webhook", time.Now())
+
+	tx, err := db.SQL.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("delete from webhooks where `url` = ?", url)
+	if err != nil {
+		return err
+	}
+
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrNoSuchWebhook
+	}
+
+	_, err = tx.Exec(
+		"update webhook_deliveries set `status` = ?, `error` = 'webhook was removed' where `url` = ? and `status` = ?",
+		DeliveryFailed, url, DeliveryPending,
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetWebhooks returns all webhooks, ordered by URL.
+func (db *DB) GetWebhooks() ([]*Webhook, error) {
+	defer metrics.ObserveQuery("get_webhooks", time.Now())
+
+	rows, err := db.SQL.Query("select `url`, `secret`, `events` from webhooks order by `url`")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*Webhook
+	for rows.Next() {
+		var (
+			webhook = &Webhook{}
+			events  string
+		)
+
+		err := rows.Scan(&webhook.URL, &webhook.Secret, &events)
+		if err != nil {
+			return nil, err
+		}
+
+		if events != "" {
+			webhook.Events = strings.Split(events, ",")
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// QueueEvent queues a delivery of an event's payload to
+// each webhook that is subscribed to the event.
+func (db *DB) QueueEvent(event string, payload []byte) error {
+	defer metrics.ObserveQuery("queue_event", time.Now())
+
+	webhooks, err := db.GetWebhooks()
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.SQL.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, webhook := range webhooks {
+		if !webhook.Subscribed(event) {
+			continue
+		}
+
+		_, err := tx.Exec("insert into webhook_deliveries (`url`, `event`, `payload`) values(?, ?, ?)", webhook.URL, event, payload)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DueDeliveries returns up to limit pending deliveries whose
+// next attempt is due at now, oldest first.
+func (db *DB) DueDeliveries(now time.Time, limit int) ([]*Delivery, error) {
+	defer metrics.ObserveQuery("due_deliveries", time.Now())
+
+	rows, err := db.SQL.Query(
+		"select d.`id`, d.`url`, d.`event`, d.`payload`, w.`secret`, d.`status`, d.`attempts`, d.`response`, d.`error`, d.`created`, d.`next_attempt`, coalesce(d.`delivered`, '') from webhook_deliveries as d join webhooks as w on w.`url` = d.`url` where d.`status` = ? and d.`next_attempt` <= ? order by d.`id` limit ?",
+		DeliveryPending, now.UTC().Format(TimestampFormat), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanDeliveries(rows, true)
+}
+
+// GetDeliveries returns the newest deliveries, up to limit.
+// limit < 1 returns all deliveries.
+func (db *DB) GetDeliveries(limit int) ([]*Delivery, error) {
+	defer metrics.ObserveQuery("get_deliveries", time.Now())
+
+	if limit < 1 {
+		limit = -1
+	}
+
+	rows, err := db.SQL.Query(
+		"select `id`, `url`, `event`, `payload`, `status`, `attempts`, `response`, `error`, `created`, `next_attempt`, coalesce(`delivered`, '') from webhook_deliveries order by `id` desc limit ?",
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanDeliveries(rows, false)
+}
+
+// scanDeliveries reads deliveries from rows, which include
+// the webhook's secret after the payload if secret is set.
+func scanDeliveries(rows *sql.Rows, secret bool) ([]*Delivery, error) {
+	var deliveries []*Delivery
+	for rows.Next() {
+		var (
+			d                        = &Delivery{}
+			created, next, delivered string
+			dest                     = []interface{}{&d.ID, &d.URL, &d.Event, &d.Payload}
+		)
+
+		if secret {
+			dest = append(dest, &d.Secret)
+		}
+		dest = append(dest, &d.Status, &d.Attempts, &d.Response, &d.Error, &created, &next, &delivered)
+
+		err := rows.Scan(dest...)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, t := range []struct {
+			value string
+			time  *time.Time
+		}{
+			{created, &d.Created},
+			{next, &d.NextAttempt},
+			{delivered, &d.Delivered},
+		} {
+			if t.value == "" {
+				continue
+			}
+
+			*t.time, err = time.Parse(TimestampFormat, t.value)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// UpdateDelivery records the outcome of an attempt to deliver.
+func (db *DB) UpdateDelivery(d *Delivery) error {
+	defer metrics.ObserveQuery("update_delivery", time.Now())
+
+	var delivered interface{}
+	if !d.Delivered.IsZero() {
+		delivered = d.Delivered.UTC().Format(TimestampFormat)
+	}
+
+	_, err := db.SQL.Exec(
+		"update webhook_deliveries set `status` = ?, `attempts` = ?, `response` = ?, `error` = ?, `next_attempt` = ?, `delivered` = ? where `id` = ?",
+		d.Status, d.Attempts, d.Response, d.Error, d.NextAttempt.UTC().Format(TimestampFormat), delivered, d.ID,
+	)
+
+	return err
+}
@@ -1,6 +1,7 @@
 package janet
 
 import (
+	"fmt"
 	"sort"
 	"time"
 
@@ -8,7 +9,13 @@ import (
 )
 
 type TestDatabase struct {
-	records []database.Points
+	records     []database.Points
+	aliases     map[string]string
+	blacklist   map[string]bool
+	events      []string
+	badges      map[string][]string
+	rewards     []*database.Reward
+	redemptions []*database.Redemption
 }
 
 func (t *TestDatabase) InsertPoints(points *database.Points) error {
@@ -18,19 +25,23 @@ func (t *TestDatabase) InsertPoints(points *database.Points) error {
 
 func (t *TestDatabase) GetUser(name string) (*database.User, error) {
 	foundUser := false
-	pointCount := 0
+	pointCount, balance := 0, 0
 	for _, r := range t.records {
 		if r.To == name {
 			foundUser = true
-			pointCount += r.Points
+			balance += r.Points
+			if r.Type != database.TypeSpent && r.Type != database.TypeTransfer {
+				pointCount += r.Points
+			}
 		}
 	}
 	if !foundUser {
 		return nil, database.ErrNoSuchUser
 	}
 	return &database.User{
-		Name:   name,
-		Points: pointCount,
+		Name:    name,
+		Points:  pointCount,
+		Balance: balance,
 	}, nil
 }
 
@@ -38,9 +49,13 @@ func (t *TestDatabase) GetLeaderboard(limit int) (database.Leaderboard, error) {
 	us := make(map[string]*database.User)
 
 	for _, r := range t.records {
+		if r.Type == database.TypeSpent || r.Type == database.TypeTransfer {
+			continue
+		}
+
 		u := us[r.To]
 		if u == nil {
-			u = &database.User{}
+			u = &database.User{Name: r.To}
 		}
 		u.Points += r.Points
 		us[r.To] = u
@@ -53,15 +68,47 @@ func (t *TestDatabase) GetLeaderboard(limit int) (database.Leaderboard, error) {
 	sort.SliceStable(lb, func(i, j int) bool {
 		ui := lb[i]
 		uj := lb[j]
-		if ui.Points == uj.Points && ui.Name < uj.Name {
-			return true
+		if ui.Points == uj.Points {
+			return ui.Name < uj.Name
+		}
+		return ui.Points > uj.Points
+	})
+	if len(lb) > limit {
+		lb = lb[:limit]
+	}
+	return lb, nil
+}
+
+func (t *TestDatabase) GetBottom(limit int) (database.Leaderboard, error) {
+	us := make(map[string]*database.User)
+
+	for _, r := range t.records {
+		if r.Points >= 0 || r.From == "janet" {
+			continue
+		}
+
+		u := us[r.To]
+		if u == nil {
+			u = &database.User{Name: r.To}
 		}
-		if ui.Points < uj.Points {
-			return true
+		u.Points -= r.Points
+		us[r.To] = u
+	}
+
+	lb := make(database.Leaderboard, 0, len(us))
+	for _, u := range us {
+		lb = append(lb, u)
+	}
+	sort.SliceStable(lb, func(i, j int) bool {
+		if lb[i].Points == lb[j].Points {
+			return lb[i].Name < lb[j].Name
 		}
-		return false
+		return lb[i].Points > lb[j].Points
 	})
-	return lb[:limit], nil
+	if len(lb) > limit {
+		lb = lb[:limit]
+	}
+	return lb, nil
 }
 
 func (t *TestDatabase) GetTotalPoints() (int, error) {
@@ -94,3 +141,275 @@ func (t *TestDatabase) GetThrowback(user string) (*database.Throwback, error) {
 		Timestamp: time.Now(),
 	}, nil
 }
+
+func (t *TestDatabase) InsertAlias(alias, user string) error {
+	if t.aliases == nil {
+		t.aliases = make(map[string]string)
+	}
+	t.aliases[alias] = user
+	return nil
+}
+
+func (t *TestDatabase) DeleteAlias(alias string) error {
+	if _, ok := t.aliases[alias]; !ok {
+		return database.ErrNoSuchAlias
+	}
+	delete(t.aliases, alias)
+	return nil
+}
+
+func (t *TestDatabase) GetAlias(alias string) (string, error) {
+	user, ok := t.aliases[alias]
+	if !ok {
+		return "", database.ErrNoSuchAlias
+	}
+	return user, nil
+}
+
+func (t *TestDatabase) GetAliases() ([]*database.Alias, error) {
+	aliases := make([]*database.Alias, 0, len(t.aliases))
+	for alias, user := range t.aliases {
+		aliases = append(aliases, &database.Alias{Alias: alias, User: user})
+	}
+	sort.Slice(aliases, func(i, j int) bool {
+		return aliases[i].Alias < aliases[j].Alias
+	})
+	return aliases, nil
+}
+
+func (t *TestDatabase) InsertBlacklist(user string) error {
+	if t.blacklist == nil {
+		t.blacklist = make(map[string]bool)
+	}
+	t.blacklist[user] = true
+	return nil
+}
+
+func (t *TestDatabase) DeleteBlacklist(user string) error {
+	if !t.blacklist[user] {
+		return database.ErrNotBlacklisted
+	}
+	delete(t.blacklist, user)
+	return nil
+}
+
+func (t *TestDatabase) IsBlacklisted(user string) (bool, error) {
+	return t.blacklist[user], nil
+}
+
+func (t *TestDatabase) GetBlacklist() ([]string, error) {
+	users := make([]string, 0, len(t.blacklist))
+	for user := range t.blacklist {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+	return users, nil
+}
+
+// GetDigest ignores the time window, since the
+// test records do not have timestamps.
+func (t *TestDatabase) GetDigest(since, until time.Time, limit int) (*database.Digest, error) {
+	var (
+		digest = &database.Digest{
+			Since: since,
+			Until: until,
+		}
+		changes = make(map[string]int)
+		given   = make(map[string]int)
+	)
+
+	for _, r := range t.records {
+		if r.From == "janet" {
+			continue
+		}
+
+		changes[r.To] += r.Points
+		if r.Points > 0 && r.From != r.To {
+			given[r.From] += r.Points
+		}
+
+		if r.Points < 0 {
+			digest.Points -= r.Points
+		} else {
+			digest.Points += r.Points
+		}
+		digest.Operations++
+	}
+
+	leaderboard := func(points map[string]int, include func(int) bool, less func(a, b int) bool) database.Leaderboard {
+		var lb database.Leaderboard
+		for name, p := range points {
+			if include(p) {
+				lb = append(lb, &database.User{Name: name, Points: p})
+			}
+		}
+		sort.Slice(lb, func(i, j int) bool {
+			if lb[i].Points == lb[j].Points {
+				return lb[i].Name < lb[j].Name
+			}
+			return less(lb[i].Points, lb[j].Points)
+		})
+		if len(lb) > limit {
+			lb = lb[:limit]
+		}
+		return lb
+	}
+
+	abs := func(p int) int {
+		if p < 0 {
+			return -p
+		}
+		return p
+	}
+
+	digest.Movers = leaderboard(changes, func(p int) bool { return p != 0 }, func(a, b int) bool { return abs(a) > abs(b) })
+	digest.Gainers = leaderboard(changes, func(p int) bool { return p > 0 }, func(a, b int) bool { return a > b })
+	digest.Givers = leaderboard(given, func(p int) bool { return p > 0 }, func(a, b int) bool { return a > b })
+
+	return digest, nil
+}
+
+func (t *TestDatabase) QueueEvent(event string, payload []byte) error {
+	t.events = append(t.events, event)
+	return nil
+}
+
+func (t *TestDatabase) GetGivers(since, until time.Time, limit int) (database.Leaderboard, error) {
+	digest, err := t.GetDigest(since, until, limit)
+	if err != nil {
+		return nil, err
+	}
+	return digest.Givers, nil
+}
+
+func (t *TestDatabase) InsertAchievement(user, badge string) (bool, error) {
+	for _, b := range t.badges[user] {
+		if b == badge {
+			return false, nil
+		}
+	}
+	if t.badges == nil {
+		t.badges = make(map[string][]string)
+	}
+	t.badges[user] = append(t.badges[user], badge)
+	return true, nil
+}
+
+func (t *TestDatabase) GetAchievements(user string) ([]*database.Achievement, error) {
+	var achievements []*database.Achievement
+	for _, b := range t.badges[user] {
+		achievements = append(achievements, &database.Achievement{User: user, Badge: b})
+	}
+	return achievements, nil
+}
+
+// GetUserStats counts every day as today, since the
+// test records do not have timestamps.
+func (t *TestDatabase) GetUserStats(user string, now time.Time) (*database.UserStats, error) {
+	stats := &database.UserStats{}
+	for _, r := range t.records {
+		if r.To == user && r.Points > 0 && r.From != user && r.From != "janet" {
+			stats.Received++
+			stats.Streak = 1
+		}
+	}
+	return stats, nil
+}
+
+func (t *TestDatabase) GetRewards() ([]*database.Reward, error) {
+	return t.rewards, nil
+}
+
+func (t *TestDatabase) Redeem(user, reward string) (*database.Redemption, error) {
+	for _, r := range t.rewards {
+		if r.Name != reward {
+			continue
+		}
+
+		redemption := &database.Redemption{
+			ID:     int64(len(t.redemptions) + 1),
+			User:   user,
+			Reward: reward,
+			Cost:   r.Cost,
+			Status: database.RedemptionPending,
+		}
+
+		balance := 0
+		if u, err := t.GetUser(user); err == nil {
+			balance = u.Balance
+		}
+		if balance < r.Cost {
+			return redemption, database.ErrInsufficientPoints
+		}
+
+		t.redemptions = append(t.redemptions, redemption)
+		t.records = append(t.records, database.Points{
+			From:   "janet",
+			To:     user,
+			Points: -r.Cost,
+			Reason: fmt.Sprintf("redeeming %s (#%d)", reward, redemption.ID),
+			Type:   database.TypeSpent,
+		})
+
+		return redemption, nil
+	}
+
+	return nil, database.ErrNoSuchReward
+}
+
+func (t *TestDatabase) DecideRedemption(id int64, admin string, approve bool, note string) (*database.Redemption, error) {
+	if id < 1 || id > int64(len(t.redemptions)) {
+		return nil, database.ErrNoSuchRedemption
+	}
+
+	redemption := t.redemptions[id-1]
+	if redemption.Status != database.RedemptionPending {
+		return redemption, database.ErrAlreadyDecided
+	}
+
+	redemption.Admin, redemption.Note = admin, note
+	redemption.Status = database.RedemptionApproved
+	if !approve {
+		redemption.Status = database.RedemptionDenied
+		t.records = append(t.records, database.Points{
+			From:   "janet",
+			To:     redemption.User,
+			Points: redemption.Cost,
+			Reason: fmt.Sprintf("refunding %s (#%d)", redemption.Reward, id),
+			Type:   database.TypeSpent,
+		})
+	}
+
+	return redemption, nil
+}
+
+// Transfer counts all transfers as made in the past 24 hours,
+// since the test records do not have timestamps.
+func (t *TestDatabase) Transfer(points *database.Points, limit int) (*database.TransferSummary, error) {
+	summary := &database.TransferSummary{}
+	if u, err := t.GetUser(points.From); err == nil {
+		summary.Balance = u.Balance
+	}
+	for _, r := range t.records {
+		if r.Type == database.TypeTransfer && r.From == points.From && r.To == points.From {
+			summary.Given -= r.Points
+		}
+	}
+
+	if summary.Balance < points.Points {
+		return summary, database.ErrInsufficientPoints
+	}
+	if limit > 0 && summary.Given+points.Points > limit {
+		return summary, database.ErrTransferLimit
+	}
+
+	debit, credit := *points, *points
+	debit.To, debit.Points = points.From, -points.Points
+	debit.Type, credit.Type = database.TypeTransfer, database.TypeTransfer
+	t.records = append(t.records, debit, credit)
+
+	summary.Balance -= points.Points
+	summary.Given += points.Points
+
+	return summary, nil
+}
@@ -0,0 +1,154 @@
+package janet
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/troyxmccall/janet/database"
+	"github.com/troyxmccall/janet/persona"
+	"github.com/troyxmccall/janet/schedule"
+)
+
+// digestLimit is the amount of users listed in each
+// leaderboard of a digest.
+const digestLimit = 5
+
+// digestWindows are the time windows that a digest can cover.
+var digestWindows = map[string]func(until time.Time) time.Time{
+	"day":   func(until time.Time) time.Time { return until.AddDate(0, 0, -1) },
+	"week":  func(until time.Time) time.Time { return until.AddDate(0, 0, -7) },
+	"month": func(until time.Time) time.Time { return until.AddDate(0, -1, 0) },
+}
+
+// A Digest is a summary of the karma of the past day, week or month
+// that Good Janet posts to a channel on a schedule.
+type Digest struct {
+	Channel  string
+	Window   string
+	Schedule *schedule.Schedule
+}
+
+// ParseDigest parses a digest in the `<channel> <window> <schedule>`
+// format, e.g. `C0123ABCD week 0 9 * * mon`.
+func ParseDigest(spec string) (*Digest, error) {
+	fields := strings.Fields(spec)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("digest %q must have a channel, a window and a schedule", spec)
+	}
+
+	digest := &Digest{
+		Channel: fields[0],
+		Window:  strings.ToLower(fields[1]),
+	}
+
+	if _, ok := digestWindows[digest.Window]; !ok {
+		return nil, fmt.Errorf("digest %q: window must be day, week or month", spec)
+	}
+
+	var err error
+	digest.Schedule, err = schedule.Parse(strings.Join(fields[2:], " "))
+	if err != nil {
+		return nil, fmt.Errorf("digest %q: %v", spec, err)
+	}
+
+	return digest, nil
+}
+
+// runDigests posts every digest on its schedule until
+// the context is cancelled.
+func (b *Bot) runDigests(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, digest := range b.Config.Digests {
+		wg.Add(1)
+		go func(digest *Digest) {
+			defer wg.Done()
+			b.runDigest(ctx, digest)
+		}(digest)
+	}
+
+	wg.Wait()
+}
+
+func (b *Bot) runDigest(ctx context.Context, digest *Digest) {
+	for {
+		next := digest.Schedule.Next(time.Now())
+		if next.IsZero() {
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		err := b.SendDigest(digest, next)
+		if err != nil {
+			b.Config.Log.Err(err).KV("channel", digest.Channel).Error("could not send digest")
+		}
+	}
+}
+
+// SendDigest posts a digest of the window that ends at until.
+func (b *Bot) SendDigest(digest *Digest, until time.Time) error {
+	// digests are sent outside of the event handlers,
+	// so they need their own snapshot of the config
+	b = b.snapshot()
+
+	since := digestWindows[digest.Window](until)
+
+	summary, err := b.Config.DB.GetDigest(since, until, digestLimit)
+	if err != nil {
+		return err
+	}
+
+	b.SendMessage(b.formatDigest(digest.Window, summary), digest.Channel, "", "")
+	return nil
+}
+
+// formatDigest formats a digest as a Slack message
+// in the workspace's locale.
+func (b *Bot) formatDigest(window string, digest *database.Digest) string {
+	locale := b.Config.Locale
+	data := persona.Data{
+		"Window": locale.T("window." + window),
+		"Since":  locale.Date(digest.Since),
+		"Until":  locale.Date(digest.Until),
+	}
+
+	title := b.message(locale, "", persona.MessageDigest, data)
+	text := fmt.Sprintf("*%s* (%s - %s)\n", title, data["Since"], data["Until"])
+
+	if digest.Operations == 0 {
+		return text + b.message(locale, "", persona.MessageQuietDigest, persona.Data{"Window": data["Window"]}) + "\n"
+	}
+
+	sections := []struct {
+		title, line string
+		users       database.Leaderboard
+	}{
+		{"digest.movers", "digest.move", digest.Movers},
+		{"digest.gainers", "digest.move", digest.Gainers},
+		{"digest.givers", "digest.gave", digest.Givers},
+	}
+
+	for _, section := range sections {
+		if len(section.users) == 0 {
+			continue
+		}
+
+		text += fmt.Sprintf("\n*%s*\n", locale.T(section.title))
+		for i, user := range section.users {
+			text += locale.Render(section.line, false, leaderboardLine(i, user)) + "\n"
+		}
+	}
+
+	text += "\n" + locale.T("digest.total", "Points", digest.Points, "Operations", digest.Operations) + "\n"
+
+	return text
+}
@@ -0,0 +1,111 @@
+// Package health serves the /healthz and /readyz endpoints
+// that orchestrators use to probe janet.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// A Check reports whether a component is healthy
+// by returning nil.
+type Check func(ctx context.Context) error
+
+// A Checker runs a set of named checks.
+type Checker struct {
+	Timeout time.Duration
+
+	checks      map[string]Check
+	checksMutex sync.RWMutex
+}
+
+// A Report is the result of running all checks.
+type Report struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]Status `json:"checks"`
+}
+
+// Status is the result of a single check.
+type Status struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// New returns a new Checker without any checks.
+func New() *Checker {
+	return &Checker{
+		Timeout: 5 * time.Second,
+		checks:  make(map[string]Check),
+	}
+}
+
+// Add registers a named check.
+func (c *Checker) Add(name string, check Check) {
+	c.checksMutex.Lock()
+	c.checks[name] = check
+	c.checksMutex.Unlock()
+}
+
+// Run runs all checks and reports their results. Janet is
+// ready if every check passes.
+func (c *Checker) Run(ctx context.Context) *Report {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	c.checksMutex.RLock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.checksMutex.RUnlock()
+
+	report := &Report{
+		Ready:  true,
+		Checks: make(map[string]Status, len(checks)),
+	}
+
+	for name, check := range checks {
+		status := Status{OK: true}
+		if err := check(ctx); err != nil {
+			status = Status{OK: false, Error: err.Error()}
+			report.Ready = false
+		}
+
+		report.Checks[name] = status
+	}
+
+	return report
+}
+
+// LiveHandler serves /healthz. It always responds with 200 as
+// long as janet is running, and includes the check results so
+// that they can be inspected.
+func (c *Checker) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, http.StatusOK, c.Run(r.Context()))
+	})
+}
+
+// ReadyHandler serves /readyz. It responds with 503 if any
+// check fails.
+func (c *Checker) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := c.Run(r.Context())
+
+		code := http.StatusOK
+		if !report.Ready {
+			code = http.StatusServiceUnavailable
+		}
+
+		writeReport(w, code, report)
+	})
+}
+
+func writeReport(w http.ResponseWriter, code int, report *Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
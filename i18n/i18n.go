@@ -0,0 +1,347 @@
+// Package i18n translates janet's replies and web UI. Every locale
+// has a message catalog in the catalogs directory, containing its
+// messages as Go templates, the names of the months and the
+// thousands separator. Messages that are missing from a catalog
+// fall back to English.
+package i18n
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultTag is the tag of the default locale.
+const DefaultTag = "en"
+
+//go:embed catalogs/*.yaml
+var catalogs embed.FS
+
+// catalog is the format of a message catalog.
+type catalog struct {
+	Thousands string            `yaml:"thousands"`
+	Months    []string          `yaml:"months"`
+	Messages  map[string]string `yaml:"messages"`
+}
+
+// A Locale formats messages, numbers and dates for a language.
+type Locale struct {
+	tag       string
+	thousands string
+	months    []string
+	plural    func(n int) int
+	messages  map[string]*template.Template
+	fallback  *Locale
+}
+
+var locales = make(map[string]*Locale)
+
+func init() {
+	files, err := catalogs.ReadDir("catalogs")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, file := range files {
+		tag := strings.TrimSuffix(file.Name(), path.Ext(file.Name()))
+
+		data, err := catalogs.ReadFile(path.Join("catalogs", file.Name()))
+		if err != nil {
+			panic(err)
+		}
+
+		locale, err := parseCatalog(tag, data)
+		if err != nil {
+			panic(fmt.Sprintf("catalog %s: %v", file.Name(), err))
+		}
+
+		locales[tag] = locale
+	}
+
+	for tag, locale := range locales {
+		if tag == DefaultTag {
+			continue
+		}
+
+		locale.fallback = locales[DefaultTag]
+		for key := range locale.messages {
+			if _, ok := locale.fallback.messages[key]; !ok {
+				panic(fmt.Sprintf("catalog %s: unknown message %q", tag, key))
+			}
+		}
+	}
+}
+
+func parseCatalog(tag string, data []byte) (*Locale, error) {
+	c := &catalog{}
+	err := yaml.UnmarshalStrict(data, c)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(c.Months) != 12 {
+		return nil, fmt.Errorf("expected 12 months, got %d", len(c.Months))
+	}
+
+	locale := &Locale{
+		tag:       tag,
+		thousands: c.Thousands,
+		months:    c.Months,
+		plural:    pluralRule(tag),
+		messages:  make(map[string]*template.Template),
+	}
+
+	for key, message := range c.Messages {
+		locale.messages[key], err = Parse(key, message)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return locale, nil
+}
+
+// Default returns the default locale.
+func Default() *Locale {
+	return locales[DefaultTag]
+}
+
+// Tags returns the tags of all supported locales.
+func Tags() []string {
+	tags := make([]string, 0, len(locales))
+	for tag := range locales {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	return tags
+}
+
+// Match returns the locale for a tag like `de`, `de-DE` or `de_DE`,
+// or nil if the language is not supported.
+func Match(tag string) *Locale {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+
+	return locales[tag]
+}
+
+// Lookup returns the locale for a tag, or an error
+// if the language is not supported.
+func Lookup(tag string) (*Locale, error) {
+	locale := Match(tag)
+	if locale == nil {
+		return nil, fmt.Errorf("unsupported locale %q, please use one of %s", tag, strings.Join(Tags(), ", "))
+	}
+
+	return locale, nil
+}
+
+// AcceptLanguage returns the first supported locale of an
+// Accept-Language header, or the fallback if there is none.
+func AcceptLanguage(header string, fallback *Locale) *Locale {
+	for _, tag := range strings.Split(header, ",") {
+		if i := strings.Index(tag, ";"); i >= 0 {
+			tag = tag[:i]
+		}
+
+		if locale := Match(tag); locale != nil {
+			return locale
+		}
+	}
+
+	return fallback
+}
+
+// Tag returns the language tag of the locale, e.g. `en`.
+func (l *Locale) Tag() string {
+	return l.tag
+}
+
+// Parse parses a message template. The templates may use the
+// bold, number, signed and plural functions, which format their
+// arguments for the locale that the template is executed with.
+func Parse(name, message string) (*template.Template, error) {
+	// the functions are replaced by the locale's in Execute
+	return template.New(name).
+		Option("missingkey=error").
+		Funcs(new(Locale).funcs(false)).
+		Parse(message)
+}
+
+func (l *Locale) funcs(markdown bool) template.FuncMap {
+	return template.FuncMap{
+		"bold": func(v interface{}) string {
+			if markdown {
+				return fmt.Sprintf("*%v*", v)
+			}
+			return fmt.Sprint(v)
+		},
+		"number": l.Number,
+		"signed": l.Signed,
+		"plural": l.Plural,
+	}
+}
+
+// Template returns the template of a message, or nil
+// if there is no such message.
+func (l *Locale) Template(key string) *template.Template {
+	if t, ok := l.messages[key]; ok {
+		return t
+	}
+
+	if l.fallback != nil {
+		return l.fallback.Template(key)
+	}
+
+	return nil
+}
+
+// Execute executes a message template for the locale. If markdown
+// is set, bold text is formatted with Slack markdown.
+func (l *Locale) Execute(t *template.Template, markdown bool, data interface{}) (string, error) {
+	t, err := t.Clone()
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = t.Funcs(l.funcs(markdown)).Execute(&buf, data)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// Render renders a message of the catalog. If the message does not
+// exist or cannot be rendered, its key is returned instead.
+func (l *Locale) Render(key string, markdown bool, data interface{}) string {
+	t := l.Template(key)
+	if t == nil {
+		return key
+	}
+
+	text, err := l.Execute(t, markdown, data)
+	if err != nil {
+		return key
+	}
+
+	return text
+}
+
+// T renders a message as plain text. The fields of the message
+// are passed as pairs of names and values, which makes it easy
+// to call from HTML templates:
+//
+//	{{ .Locale.T "web.top" "Limit" 10 }}
+func (l *Locale) T(key string, fields ...interface{}) string {
+	data := make(map[string]interface{}, len(fields)/2)
+	for i := 0; i+1 < len(fields); i += 2 {
+		data[fmt.Sprint(fields[i])] = fields[i+1]
+	}
+
+	return l.Render(key, false, data)
+}
+
+// Number formats an integer with the locale's thousands separator.
+func (l *Locale) Number(n int) string {
+	digits := strconv.Itoa(n)
+
+	sign := ""
+	if n < 0 {
+		sign, digits = "-", digits[1:]
+	}
+
+	var groups []string
+	for len(digits) > 3 {
+		groups = append([]string{digits[len(digits)-3:]}, groups...)
+		digits = digits[:len(digits)-3]
+	}
+	groups = append([]string{digits}, groups...)
+
+	return sign + strings.Join(groups, l.thousands)
+}
+
+// Signed formats an integer like Number, with a plus
+// sign if it is positive.
+func (l *Locale) Signed(n int) string {
+	if n > 0 {
+		return "+" + l.Number(n)
+	}
+
+	return l.Number(n)
+}
+
+// Plural returns the plural form to use for n, according to the
+// locale's plural rule. The forms are passed in the order of the
+// rule, e.g. `plural n "point" "points"` in English.
+func (l *Locale) Plural(n int, forms ...string) string {
+	if len(forms) == 0 {
+		return ""
+	}
+
+	i := l.plural(n)
+	if i >= len(forms) {
+		i = len(forms) - 1
+	}
+
+	return forms[i]
+}
+
+// Date formats the day and month of a date, e.g. `Jan 2`.
+func (l *Locale) Date(t time.Time) string {
+	return l.Render("date", false, map[string]interface{}{
+		"Day":   t.Day(),
+		"Month": l.months[t.Month()-1],
+		"Year":  t.Year(),
+	})
+}
+
+// Month formats the month and year of a date, e.g. `Jan 2019`.
+func (l *Locale) Month(t time.Time) string {
+	return l.Render("month", false, map[string]interface{}{
+		"Month": l.months[t.Month()-1],
+		"Year":  t.Year(),
+	})
+}
+
+// timeUnits are the units of relative times, largest first.
+var timeUnits = []struct {
+	name     string
+	duration time.Duration
+}{
+	{"year", 365 * 24 * time.Hour},
+	{"month", 30 * 24 * time.Hour},
+	{"week", 7 * 24 * time.Hour},
+	{"day", 24 * time.Hour},
+	{"hour", time.Hour},
+	{"minute", time.Minute},
+	{"second", time.Second},
+}
+
+// Ago formats the time that passed between t and now,
+// e.g. `3 days ago`, using the largest fitting unit.
+func (l *Locale) Ago(t, now time.Time) string {
+	passed := now.Sub(t)
+
+	for _, unit := range timeUnits {
+		if passed >= unit.duration {
+			return l.Render("ago."+unit.name, false, map[string]interface{}{
+				"N": int(passed / unit.duration),
+			})
+		}
+	}
+
+	return l.Render("now", false, nil)
+}
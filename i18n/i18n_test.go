@@ -0,0 +1,79 @@
+package i18n
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlural(t *testing.T) {
+	for _, tc := range []struct {
+		tag   string
+		forms map[int]string
+	}{
+		{
+			tag:   "en",
+			forms: map[int]string{0: "other", 1: "one", -1: "one", 2: "other", 21: "other"},
+		},
+		{
+			tag:   "de",
+			forms: map[int]string{0: "other", 1: "one", -1: "one", 2: "other", 21: "other"},
+		},
+		{
+			tag:   "es",
+			forms: map[int]string{0: "other", 1: "one", -1: "one", 2: "other", 21: "other"},
+		},
+		{
+			// french uses the singular for 0
+			tag:   "fr",
+			forms: map[int]string{0: "one", 1: "one", -1: "one", 2: "other", 21: "other"},
+		},
+	} {
+		locale := Match(tc.tag)
+		if locale == nil {
+			t.Errorf("%s: has no catalog", tc.tag)
+			continue
+		}
+
+		for n, want := range tc.forms {
+			if got := locale.Plural(n, "one", "other"); got != want {
+				t.Errorf("%s: picked %q for %d; want %q", tc.tag, got, n, want)
+			}
+		}
+	}
+}
+
+func TestPluralRules(t *testing.T) {
+	// every catalog has a plural rule, and there are
+	// no rules for languages without a catalog
+	for tag := range pluralRules {
+		if Match(tag) == nil {
+			t.Errorf("%s: has a plural rule, but no catalog", tag)
+		}
+	}
+
+	for _, tag := range Tags() {
+		if _, ok := pluralRules[tag]; !ok {
+			t.Errorf("%s: has a catalog, but no plural rule", tag)
+		}
+	}
+}
+
+func TestPluralMessages(t *testing.T) {
+	for tag, want := range map[string][]string{
+		"en": {"0 minutes ago", "1 minute ago", "2 minutes ago"},
+		"de": {"vor 0 Minuten", "vor 1 Minute", "vor 2 Minuten"},
+		"es": {"hace 0 minutos", "hace 1 minuto", "hace 2 minutos"},
+		"fr": {"il y a 0 minute", "il y a 1 minute", "il y a 2 minutes"},
+	} {
+		locale := Match(tag)
+
+		var got []string
+		for n := 0; n < 3; n++ {
+			got = append(got, locale.T("ago.minute", "N", n))
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: rendered %q; want %q", tag, got, want)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package i18n
+
+// pluralRules return the index of the plural form to use for n,
+// following the CLDR plural rules for integers, for the languages
+// that have a catalog. Languages that are not listed use the
+// English rule.
+var pluralRules = map[string]func(n int) int{
+	// one: 1, other
+	"en": oneOther,
+	"de": oneOther,
+	"es": oneOther,
+
+	// one: 0 and 1, other
+	"fr": func(n int) int {
+		if n == 0 || n == 1 || n == -1 {
+			return 0
+		}
+		return 1
+	},
+}
+
+func pluralRule(tag string) func(n int) int {
+	if rule, ok := pluralRules[tag]; ok {
+		return rule
+	}
+
+	return oneOther
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func oneOther(n int) int {
+	if abs(n) == 1 {
+		return 0
+	}
+	return 1
+}
@@ -0,0 +1,85 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/troyxmccall/janet/database"
+)
+
+// hubotPlusPlus is the part of a hubot brain
+// that is used by hubot-plusplus.
+type hubotPlusPlus struct {
+	Scores  map[string]float64            `json:"scores"`
+	Reasons map[string]map[string]float64 `json:"reasons"`
+}
+
+// hubotBrain is a dump of hubot's brain. Depending on how it was
+// exported, the plusplus data is either at the top level or in `data`.
+type hubotBrain struct {
+	PlusPlus *hubotPlusPlus `json:"plusPlus"`
+	Data     *struct {
+		PlusPlus *hubotPlusPlus `json:"plusPlus"`
+	} `json:"data"`
+}
+
+// HubotPlusPlus imports the scores and reasons from a hubot-plusplus
+// brain.json. Every reason becomes a record with the points given for
+// it, and a record without a reason makes up for the rest of the
+// user's score. hubot-plusplus does not keep timestamps or givers, so
+// all records use opts.Timestamp, or Epoch, and opts.From.
+func HubotPlusPlus(r io.Reader, opts *Options) ([]*database.Record, error) {
+	var brain hubotBrain
+	err := json.NewDecoder(r).Decode(&brain)
+	if err != nil {
+		return nil, err
+	}
+
+	pp := brain.PlusPlus
+	if pp == nil && brain.Data != nil {
+		pp = brain.Data.PlusPlus
+	}
+	if pp == nil {
+		return nil, errors.New("brain does not contain any hubot-plusplus data")
+	}
+
+	users := make([]string, 0, len(pp.Scores))
+	for user := range pp.Scores {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+
+	var records []*database.Record
+	for _, user := range users {
+		var (
+			name    = strings.ToLower(strings.TrimPrefix(user, "@"))
+			score   = int(math.Round(pp.Scores[user]))
+			reasons = make([]string, 0, len(pp.Reasons[user]))
+		)
+
+		for reason := range pp.Reasons[user] {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+
+		for _, reason := range reasons {
+			points := int(math.Round(pp.Reasons[user][reason]))
+			if points == 0 {
+				continue
+			}
+
+			records = append(records, newRecord(opts, name, reason, points))
+			score -= points
+		}
+
+		if score != 0 {
+			records = append(records, newRecord(opts, name, "", score))
+		}
+	}
+
+	return records, nil
+}
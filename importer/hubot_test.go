@@ -0,0 +1,102 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/troyxmccall/janet/database"
+)
+
+func TestHubotPlusPlus(t *testing.T) {
+	timestamp := time.Date(2019, 1, 14, 9, 0, 0, 0, time.UTC)
+	record := func(to, reason string, points int, timestamp time.Time) *database.Record {
+		return &database.Record{
+			Points: database.Points{
+				From:   "hubot",
+				To:     to,
+				Reason: reason,
+				Points: points,
+			},
+			Timestamp: timestamp,
+		}
+	}
+
+	for _, tc := range []struct {
+		name      string
+		brain     string
+		timestamp time.Time
+		want      []*database.Record
+		err       bool
+	}{
+		{
+			name:      "top level",
+			brain:     `{"plusPlus": {"scores": {"troy": 5, "@Janet": 2}, "reasons": {"troy": {"for the review": 3, "for nothing": 0}}}}`,
+			timestamp: timestamp,
+			want: []*database.Record{
+				record("janet", "", 2, timestamp),
+				record("troy", "for the review", 3, timestamp),
+				record("troy", "", 2, timestamp),
+			},
+		},
+		{
+			name:  "in data",
+			brain: `{"data": {"plusPlus": {"scores": {"troy": -1.6}, "reasons": {"troy": {"for breaking the build": -2}}}}}`,
+			want: []*database.Record{
+				record("troy", "for breaking the build", -2, Epoch),
+			},
+		},
+		{
+			name:  "reasons add up to the score",
+			brain: `{"plusPlus": {"scores": {"troy": 4}, "reasons": {"troy": {"a": 1, "b": 3}}}}`,
+			want: []*database.Record{
+				record("troy", "a", 1, Epoch),
+				record("troy", "b", 3, Epoch),
+			},
+		},
+		{
+			name:  "no plusplus data",
+			brain: `{"data": {"users": {}}}`,
+			err:   true,
+		},
+		{
+			name:  "invalid json",
+			brain: `{"plusPlus": `,
+			err:   true,
+		},
+	} {
+		records, err := HubotPlusPlus(strings.NewReader(tc.brain), &Options{From: "hubot", Timestamp: tc.timestamp})
+		if tc.err {
+			if err == nil {
+				t.Errorf("%s: returned %+v; want an error", tc.name, records)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: returned error %v", tc.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(records, tc.want) {
+			t.Errorf("%s: returned %+v; want %+v", tc.name, records, tc.want)
+		}
+	}
+}
+
+func TestHubotPlusPlusIsStable(t *testing.T) {
+	brain := `{"plusPlus": {"scores": {"troy": 5, "janet": 2}, "reasons": {"troy": {"a": 1, "b": 2}}}}`
+
+	first, err := HubotPlusPlus(strings.NewReader(brain), &Options{From: "hubot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := HubotPlusPlus(strings.NewReader(brain), &Options{From: "hubot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("importing twice returned %+v and %+v; want the same records", first, second)
+	}
+}
@@ -0,0 +1,83 @@
+// Package importer converts karma from other bots
+// into janet karma records.
+package importer
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/troyxmccall/janet/database"
+)
+
+// Options are passed to every importer.
+type Options struct {
+	// From is used as the giver of all imported records,
+	// so that they can be told apart from janet's own.
+	From string
+
+	// Timestamp is used for records whose original time
+	// is unknown, Epoch if it is zero. It must be the same
+	// across runs for re-imports to be skipped.
+	Timestamp time.Time
+}
+
+// Epoch is the timestamp of records whose original time is unknown
+// if no other timestamp is passed. It does not depend on when or
+// from which file the karma is imported, so importing the same
+// data twice skips the records of the first import.
+var Epoch = time.Unix(0, 0).UTC()
+
+// An Importer reads another bot's data and returns it as karma records.
+type Importer func(r io.Reader, opts *Options) ([]*database.Record, error)
+
+// ErrNoSuchImporter is returned when looking up an unknown importer.
+var ErrNoSuchImporter = errors.New("no such importer")
+
+var importers = map[string]Importer{
+	"hubot-plusplus": HubotPlusPlus,
+}
+
+// Register adds an importer under the given name.
+func Register(name string, importer Importer) {
+	importers[name] = importer
+}
+
+// Get returns the importer with the given name.
+func Get(name string) (Importer, error) {
+	importer, ok := importers[name]
+	if !ok {
+		return nil, ErrNoSuchImporter
+	}
+
+	return importer, nil
+}
+
+// Names returns the names of all importers in alphabetical order.
+func Names() []string {
+	names := make([]string, 0, len(importers))
+	for name := range importers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func newRecord(opts *Options, to, reason string, points int) *database.Record {
+	timestamp := opts.Timestamp
+	if timestamp.IsZero() {
+		timestamp = Epoch
+	}
+
+	return &database.Record{
+		Points: database.Points{
+			From:   opts.From,
+			To:     to,
+			Reason: reason,
+			Points: points,
+		},
+		Timestamp: timestamp,
+	}
+}
@@ -0,0 +1,236 @@
+// Package karmaio reads and writes karma records in
+// portable file formats.
+package karmaio
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/troyxmccall/janet/database"
+)
+
+// The supported formats.
+const (
+	CSV   = "csv"
+	JSONL = "jsonl"
+)
+
+// ErrUnknownFormat is returned for formats other than CSV and JSONL.
+var ErrUnknownFormat = errors.New("unknown format, please use csv or jsonl")
+
+// header is the first line of CSV files.
+var header = []string{"from", "to", "points", "reason", "timestamp", "type", "message"}
+
+// legacyHeader is the header of CSV files written before records
+// had a type and a message. Their records are read without either.
+var legacyHeader = header[:5]
+
+// row is the JSONL representation of a record.
+type row struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Points    int       `json:"points"`
+	Reason    string    `json:"reason"`
+	Timestamp time.Time `json:"timestamp"`
+	Type      string    `json:"type,omitempty"`
+	Message   string    `json:"message,omitempty"`
+}
+
+// FormatFromPath guesses the format from a file extension,
+// falling back to CSV.
+func FormatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".json", ".ndjson":
+		return JSONL
+	default:
+		return CSV
+	}
+}
+
+// Write writes records to w in the given format.
+// Timestamps are written in UTC as RFC 3339. The
+// records' types and messages are written as well,
+// so that reading them back does not lose either.
+func Write(w io.Writer, format string, records []*database.Record) error {
+	switch format {
+	case CSV:
+		cw := csv.NewWriter(w)
+		err := cw.Write(header)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range records {
+			err := cw.Write([]string{
+				r.From,
+				r.To,
+				strconv.Itoa(r.Points.Points),
+				r.Reason,
+				r.Timestamp.UTC().Format(time.RFC3339),
+				r.Type,
+				r.Message,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		cw.Flush()
+		return cw.Error()
+
+	case JSONL:
+		enc := json.NewEncoder(w)
+		for _, r := range records {
+			err := enc.Encode(&row{
+				From:      r.From,
+				To:        r.To,
+				Points:    r.Points.Points,
+				Reason:    r.Reason,
+				Timestamp: r.Timestamp.UTC(),
+				Type:      r.Type,
+				Message:   r.Message,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+
+	default:
+		return ErrUnknownFormat
+	}
+}
+
+// Read reads all records from r in the given format. CSV files
+// written without types and messages are read as well.
+func Read(r io.Reader, format string) ([]*database.Record, error) {
+	switch format {
+	case CSV:
+		return readCSV(r)
+	case JSONL:
+		return readJSONL(r)
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+func readCSV(r io.Reader) ([]*database.Record, error) {
+	// the header sets the amount of fields of all rows
+	cr := csv.NewReader(r)
+
+	first, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	if got := strings.Join(first, ","); got != strings.Join(header, ",") && got != strings.Join(legacyHeader, ",") {
+		return nil, fmt.Errorf("invalid csv header %q, want %q", got, strings.Join(header, ","))
+	}
+
+	var (
+		records []*database.Record
+		n       = 1
+	)
+
+	for {
+		n++
+		fields, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		points, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid points: %v", n, err)
+		}
+
+		timestamp, err := time.Parse(time.RFC3339, fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid timestamp: %v", n, err)
+		}
+
+		record := newRecord(fields[0], fields[1], fields[3], points, timestamp)
+		if len(fields) == len(header) {
+			record.Type, record.Message = fields[5], fields[6]
+		}
+		if err := validate(record); err != nil {
+			return nil, fmt.Errorf("row %d: %v", n, err)
+		}
+
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+func readJSONL(r io.Reader) ([]*database.Record, error) {
+	var (
+		records []*database.Record
+		scanner = bufio.NewScanner(r)
+		line    = 0
+	)
+
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		var rw row
+		err := json.Unmarshal(scanner.Bytes(), &rw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		record := newRecord(rw.From, rw.To, rw.Reason, rw.Points, rw.Timestamp)
+		record.Type, record.Message = rw.Type, rw.Message
+		if err := validate(record); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+
+		records = append(records, record)
+	}
+
+	return records, scanner.Err()
+}
+
+func newRecord(from, to, reason string, points int, timestamp time.Time) *database.Record {
+	return &database.Record{
+		Points: database.Points{
+			From:   from,
+			To:     to,
+			Reason: reason,
+			Points: points,
+		},
+		Timestamp: timestamp,
+	}
+}
+
+func validate(record *database.Record) error {
+	switch {
+	case record.From == "" || record.To == "":
+		return errors.New("record is missing `from` or `to`")
+	case record.Timestamp.IsZero():
+		return errors.New("record is missing a timestamp")
+	}
+
+	switch record.Type {
+	case "", database.TypeEarned, database.TypeSpent, database.TypeAdjustment, database.TypeMigration, database.TypeTransfer:
+	default:
+		return fmt.Errorf("unknown record type %q", record.Type)
+	}
+
+	return nil
+}
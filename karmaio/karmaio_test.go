@@ -0,0 +1,247 @@
+package karmaio
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/troyxmccall/janet/database"
+)
+
+func record(from, to string, points int, reason, kind, message string) *database.Record {
+	return &database.Record{
+		Points: database.Points{
+			From:    from,
+			To:      to,
+			Points:  points,
+			Reason:  reason,
+			Type:    kind,
+			Message: message,
+		},
+		Timestamp: time.Date(2019, 1, 14, 9, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		records []*database.Record
+	}{
+		{
+			name: "types and messages",
+			records: []*database.Record{
+				record("troy", "janet", 2, "for being helpful", database.TypeEarned, "C0123/1547456400.000100"),
+				record("troy", "troy", -5, "for the review", database.TypeTransfer, "C0123/1547456400.000200"),
+				record("troy", "janet", 5, "for the review", database.TypeTransfer, "C0123/1547456400.000200"),
+				record("janet", "troy", -10, "redeeming coffee", database.TypeSpent, ""),
+				record("janet", "troy", 3, "overriding karma", database.TypeAdjustment, ""),
+				record("janet", "alex", 7, "migrating karma from troy to alex", database.TypeMigration, ""),
+			},
+		},
+		{
+			name: "without types",
+			records: []*database.Record{
+				record("troy", "janet", 1, "", "", ""),
+			},
+		},
+		{
+			name: "quoted reasons",
+			records: []*database.Record{
+				record("troy", "janet", 1, `for "quoting", commas`, "", ""),
+				record("troy", "janet", 1, "for\nnew lines", "", ""),
+				record("troy", "janet", 1, "for emoji :tada: 🎉", "", ""),
+			},
+		},
+	} {
+		for _, format := range []string{CSV, JSONL} {
+			var buf bytes.Buffer
+			if err := Write(&buf, format, tc.records); err != nil {
+				t.Errorf("%s, %s: Write: %v", tc.name, format, err)
+				continue
+			}
+
+			read, err := Read(&buf, format)
+			if err != nil {
+				t.Errorf("%s, %s: Read: %v", tc.name, format, err)
+				continue
+			}
+			if !reflect.DeepEqual(read, tc.records) {
+				t.Errorf("%s, %s: read %+v; want %+v", tc.name, format, read, tc.records)
+			}
+		}
+	}
+}
+
+func TestWriteUTC(t *testing.T) {
+	r := record("troy", "janet", 1, "", "", "")
+	r.Timestamp = time.Date(2019, 1, 14, 10, 0, 0, 0, time.FixedZone("CET", 3600))
+
+	for _, format := range []string{CSV, JSONL} {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, []*database.Record{r}); err != nil {
+			t.Fatalf("%s: Write: %v", format, err)
+		}
+
+		if !strings.Contains(buf.String(), "2019-01-14T09:00:00Z") {
+			t.Errorf("%s: wrote %q; want the timestamp in UTC", format, buf.String())
+		}
+	}
+}
+
+func TestReadLegacyCSV(t *testing.T) {
+	in := "from,to,points,reason,timestamp\ntroy,janet,2,for being helpful,2019-01-14T09:00:00Z\n"
+
+	read, err := Read(strings.NewReader(in), CSV)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	want := []*database.Record{record("troy", "janet", 2, "for being helpful", "", "")}
+	if !reflect.DeepEqual(read, want) {
+		t.Errorf("read %+v; want %+v", read, want)
+	}
+}
+
+func TestReadMalformed(t *testing.T) {
+	const csvHeader = "from,to,points,reason,timestamp,type,message\n"
+
+	for _, tc := range []struct {
+		name   string
+		format string
+		in     string
+		err    string
+	}{
+		{
+			name:   "empty csv",
+			format: CSV,
+			in:     "",
+			err:    "EOF",
+		},
+		{
+			name:   "invalid header",
+			format: CSV,
+			in:     "giver,receiver,points\ntroy,janet,1\n",
+			err:    "invalid csv header",
+		},
+		{
+			name:   "missing fields",
+			format: CSV,
+			in:     csvHeader + "troy,janet,1\n",
+			err:    "wrong number of fields",
+		},
+		{
+			name:   "legacy row in a new file",
+			format: CSV,
+			in:     csvHeader + "troy,janet,1,,2019-01-14T09:00:00Z\n",
+			err:    "wrong number of fields",
+		},
+		{
+			name:   "invalid points",
+			format: CSV,
+			in:     csvHeader + "troy,janet,lots,,2019-01-14T09:00:00Z,,\n",
+			err:    "row 2: invalid points",
+		},
+		{
+			name:   "invalid timestamp",
+			format: CSV,
+			in:     csvHeader + "troy,janet,1,,2019-01-14T09:00:00Z,,\ntroy,janet,1,,yesterday,,\n",
+			err:    "row 3: invalid timestamp",
+		},
+		{
+			name:   "missing to in csv",
+			format: CSV,
+			in:     csvHeader + "troy,,1,,2019-01-14T09:00:00Z,,\n",
+			err:    "row 2: record is missing `from` or `to`",
+		},
+		{
+			name:   "unknown type in csv",
+			format: CSV,
+			in:     csvHeader + "troy,janet,1,,2019-01-14T09:00:00Z,stolen,\n",
+			err:    `row 2: unknown record type "stolen"`,
+		},
+		{
+			name:   "invalid json",
+			format: JSONL,
+			in:     `{"from": "troy", "to": "janet", "points": 1, "timestamp": "2019-01-14T09:00:00Z"}` + "\n" + `{"from": "troy",`,
+			err:    "line 2:",
+		},
+		{
+			name:   "invalid points in json",
+			format: JSONL,
+			in:     `{"from": "troy", "to": "janet", "points": "1", "timestamp": "2019-01-14T09:00:00Z"}`,
+			err:    "line 1:",
+		},
+		{
+			name:   "missing from in json",
+			format: JSONL,
+			in:     `{"to": "janet", "points": 1, "timestamp": "2019-01-14T09:00:00Z"}`,
+			err:    "line 1: record is missing `from` or `to`",
+		},
+		{
+			name:   "missing timestamp in json",
+			format: JSONL,
+			in:     `{"from": "troy", "to": "janet", "points": 1}`,
+			err:    "line 1: record is missing a timestamp",
+		},
+		{
+			name:   "unknown type in json",
+			format: JSONL,
+			in:     `{"from": "troy", "to": "janet", "points": 1, "timestamp": "2019-01-14T09:00:00Z", "type": "stolen"}`,
+			err:    `line 1: unknown record type "stolen"`,
+		},
+		{
+			name:   "unknown format",
+			format: "xml",
+			in:     "<karma/>",
+			err:    ErrUnknownFormat.Error(),
+		},
+	} {
+		records, err := Read(strings.NewReader(tc.in), tc.format)
+		if err == nil {
+			t.Errorf("%s: read %+v; want an error", tc.name, records)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: returned error %q; want it to contain %q", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestReadSkipsBlankLines(t *testing.T) {
+	in := "\n" + `{"from": "troy", "to": "janet", "points": 2, "reason": "for being helpful", "timestamp": "2019-01-14T09:00:00Z"}` + "\n\n"
+
+	read, err := Read(strings.NewReader(in), JSONL)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	want := []*database.Record{record("troy", "janet", 2, "for being helpful", "", "")}
+	if !reflect.DeepEqual(read, want) {
+		t.Errorf("read %+v; want %+v", read, want)
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, "xml", nil); err != ErrUnknownFormat {
+		t.Errorf("Write: returned %v; want %v", err, ErrUnknownFormat)
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	for path, want := range map[string]string{
+		"karma.csv":     CSV,
+		"karma.jsonl":   JSONL,
+		"karma.JSON":    JSONL,
+		"karma.ndjson":  JSONL,
+		"karma":         CSV,
+		"/tmp/karma.gz": CSV,
+	} {
+		if got := FormatFromPath(path); got != want {
+			t.Errorf("FormatFromPath(%q) = %q; want %q", path, got, want)
+		}
+	}
+}
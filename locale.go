@@ -0,0 +1,27 @@
+package janet
+
+import (
+	"github.com/troyxmccall/janet/i18n"
+)
+
+// locale returns the locale of a Slack user, or the workspace's
+// locale if janet does not speak the user's language.
+func (b *Bot) locale(user string) *i18n.Locale {
+	tag, ok := b.userLocales.Load(user)
+	if !ok {
+		info, err := b.Config.Slack.GetUserInfo(user)
+		if err != nil {
+			b.Config.Log.Err(err).KV("user", user).Info("could not look up locale")
+			return b.Config.Locale
+		}
+
+		tag = info.Locale
+		b.userLocales.Store(user, tag)
+	}
+
+	if locale := i18n.Match(tag.(string)); locale != nil {
+		return locale
+	}
+
+	return b.Config.Locale
+}
@@ -1,25 +1,32 @@
 package janet
 
 import (
+  "context"
+  "errors"
   "fmt"
   "reflect"
   "regexp"
   "strconv"
   "strings"
   "sync"
+  "time"
 
+  "github.com/troyxmccall/janet/achievement"
   "github.com/troyxmccall/janet/database"
+  "github.com/troyxmccall/janet/i18n"
+  "github.com/troyxmccall/janet/metrics"
   "github.com/troyxmccall/janet/munge"
+  "github.com/troyxmccall/janet/persona"
   "github.com/troyxmccall/janet/ui"
+  "github.com/troyxmccall/janet/webhook"
 
   "github.com/aybabtme/log"
-  "github.com/dustin/go-humanize"
   "github.com/nlopes/slack"
 )
 
 var (
   regexps = struct {
-    Motivate, GivePoints, TakePoints, QueryPoints, Leaderboard, URL, SlackUser, Throwback *regexp.Regexp
+    Motivate, GivePoints, TakePoints, QueryPoints, Leaderboard, URL, SlackUser, Throwback, Alias, Blacklist, Roast, Bottom, Rewards, Redeem, Give *regexp.Regexp
   }{
     Motivate:    karmaReg.MatchMotivate(),
     GivePoints:  karmaReg.MatchGive(),
@@ -29,6 +36,13 @@ var (
     URL:         regexp.MustCompile(`^janet(?:bot)? (?:url|web|link)?$`),
     SlackUser:   regexp.MustCompile(`^<@([A-Za-z0-9]+)>$`),
     Throwback:   karmaReg.MatchThrowback(),
+    Alias:       regexp.MustCompile(`^janet(?:bot)? alias (add|remove|list)(?: +(\S+))?(?: +(\S+))?$`),
+    Blacklist:   regexp.MustCompile(`^janet(?:bot)? blacklist (add|remove|list)(?: +(\S+))?$`),
+    Roast:       regexp.MustCompile(`^(?:<@([A-Za-z0-9]+)>:?|badjanet) roast(?: +(\S+))?$`),
+    Bottom:      regexp.MustCompile(`^(?:<@([A-Za-z0-9]+)>:?|badjanet) (?:bottom|lowscores) ?([0-9]+)?$`),
+    Rewards:     regexp.MustCompile(`^janet(?:bot)? (?:rewards|store)$`),
+    Redeem:      regexp.MustCompile(`^janet(?:bot)? redeem(?: +(approve|deny) +#?([0-9]+)(?: +(.+))?| +(\S+))?$`),
+    Give:        regexp.MustCompile(`^janet(?:bot)? (?:give|tip)(?: +(\S+?):?(?: +([0-9]+))?(?: +from +my +balance)?(?: +(?:for +)?(.+?))?)? *$`),
   }
 )
 
@@ -43,11 +57,69 @@ type Database interface {
   // GetLeaderboard returns the top X users with the most points, in order.
   GetLeaderboard(limit int) (database.Leaderboard, error)
 
+  // GetBottom returns the top X users who had the most points taken, in order.
+  GetBottom(limit int) (database.Leaderboard, error)
+
   // GetTotalPoints returns the total number of points transferred across all users.
   GetTotalPoints() (int, error)
 
   // GetThrowback returns a random karma operation on a specific user.
   GetThrowback(user string) (*database.Throwback, error)
+
+  // InsertAlias aliases a username to a user.
+  InsertAlias(alias, user string) error
+
+  // DeleteAlias removes an alias.
+  DeleteAlias(alias string) error
+
+  // GetAlias returns the user that an alias belongs to.
+  GetAlias(alias string) (string, error)
+
+  // GetAliases returns all aliases.
+  GetAliases() ([]*database.Alias, error)
+
+  // InsertBlacklist blacklists a user.
+  InsertBlacklist(user string) error
+
+  // DeleteBlacklist removes a user from the blacklist.
+  DeleteBlacklist(user string) error
+
+  // IsBlacklisted checks whether a user is blacklisted.
+  IsBlacklisted(user string) (bool, error)
+
+  // GetBlacklist returns all blacklisted users.
+  GetBlacklist() ([]string, error)
+
+  // GetDigest returns a summary of the karma operations in a time window.
+  GetDigest(since, until time.Time, limit int) (*database.Digest, error)
+
+  // GetGivers returns the users who gave the most karma in a time window.
+  GetGivers(since, until time.Time, limit int) (database.Leaderboard, error)
+
+  // InsertAchievement records that a user earned a badge and
+  // returns whether they had not earned it before.
+  InsertAchievement(user, badge string) (bool, error)
+
+  // GetAchievements returns the badges that a user earned.
+  GetAchievements(user string) ([]*database.Achievement, error)
+
+  // GetUserStats returns the numbers that achievements are based on.
+  GetUserStats(user string, now time.Time) (*database.UserStats, error)
+
+  // QueueEvent queues the delivery of an event to the subscribed webhooks.
+  QueueEvent(event string, payload []byte) error
+
+  // GetRewards returns the rewards that users can redeem.
+  GetRewards() ([]*database.Reward, error)
+
+  // Redeem debits the cost of a reward from a user and records a pending redemption.
+  Redeem(user, reward string) (*database.Redemption, error)
+
+  // DecideRedemption approves or denies a pending redemption, refunding denied ones.
+  DecideRedemption(id int64, admin string, approve bool, note string) (*database.Redemption, error)
+
+  // Transfer moves points from one user's balance to another's, up to a limit per day.
+  Transfer(points *database.Points, limit int) (*database.TransferSummary, error)
 }
 
 // ChatService is an abstraction around Slack, mostly designed for use in tests.
@@ -67,11 +139,22 @@ type ChatService interface {
 
   // GetUserInfo retrieves the complete user information for the specified username.
   GetUserInfo(user string) (*slack.User, error)
+
+  // GetUsers returns all users of the workspace.
+  GetUsers() ([]slack.User, error)
+
+  // SendBlocks sends a message made of Block Kit blocks, with text as the
+  // fallback for notifications and clients that cannot show blocks.
+  SendBlocks(channel, thread, text string, blocks ...slack.Block) error
+
+  // SendAs sends a message through the web API with the name and icon
+  // of an identity instead of the bot user's, and blocks if any are passed.
+  SendAs(channel, thread, text string, identity *Identity, blocks ...slack.Block) error
 }
 
 // SlackChatService is an implementation of ChatService using github.com/nlopes/slack.
 type SlackChatService struct {
-  slack.RTM
+  *slack.RTM
 }
 
 // IncomingEventsChan returns a channel of real-time messaging events.
@@ -79,6 +162,45 @@ func (s SlackChatService) IncomingEventsChan() chan slack.RTMEvent {
   return s.IncomingEvents
 }
 
+// SendBlocks sends a message made of blocks through the web API,
+// since the real-time messaging API does not support blocks.
+func (s SlackChatService) SendBlocks(channel, thread, text string, blocks ...slack.Block) error {
+  _, _, err := s.PostMessage(
+    channel,
+    slack.MsgOptionText(text, false),
+    slack.MsgOptionBlocks(blocks...),
+    slack.MsgOptionTS(thread),
+    slack.MsgOptionAsUser(true),
+  )
+
+  return err
+}
+
+// SendAs sends a message through the web API, overriding the bot
+// user's name and icon. This requires the chat:write.customize scope.
+func (s SlackChatService) SendAs(channel, thread, text string, identity *Identity, blocks ...slack.Block) error {
+  options := []slack.MsgOption{
+    slack.MsgOptionText(text, false),
+    slack.MsgOptionTS(thread),
+    slack.MsgOptionAsUser(false),
+  }
+  if len(blocks) > 0 {
+    options = append(options, slack.MsgOptionBlocks(blocks...))
+  }
+  if identity.Name != "" {
+    options = append(options, slack.MsgOptionUsername(identity.Name))
+  }
+  switch {
+  case strings.HasPrefix(identity.Icon, ":"):
+    options = append(options, slack.MsgOptionIconEmoji(identity.Icon))
+  case identity.Icon != "":
+    options = append(options, slack.MsgOptionIconURL(identity.Icon))
+  }
+
+  _, _, err := s.PostMessage(channel, options...)
+  return err
+}
+
 // UserAliases is a map of alias -> main username
 type UserAliases map[string]string
 
@@ -88,150 +210,312 @@ type ReactjiConfig struct {
   Upvote, Downvote StringList
 }
 
+// TransferConfig limits the points that users can give
+// each other from their balance. Transfers are turned off
+// if Max is 0, and Daily is not enforced if it is 0.
+type TransferConfig struct {
+  // Max is the amount of points that can be given at once.
+  Max int
+
+  // Daily is the amount of points that a user can
+  // give in 24 hours.
+  Daily int
+}
+
 // Config contains all the necessary configs for janet.
 type Config struct {
   Slack                       ChatService
   BadJanetSlack               ChatService
+  BadJanetIdentity            Identity
   Debug, Motivate, SelfPoints bool
+  Blocks                      bool
   MaxPoints, LeaderboardLimit int
   Log                         *log.Log
   UI                          ui.Provider
   DB                          Database
-  UserBlacklist               StringList
+  UserBlacklist, Admins       StringList
   Aliases                     UserAliases
   Reactji                     *ReactjiConfig
-  WaitGroup                   *sync.WaitGroup
+  Digests                     []*Digest
+  Catalog                     *persona.Catalog
+  Locale                      *i18n.Locale
+
+  // Achievements are the milestones that users can unlock.
+  // There are no achievements if it is empty.
+  Achievements []*achievement.Milestone
+
+  // Transfers limits the points that users can give each other.
+  Transfers TransferConfig
 }
 
 // A Bot is an instance of janet.
 type Bot struct {
-  Config    *Config
-  WaitGroup *sync.WaitGroup
+  Config *Config
+
+  // botState is shared by the bot and the snapshots that
+  // its event handlers run with
+  *botState
+}
+
+// botState is the part of a Bot that doesn't change on Reload.
+type botState struct {
+  // handlers tracks the event handlers that are still running
+  handlers sync.WaitGroup
+
+  // configMutex guards the settings that can be changed by Reload
+  configMutex sync.RWMutex
+
+  goodJanetConnection, badJanetConnection connectionState
+
+  // userIDs maps the names of the users janet has seen to their Slack IDs
+  userIDs sync.Map
+
+  // userLocales maps Slack IDs to the users' locales
+  userLocales sync.Map
+
+  // generousMonth is the last month whose most generous
+  // giver was looked up
+  generousMonth      time.Time
+  generousMonthMutex sync.Mutex
 }
 
 // New returns a pointer to an new instance of janet.
 func New(config *Config) *Bot {
+  if config.Catalog == nil {
+    config.Catalog = persona.Default()
+  }
+  if config.Locale == nil {
+    config.Locale = i18n.Default()
+  }
+
   return &Bot{
-    Config: config,
+    Config:   config,
+    botState: &botState{},
   }
 }
 
-func (b *Bot) Listen() {
+// snapshot returns a copy of the bot whose config is not changed by
+// Reload, so that the caller can use it without holding configMutex.
+func (b *Bot) snapshot() *Bot {
+  b.configMutex.RLock()
+  config := *b.Config
+  b.configMutex.RUnlock()
+
+  return &Bot{
+    Config:   &config,
+    botState: b.botState,
+  }
+}
+
+// Reload replaces the settings that can be changed while janet is
+// running: MaxPoints, LeaderboardLimit, Motivate, SelfPoints, Blocks,
+// UserBlacklist, Admins, Aliases, Reactji, Achievements, Transfers and
+// Catalog. All other fields of the passed config are ignored.
+func (b *Bot) Reload(config *Config) {
+  b.configMutex.Lock()
+  defer b.configMutex.Unlock()
+
+  b.Config.MaxPoints = config.MaxPoints
+  b.Config.LeaderboardLimit = config.LeaderboardLimit
+  b.Config.Motivate = config.Motivate
+  b.Config.SelfPoints = config.SelfPoints
+  b.Config.Blocks = config.Blocks
+  b.Config.UserBlacklist = config.UserBlacklist
+  b.Config.Admins = config.Admins
+  b.Config.Aliases = config.Aliases
+  b.Config.Reactji = config.Reactji
+  b.Config.Achievements = config.Achievements
+  b.Config.Transfers = config.Transfers
+
+  if config.Catalog != nil {
+    b.Config.Catalog = config.Catalog
+  }
+}
+
+// Listen starts the listeners of the configured janets and the scheduled
+// digests and blocks until the context is cancelled, the listeners' event
+// channels are closed or one of them fails. It waits for in-flight
+// handlers to finish before returning.
+func (b *Bot) Listen(ctx context.Context) error {
 
   b.Config.Log.Info("listener called")
 
-  //add listenrs to waitgroup because they need to run at the same timee (concurrently)
-  var wg sync.WaitGroup
-  wg.Add(2)
+  ctx, cancel := context.WithCancel(ctx)
+  defer cancel()
 
-  b.GoodJanetListen(wg)
-  b.BadJanetListen(wg)
+  listeners := []func(context.Context) error{b.GoodJanetListen}
+  if b.Config.BadJanetSlack != nil {
+    listeners = append(listeners, b.BadJanetListen)
+  }
 
-  wg.Wait()
+  //the listeners run at the same time (concurrently), the first one to fail stops the other
+  errs := make(chan error, len(listeners))
+  for _, listen := range listeners {
+    listen := listen
+    go func() { errs <- listen(ctx) }()
+  }
+
+  digestsDone := make(chan struct{})
+  go func() {
+    defer close(digestsDone)
+    b.runDigests(ctx)
+  }()
+
+  var err error
+  for range listeners {
+    if listenErr := <-errs; listenErr != nil && err == nil {
+      err = listenErr
+      cancel()
+    }
+  }
+
+  cancel()
+  <-digestsDone
+
+  b.Config.Log.Info("waiting for handlers to finish")
+  b.handlers.Wait()
+
+  return err
 }
 
-// Listen starts listening for Slack messages and calls the
-// appropriate handlers.
-func (b *Bot) GoodJanetListen(wg sync.WaitGroup) {
+// GoodJanetListen listens for Good Janet's Slack events and calls the
+// appropriate handlers until the context is cancelled or the event
+// channel is closed.
+func (b *Bot) GoodJanetListen(ctx context.Context) error {
 
   b.Config.Log.Info("good-janet listener called")
 
-  go func() {
-    for msg := range b.Config.Slack.IncomingEventsChan() {
-      switch ev := msg.Data.(type) {
-      case *slack.ReactionAddedEvent:
-        go b.handleReactionAddedEvent(msg.Data.(*slack.ReactionAddedEvent))
-      case *slack.ReactionRemovedEvent:
-        go b.handleReactionRemovedEvent(msg.Data.(*slack.ReactionRemovedEvent))
-      case *slack.MessageEvent:
-        go b.handleMessageEvent(msg.Data.(*slack.MessageEvent))
-      case *slack.ConnectedEvent:
-        b.Config.Log.Info("janet connected to slack")
-        if b.Config.Debug {
-          b.Config.Log.KV("info", ev.Info).Info("got slack info")
-          b.Config.Log.KV("connections", ev.ConnectionCount).Info("got connection count")
-        }
-      case *slack.RTMError:
-        b.Config.Log.Err(ev).Error("slack rtm error")
-      case *slack.InvalidAuthEvent:
-        wg.Done()
-        b.Config.Log.Fatal("invalid slack token")
-      default:
-        b.Config.Log.KV("data", msg.Data).KV("event", reflect.TypeOf(msg.Data)).Info("unexpected slack api event")
+  events := b.Config.Slack.IncomingEventsChan()
+  for {
+    var msg slack.RTMEvent
+    select {
+    case <-ctx.Done():
+      return nil
+    case event, ok := <-events:
+      if !ok {
+        return nil
       }
+      msg = event
     }
-  }()
+
+    metrics.SlackEvents.WithLabelValues("good", msg.Type).Inc()
+
+    switch ev := msg.Data.(type) {
+    case *slack.ReactionAddedEvent:
+      b.handle(func(b *Bot) { b.handleReactionAddedEvent(ev) })
+    case *slack.ReactionRemovedEvent:
+      b.handle(func(b *Bot) { b.handleReactionRemovedEvent(ev) })
+    case *slack.MessageEvent:
+      b.handle(func(b *Bot) { b.handleMessageEvent(ev) })
+    case *slack.ConnectedEvent:
+      b.goodJanetConnection.setConnected(connectedUser(ev))
+      b.Config.Log.Info("janet connected to slack")
+      if ev.ConnectionCount > 0 {
+        metrics.RTMReconnects.WithLabelValues("good").Inc()
+      }
+      if b.Config.Debug {
+        b.Config.Log.KV("info", ev.Info).Info("got slack info")
+        b.Config.Log.KV("connections", ev.ConnectionCount).Info("got connection count")
+      }
+    case *slack.ConnectingEvent:
+      b.goodJanetConnection.setDisconnected(nil)
+    case *slack.ConnectionErrorEvent:
+      b.goodJanetConnection.setDisconnected(ev.ErrorObj)
+      b.Config.Log.Err(ev.ErrorObj).Error("slack connection error")
+    case *slack.DisconnectedEvent:
+      b.goodJanetConnection.setDisconnected(errors.New("disconnected"))
+      b.Config.Log.KV("intentional", ev.Intentional).Info("janet disconnected from slack")
+    case *slack.RTMError:
+      b.goodJanetConnection.setError(ev)
+      b.Config.Log.Err(ev).Error("slack rtm error")
+    case *slack.InvalidAuthEvent:
+      b.goodJanetConnection.setDisconnected(errors.New("invalid slack token"))
+      return errors.New("invalid slack token")
+    default:
+      b.Config.Log.KV("data", msg.Data).KV("event", reflect.TypeOf(msg.Data)).Info("unexpected slack api event")
+    }
+  }
 }
 
-func (b *Bot) BadJanetListen(wg sync.WaitGroup) {
+// BadJanetListen listens for Bad Janet's Slack events until the context
+// is cancelled or the event channel is closed.
+func (b *Bot) BadJanetListen(ctx context.Context) error {
 
   b.Config.Log.Info("bad-janet listener called")
 
-  go func() {
-    for msg := range b.Config.BadJanetSlack.IncomingEventsChan() {
-      switch ev := msg.Data.(type) {
-      case *slack.MessageEvent:
-        b.Config.Log.Info("bad-janet got a message")
-        //go b.handleMessageEvent(msg.Data.(*slack.MessageEvent))
-      case *slack.ConnectedEvent:
-        b.Config.Log.Info("bad-janet connected to slack")
-        if b.Config.Debug {
-          b.Config.Log.KV("info", ev.Info).Info("got bad-janet slack info")
-          b.Config.Log.KV("connections", ev.ConnectionCount).Info("got bad-janet connection count")
-        }
-      case *slack.RTMError:
-        b.Config.Log.Err(ev).Error("badjanet slack rtm error")
-      case *slack.InvalidAuthEvent:
-        wg.Done()
-        b.Config.Log.Fatal("badjanet invalid slack token")
-      default:
-        b.Config.Log.KV("data", msg.Data).KV("event", reflect.TypeOf(msg.Data)).Info("unexpected slack api event")
+  events := b.Config.BadJanetSlack.IncomingEventsChan()
+  for {
+    var msg slack.RTMEvent
+    select {
+    case <-ctx.Done():
+      return nil
+    case event, ok := <-events:
+      if !ok {
+        return nil
       }
+      msg = event
     }
-  }()
-}
 
-// SendMessage sends a message to a Slack channel.
-func (b *Bot) SendMessage(message, channel, thread string, whichJanet string) {
+    metrics.SlackEvents.WithLabelValues("bad", msg.Type).Inc()
 
-  //b.Config.Log.Info("sending message as")
+    switch ev := msg.Data.(type) {
+    case *slack.MessageEvent:
+      b.handle(func(b *Bot) { b.handleBadJanetMessageEvent(ev) })
+    case *slack.ConnectedEvent:
+      b.badJanetConnection.setConnected(connectedUser(ev))
+      b.Config.Log.Info("bad-janet connected to slack")
+      if ev.ConnectionCount > 0 {
+        metrics.RTMReconnects.WithLabelValues("bad").Inc()
+      }
+      if b.Config.Debug {
+        b.Config.Log.KV("info", ev.Info).Info("got bad-janet slack info")
+        b.Config.Log.KV("connections", ev.ConnectionCount).Info("got bad-janet connection count")
+      }
+    case *slack.ConnectingEvent:
+      b.badJanetConnection.setDisconnected(nil)
+    case *slack.ConnectionErrorEvent:
+      b.badJanetConnection.setDisconnected(ev.ErrorObj)
+      b.Config.Log.Err(ev.ErrorObj).Error("badjanet slack connection error")
+    case *slack.DisconnectedEvent:
+      b.badJanetConnection.setDisconnected(errors.New("disconnected"))
+      b.Config.Log.KV("intentional", ev.Intentional).Info("bad-janet disconnected from slack")
+    case *slack.RTMError:
+      b.badJanetConnection.setError(ev)
+      b.Config.Log.Err(ev).Error("badjanet slack rtm error")
+    case *slack.InvalidAuthEvent:
+      b.badJanetConnection.setDisconnected(errors.New("invalid slack token"))
+      return errors.New("badjanet invalid slack token")
+    default:
+      b.Config.Log.KV("data", msg.Data).KV("event", reflect.TypeOf(msg.Data)).Info("unexpected slack api event")
+    }
+  }
+}
 
-  if whichJanet == "badJanet" {
-    //b.Config.Log.Info(whichJanet)
+// handle runs an event handler in its own goroutine with a snapshot of
+// the bot and keeps track of it so that Listen can wait for it to finish.
+func (b *Bot) handle(handler func(*Bot)) {
+  b.handlers.Add(1)
+  go func() {
+    defer b.handlers.Done()
 
-    msg := b.Config.BadJanetSlack.NewOutgoingMessage(message, channel)
-    msg.ThreadTimestamp = thread
-    b.Config.BadJanetSlack.SendMessage(msg)
+    handler(b.snapshot())
+  }()
+}
 
-    appendMessage := appendQuoteToMessage()
-    if appendMessage {
-      msg := b.Config.BadJanetSlack.NewOutgoingMessage(badJanetQuote(), channel)
-      msg.ThreadTimestamp = thread
-      b.Config.BadJanetSlack.SendMessage(msg)
-    }
-  } else {
-    //b.Config.Log.Info("good janet")
-
-    msg := b.Config.Slack.NewOutgoingMessage(message, channel)
-    msg.ThreadTimestamp = thread
-    b.Config.Slack.SendMessage(msg)
-    appendMessage := appendQuoteToMessage()
-    if appendMessage {
-      msg := b.Config.Slack.NewOutgoingMessage(goodJanetQuote(), channel)
-      msg.ThreadTimestamp = thread
-      b.Config.Slack.SendMessage(msg)
-    }
+// SendMessage sends a message to a Slack channel.
+func (b *Bot) SendMessage(message, channel, thread string, whichJanet string) {
+  err := b.post(message, nil, channel, thread, whichJanet)
+  if err != nil {
+    b.Config.Log.Err(err).KV("channel", channel).Error("could not send message")
+    return
   }
+
+  b.sendQuote(channel, thread, whichJanet)
 }
 
 // DMUser sends a message directly to a Slack user.
 func (b *Bot) DMUser(message, user string, thread string, whichJanet string) {
-  _, _, channel, err := b.Config.Slack.OpenIMChannel(user)
-
-  if whichJanet == "badJanet" {
-    _, _, channel, err = b.Config.BadJanetSlack.OpenIMChannel(user)
-  }
-
+  _, _, channel, err := b.chat(whichJanet).OpenIMChannel(user)
   if err != nil {
     b.Config.Log.Err(err).KV("user", user).Error("could not open IM channel with user")
     return
@@ -246,12 +530,13 @@ func (b *Bot) handleError(err error, channel, thread string) bool {
   }
 
   b.Config.Log.Err(err).Error("error")
+  metrics.HandlerErrors.Inc()
   if channel != "" {
     var message string
     if b.Config.Debug {
       message = err.Error()
     } else {
-      message = "hi, guys, i'm broken."
+      message = b.message(b.Config.Locale, "", persona.MessageBroken, persona.Data{"Error": err.Error()})
     }
 
     b.SendMessage(message, channel, thread, "")
@@ -279,7 +564,7 @@ func (b *Bot) handleReactionAddedEvent(ev *slack.ReactionAddedEvent) {
   }
 
   reason = fmt.Sprintf("adding a :%s: reactji", ev.Reaction)
-  b.handleReactionEvent(ev.User, ev.ItemUser, reason, points)
+  b.handleReactionEvent(ev.User, ev.ItemUser, ev.Item.Channel, reason, points)
 }
 
 func (b *Bot) handleReactionRemovedEvent(ev *slack.ReactionRemovedEvent) {
@@ -301,10 +586,10 @@ func (b *Bot) handleReactionRemovedEvent(ev *slack.ReactionRemovedEvent) {
   }
 
   reason = fmt.Sprintf("removing a :%s: reactji", ev.Reaction)
-  b.handleReactionEvent(ev.User, ev.ItemUser, reason, points)
+  b.handleReactionEvent(ev.User, ev.ItemUser, ev.Item.Channel, reason, points)
 }
 
-func (b *Bot) handleReactionEvent(fromID, toID, reason string, points int) {
+func (b *Bot) handleReactionEvent(fromID, toID, channel, reason string, points int) {
   from, err := b.getUserNameByID(fromID)
   if b.handleError(err, "", "") {
     return
@@ -326,10 +611,11 @@ func (b *Bot) handleReactionEvent(fromID, toID, reason string, points int) {
   if b.handleError(err, "", "") {
     return
   }
+  metrics.KarmaOperations.WithLabelValues(metrics.Sign(points), "reactji").Inc()
 
-  pointsMsg, err := b.getUserPointsMessage(to, reason, points)
-  if b.handleError(err, "", "") {
-    return
+  err = webhook.SendKarma(b.Config.DB, webhook.EventInsert, record)
+  if err != nil {
+    b.Config.Log.Err(err).Error("could not queue webhook events")
   }
 
   whichJanet := ""
@@ -340,25 +626,47 @@ func (b *Bot) handleReactionEvent(fromID, toID, reason string, points int) {
     whichJanet = "goodJanet"
   }
 
-  b.DMUser(pointsMsg, fromID, "", whichJanet)
+  pointsMsg, blocks, err := b.getUserPointsMessage(to, reason, points, whichJanet, b.locale(fromID))
+  if b.handleError(err, "", "") {
+    return
+  }
+
+  b.DMUserBlocks(pointsMsg, blocks, fromID, whichJanet)
+  b.unlockAchievements(record, channel, "", b.locale(fromID))
 }
 
+// handleMessageEvent handles the messages that Good Janet receives.
 func (b *Bot) handleMessageEvent(ev *slack.MessageEvent) {
+  b.handleMessage(ev, "goodJanet")
+}
+
+// handleBadJanetMessageEvent handles the messages that Bad Janet receives.
+func (b *Bot) handleBadJanetMessageEvent(ev *slack.MessageEvent) {
+  b.handleMessage(ev, "badJanet")
+}
+
+func (b *Bot) handleMessage(ev *slack.MessageEvent, receiver string) {
   if ev.Type != "message" {
     return
   }
 
-  // convert motivates into janet syntax
-  if b.Config.Motivate {
-    if match := regexps.Motivate.FindStringSubmatch(ev.Text); len(match) > 0 {
-      ev.Text = match[1] + "++ for doing good work"
-    }
-  }
+  b.convertMotivate(ev)
 
   //b.Config.Log.Info(ev.Text)
 
-  re := regexp.MustCompile("(<@[A-Za-z0-9]+>(\\s)?([\\+]{2,})?([\\-]{2,})?)")
-  splits := re.FindAllString(ev.Text, -1)
+  // commands may contain slack users, so they need
+  // to be handled before looking for karma operations
+  if b.dispatch(ev, receiver, commands) {
+    return
+  }
+
+  // both janets see every message, so karma is only
+  // counted once, by Good Janet
+  if receiver != "goodJanet" {
+    return
+  }
+
+  splits := splitKarma(ev.Text)
 
   if splits != nil {
     for _, split := range splits {
@@ -367,31 +675,55 @@ func (b *Bot) handleMessageEvent(ev *slack.MessageEvent) {
 
       switch {
       case regexps.GivePoints.MatchString(splitText):
+        metrics.Commands.WithLabelValues("give").Inc()
         b.applyPoints(ev, "", splitText)
 
       case regexps.TakePoints.MatchString(splitText):
+        metrics.Commands.WithLabelValues("take").Inc()
         whichJanet := "badJanet"
         b.applyPoints(ev, whichJanet, splitText)
 
       case regexps.Throwback.MatchString(ev.Text):
+        metrics.Commands.WithLabelValues("throwback").Inc()
         b.getThrowback(ev)
 
       case regexps.QueryPoints.MatchString(ev.Text):
+        metrics.Commands.WithLabelValues("query").Inc()
         b.queryPoints(ev)
       }
     }
   } else {
+    b.dispatch(ev, receiver, standaloneCommands)
+  }
+}
 
-    switch {
-    case regexps.URL.MatchString(ev.Text):
-      b.printURL(ev)
-    case regexps.Leaderboard.MatchString(ev.Text):
-      b.printLeaderboard(ev)
-    }
+// connectedUser returns the ID of the bot user that connected.
+func connectedUser(ev *slack.ConnectedEvent) string {
+  if ev.Info == nil || ev.Info.User == nil {
+    return ""
+  }
+
+  return ev.Info.User.ID
+}
+
+// convertMotivate converts motivates into janet syntax.
+func (b *Bot) convertMotivate(ev *slack.MessageEvent) {
+  if !b.Config.Motivate {
+    return
+  }
 
+  if match := regexps.Motivate.FindStringSubmatch(ev.Text); len(match) > 0 {
+    ev.Text = match[1] + "++ for doing good work"
   }
 }
 
+// splitKarma returns the parts of a message that may
+// contain karma operations on slack users.
+func splitKarma(text string) []string {
+  re := regexp.MustCompile("(<@[A-Za-z0-9]+>(\\s)?([\\+]{2,})?([\\-]{2,})?)")
+  return re.FindAllString(text, -1)
+}
+
 func (b *Bot) printURL(ev *slack.MessageEvent) {
   url, err := b.Config.UI.GetURL("/")
   if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
@@ -437,7 +769,11 @@ func (b *Bot) applyPoints(ev *slack.MessageEvent, whichJanet string, splitText s
   }
   to = strings.ToLower(to)
 
-  if _, blacklisted := b.Config.UserBlacklist[to]; blacklisted {
+  blacklisted, err := b.isBlacklisted(to)
+  if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+    return
+  }
+  if blacklisted {
     b.Config.Log.KV("user", to).Info("user is blacklisted, ignoring karma command")
     return
   }
@@ -449,30 +785,38 @@ func (b *Bot) applyPoints(ev *slack.MessageEvent, whichJanet string, splitText s
   reason := match[3]
 
   if !b.Config.SelfPoints && from == to {
-    b.SendMessage("You cannot give yourself points.", ev.Channel, ev.ThreadTimestamp, whichJanet)
+    b.SendMessage(b.message(b.locale(ev.User), whichJanet, persona.MessageSelfPoints, persona.Data{"User": from}), ev.Channel, ev.ThreadTimestamp, whichJanet)
     return
   }
 
   record := &database.Points{
-    From:   from,
-    To:     to,
-    Points: points,
-    Reason: reason,
+    From:    from,
+    To:      to,
+    Points:  points,
+    Reason:  reason,
+    Message: database.MessageID(ev.Channel, ev.Timestamp),
   }
 
   err = b.Config.DB.InsertPoints(record)
   if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
     return
   }
+  metrics.KarmaOperations.WithLabelValues(metrics.Sign(points), "message").Inc()
 
-  pointsMsg, err := b.getUserPointsMessage(to, reason, points)
+  err = webhook.SendKarma(b.Config.DB, webhook.EventInsert, record)
+  if err != nil {
+    b.Config.Log.Err(err).Error("could not queue webhook events")
+  }
+
+  pointsMsg, blocks, err := b.getUserPointsMessage(to, reason, points, whichJanet, b.locale(ev.User))
   if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
     return
   }
 
   b.Config.Log.Info("points applied")
 
-  b.SendMessage(pointsMsg, ev.Channel, ev.ThreadTimestamp, whichJanet)
+  b.SendBlocks(pointsMsg, blocks, ev.Channel, ev.ThreadTimestamp, whichJanet)
+  b.unlockAchievements(record, ev.Channel, ev.ThreadTimestamp, b.locale(ev.User))
 }
 
 func (b *Bot) getThrowback(ev *slack.MessageEvent) {
@@ -498,9 +842,11 @@ func (b *Bot) getThrowback(ev *slack.MessageEvent) {
     }
   }
 
+  locale := b.locale(ev.User)
+
   throwback, err := b.Config.DB.GetThrowback(user)
   if err == database.ErrNoSuchUser {
-    b.SendMessage(fmt.Sprintf("could not find any karma operations for %s", user), ev.Channel, ev.ThreadTimestamp, "")
+    b.SendMessage(b.message(locale, "", persona.MessageNoThrowback, persona.Data{"User": user}), ev.Channel, ev.ThreadTimestamp, "")
     return
   }
 
@@ -508,34 +854,43 @@ func (b *Bot) getThrowback(ev *slack.MessageEvent) {
     return
   }
 
-  date := humanize.Time(throwback.Timestamp)
-  if throwback.Reason != "" {
-    throwback.Reason = fmt.Sprintf(" for %s", throwback.Reason)
+  date := locale.Ago(throwback.Timestamp, time.Now())
+  data := persona.Data{
+    "To":     munge.Munge(throwback.To),
+    "From":   munge.Munge(throwback.From),
+    "Points": throwback.Points.Points,
+    "Date":   date,
+    "Reason": throwback.Reason,
   }
-  text := fmt.Sprintf("%s received %d points from %s %s%s", munge.Munge(throwback.To), throwback.Points.Points, munge.Munge(throwback.From), date, throwback.Reason)
 
-  b.SendMessage(text, ev.Channel, ev.ThreadTimestamp, "")
+  details := locale.Render("throwbackdate", false, data)
+  text := fmt.Sprintf("%s %s", b.message(locale, "", persona.MessageThrowback, data), details)
+
+  headline := b.markdownMessage(locale, "", persona.MessageThrowback, data)
+  b.SendBlocks(text, b.throwbackBlocks(throwback, headline, details), ev.Channel, ev.ThreadTimestamp, "")
 }
 
-func (b *Bot) getUserPointsMessage(name, reason string, points int) (string, error) {
+func (b *Bot) getUserPointsMessage(name, reason string, points int, whichJanet string, locale *i18n.Locale) (string, []slack.Block, error) {
   user, err := b.Config.DB.GetUser(name)
   if err != nil {
-    return "", err
+    return "", nil, err
   }
 
-  text := fmt.Sprintf("%s now has %d points(", name, user.Points)
+  change := locale.Render("change", false, persona.Data{"Points": points, "Reason": reason})
 
-  if points > 0 {
-    text += "+"
+  data := persona.Data{
+    "User":   name,
+    "Points": user.Points,
+    "Change": change,
+    "Reason": reason,
   }
-  text = fmt.Sprintf("%s%d", text, points)
 
-  if reason != "" {
-    text += fmt.Sprintf(" for %s", reason)
-  }
-  text += ")"
+  text := fmt.Sprintf("%s(%s)", b.message(locale, whichJanet, persona.MessagePoints, data), change)
 
-  return text, nil
+  data["User"] = user.Name
+  headline := b.markdownMessage(locale, whichJanet, persona.MessagePoints, data)
+
+  return text, b.pointsBlocks(user, headline, change), nil
 }
 
 func (b *Bot) printLeaderboard(ev *slack.MessageEvent) {
@@ -553,7 +908,9 @@ func (b *Bot) printLeaderboard(ev *slack.MessageEvent) {
     }
   }
 
-  text := fmt.Sprintf("*top %d leaderboard*\n", limit)
+  locale := b.locale(ev.User)
+  title := b.message(locale, "", persona.MessageLeaderboard, persona.Data{"Limit": limit})
+  text := fmt.Sprintf("*%s*\n", title)
 
   url, err := b.Config.UI.GetURL(fmt.Sprintf("/leaderboard/%d", limit))
   if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
@@ -569,10 +926,11 @@ func (b *Bot) printLeaderboard(ev *slack.MessageEvent) {
   }
 
   for i, user := range leaderboard {
-    text += fmt.Sprintf("%d. %s == %d\n", i+1, munge.Munge(user.Name), user.Points)
+    text += locale.Render("leaderboardline", false, leaderboardLine(i, user)) + "\n"
   }
 
-  b.SendMessage(text, ev.Channel, ev.ThreadTimestamp, "")
+  blocks := b.leaderboardBlocks(locale, title, url, "leaderboardline", leaderboard)
+  b.SendBlocks(text, blocks, ev.Channel, ev.ThreadTimestamp, "")
 }
 
 func (b *Bot) parseUser(user string) (string, error) {
@@ -586,17 +944,38 @@ func (b *Bot) parseUser(user string) (string, error) {
 
   // check if it is aliased
   if alias, ok := b.Config.Aliases[user]; ok {
+    return alias, nil
+  }
+
+  alias, err := b.Config.DB.GetAlias(strings.ToLower(user))
+  switch err {
+  case nil:
     user = alias
+  case database.ErrNoSuchAlias:
+  default:
+    return "", err
   }
 
   return user, nil
 }
 
+// isBlacklisted checks both the configured and the
+// persisted blacklist.
+func (b *Bot) isBlacklisted(user string) (bool, error) {
+  if b.Config.UserBlacklist.Contains(user) {
+    return true, nil
+  }
+
+  return b.Config.DB.IsBlacklisted(user)
+}
+
 func (b *Bot) getUserNameByID(id string) (string, error) {
   userInfo, err := b.Config.Slack.GetUserInfo(id)
   if err != nil {
     return "", err
   }
+  b.rememberUser(userInfo.Name, id)
+  b.userLocales.Store(id, userInfo.Locale)
 
   return userInfo.Name, nil
 }
@@ -613,13 +992,37 @@ func (b *Bot) queryPoints(ev *slack.MessageEvent) {
   }
   name = strings.ToLower(name)
 
+  locale := b.locale(ev.User)
+
   user, err := b.Config.DB.GetUser(name)
   switch {
   case err == database.ErrNoSuchUser:
     // override debug mode
-    b.SendMessage(err.Error(), ev.Channel, ev.ThreadTimestamp, "")
+    b.SendMessage(locale.T("nosuchuser", "User", name), ev.Channel, ev.ThreadTimestamp, "")
   case b.handleError(err, ev.Channel, ev.ThreadTimestamp):
   default:
-    b.SendMessage(fmt.Sprintf("%s == %d", user.Name, user.Points), ev.Channel, ev.ThreadTimestamp, "")
+    data := persona.Data{"User": user.Name, "Points": user.Points}
+    text := b.message(locale, "", persona.MessageQuery, data)
+    headline := b.markdownMessage(locale, "", persona.MessageQuery, data)
+    blocks := b.userBlocks(user, headline)
+
+    // only worth mentioning once they spent some of their karma
+    if user.Balance != user.Points {
+      balance := locale.T("balance", "Points", user.Balance)
+      text += "\n" + balance
+      blocks = append(blocks, badgesBlock(balance))
+    }
+
+    badges, err := b.badges(user.Name, locale)
+    if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+      return
+    }
+    if badges != "" {
+      badges = locale.T("badges", "Badges", badges)
+      text += "\n" + badges
+      blocks = append(blocks, badgesBlock(badges))
+    }
+
+    b.SendBlocks(text, blocks, ev.Channel, ev.ThreadTimestamp, "")
   }
 }
@@ -1,11 +1,23 @@
 package janet
 
 import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/nlopes/slack"
+	"github.com/troyxmccall/janet/achievement"
 	"github.com/troyxmccall/janet/database"
+	"github.com/troyxmccall/janet/i18n"
+	"github.com/troyxmccall/janet/persona"
+	"github.com/troyxmccall/janet/ui/blankui"
+	"github.com/troyxmccall/janet/webhook"
 )
 
 func TestNew(t *testing.T) {
@@ -45,7 +57,7 @@ func TestListen(t *testing.T) {
 	hasStarted := make(chan int)
 	go func() {
 		close(hasStarted)
-		b.Listen()
+		b.Listen(context.Background())
 		hasExited = true
 	}()
 	<-hasStarted
@@ -62,6 +74,902 @@ func TestListen(t *testing.T) {
 	// TODO: To properly test Listen, it needs to be decoupled further from what it actually does.
 }
 
+func TestListenStopsOnCancel(t *testing.T) {
+	b, _, _ := newBot(&Config{})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- b.Listen(ctx)
+	}()
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Listen: returned %v after cancel; want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Listen: did not exit after cancelling the context")
+	}
+}
+
+func TestListenInvalidAuth(t *testing.T) {
+	b, cs, _ := newBot(&Config{})
+	done := make(chan error)
+	go func() {
+		done <- b.Listen(context.Background())
+	}()
+
+	cs.IncomingEvents <- slack.RTMEvent{Data: &slack.InvalidAuthEvent{}}
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("Listen: returned nil after an invalid auth event; want error")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Listen: did not exit after an invalid auth event")
+	}
+}
+
+func TestConnectionHealth(t *testing.T) {
+	b, good, _ := newBot(&Config{})
+	bad := &TestChatService{
+		IncomingEvents: make(chan slack.RTMEvent),
+	}
+	b.Config.BadJanetSlack = bad
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Listen(ctx)
+
+	if err := b.GoodJanetHealth(ctx); err == nil {
+		t.Errorf("GoodJanetHealth: returned nil before connecting; want error")
+	}
+
+	good.IncomingEvents <- slack.RTMEvent{Type: "connected", Data: &slack.ConnectedEvent{ConnectionCount: 1}}
+	bad.IncomingEvents <- slack.RTMEvent{Type: "connected", Data: &slack.ConnectedEvent{ConnectionCount: 1}}
+	time.Sleep(1 * time.Millisecond)
+	if err := b.GoodJanetHealth(ctx); err != nil {
+		t.Errorf("GoodJanetHealth: returned %v after connecting; want nil", err)
+	}
+	if err := b.BadJanetHealth(ctx); err != nil {
+		t.Errorf("BadJanetHealth: returned %v after connecting; want nil", err)
+	}
+
+	good.IncomingEvents <- slack.RTMEvent{Type: "disconnected", Data: &slack.DisconnectedEvent{}}
+	bad.IncomingEvents <- slack.RTMEvent{Type: "disconnected", Data: &slack.DisconnectedEvent{}}
+	time.Sleep(1 * time.Millisecond)
+	if err := b.GoodJanetHealth(ctx); err == nil {
+		t.Errorf("GoodJanetHealth: returned nil after disconnecting; want error")
+	}
+	if err := b.BadJanetHealth(ctx); err == nil {
+		t.Errorf("BadJanetHealth: returned nil after disconnecting; want error")
+	}
+}
+
+func TestReload(t *testing.T) {
+	b, _, _ := newBot(&Config{
+		MaxPoints: 6,
+		Debug:     true,
+	})
+
+	blacklist := make(StringList)
+	blacklist.Set("janet")
+	b.Reload(&Config{
+		MaxPoints:     3,
+		UserBlacklist: blacklist,
+		Aliases:       UserAliases{"t": "troy"},
+		Debug:         false,
+	})
+
+	if b.Config.MaxPoints != 3 {
+		t.Errorf("Reload: MaxPoints is %d; want 3", b.Config.MaxPoints)
+	}
+	if !b.Config.UserBlacklist.Contains("janet") {
+		t.Errorf("Reload: UserBlacklist does not contain %q", "janet")
+	}
+	if b.Config.Aliases["t"] != "troy" {
+		t.Errorf("Reload: alias %q is %q; want %q", "t", b.Config.Aliases["t"], "troy")
+	}
+	if !b.Config.Debug {
+		t.Errorf("Reload: changed Debug, which cannot be reloaded")
+	}
+}
+
+func TestAdminCommands(t *testing.T) {
+	admins := make(StringList)
+	admins.Set("admin")
+	admins.Set("admin_de")
+	b, cs, db := newBot(&Config{
+		Admins: admins,
+	})
+	cs.Locales = map[string]string{"admin_de": "de-DE"}
+
+	// sent returns the texts that janet replied with
+	sent := func() string {
+		var texts []string
+		for _, msg := range cs.SentMessages {
+			texts = append(texts, msg.Text)
+		}
+		return strings.Join(texts, "\n")
+	}
+
+	send := func(user, text string) {
+		cs.SentMessages = nil
+		b.handleMessageEvent(&slack.MessageEvent{
+			Msg: slack.Msg{
+				Type:    "message",
+				Text:    text,
+				Channel: "user",
+				User:    user,
+			},
+		})
+	}
+
+	send("someone", "janet alias add tm troy")
+	if _, err := db.GetAlias("tm"); err != database.ErrNoSuchAlias {
+		t.Errorf("alias add: non-admin added an alias")
+	}
+	if len(cs.SentMessages) == 0 {
+		t.Errorf("alias add: did not refuse non-admin")
+	}
+
+	send("admin", "janet alias add <@TM> troy")
+	if user, err := db.GetAlias("tm"); err != nil || user != "troy" {
+		t.Errorf("alias add: alias %q is %q (%v); want %q", "tm", user, err, "troy")
+	}
+	if want := "is now an alias of"; !strings.Contains(sent(), want) {
+		t.Errorf("alias add: sent %q; want %q", sent(), want)
+	}
+	if user, err := b.parseUser("tm"); err != nil || user != "troy" {
+		t.Errorf("parseUser: returned %q (%v) for an alias; want %q", user, err, "troy")
+	}
+
+	send("admin", "janet alias list")
+	if want := "*aliases*\n"; !strings.HasPrefix(sent(), want) || !strings.Contains(sent(), " => ") {
+		t.Errorf("alias list: sent %q; want %q followed by the aliases", sent(), want)
+	}
+
+	send("admin", "janet alias add tm")
+	if want := "usage: janet alias add <alias> <user>"; !strings.HasPrefix(sent(), want) {
+		t.Errorf("alias add: sent %q; want %q", sent(), want)
+	}
+
+	send("admin", "janet alias remove tm")
+	if _, err := db.GetAlias("tm"); err != database.ErrNoSuchAlias {
+		t.Errorf("alias remove: alias %q still exists", "tm")
+	}
+
+	send("admin", "janet blacklist add onehundred_points")
+	if blacklisted, _ := db.IsBlacklisted("onehundred_points"); !blacklisted {
+		t.Errorf("blacklist add: user %q is not blacklisted", "onehundred_points")
+	}
+
+	send("someone", "onehundred_points++")
+	if u, _ := db.GetUser("onehundred_points"); u.Points != 100 {
+		t.Errorf("blacklist: user %q has %d points; want 100", "onehundred_points", u.Points)
+	}
+
+	send("admin", "janet blacklist remove onehundred_points")
+	if blacklisted, _ := db.IsBlacklisted("onehundred_points"); blacklisted {
+		t.Errorf("blacklist remove: user %q is still blacklisted", "onehundred_points")
+	}
+	if want := "is no longer blacklisted"; !strings.Contains(sent(), want) {
+		t.Errorf("blacklist remove: sent %q; want %q", sent(), want)
+	}
+
+	// replies are in the language of the admin
+	for _, tc := range []struct {
+		text, want string
+	}{
+		{"janet alias remove", "Verwendung: janet alias remove <Alias>"},
+		{"janet alias list", "es gibt keine Aliase"},
+		{"janet blacklist add", "Verwendung: janet blacklist add <Benutzer>"},
+		{"janet blacklist list", "es stehen keine Benutzer auf der Blacklist"},
+	} {
+		send("admin_de", tc.text)
+		if !strings.HasPrefix(sent(), tc.want) {
+			t.Errorf("%s: sent %q; want %q", tc.text, sent(), tc.want)
+		}
+	}
+}
+
+func TestBadJanet(t *testing.T) {
+	b, good, db := newBot(&Config{
+		MaxPoints:        6,
+		LeaderboardLimit: 10,
+	})
+	bad := &TestChatService{}
+	b.Config.BadJanetSlack = bad
+	b.goodJanetConnection.setConnected("UGOOD")
+	b.badJanetConnection.setConnected("UBAD")
+
+	db.InsertPoints(&database.Points{From: "u200", To: "u100", Points: -3})
+
+	send := func(handler func(*slack.MessageEvent), text string) {
+		good.SentMessages, bad.SentMessages = nil, nil
+		handler(&slack.MessageEvent{
+			Msg: slack.Msg{
+				Type:    "message",
+				Text:    text,
+				Channel: "channel",
+				User:    "u200",
+			},
+		})
+	}
+
+	send(b.handleMessageEvent, "<@UBAD> roast <@U100>")
+	if len(good.SentMessages) != 0 || len(bad.SentMessages) != 0 {
+		t.Errorf("roast: good janet handled bad janet's command")
+	}
+
+	// replies may be followed by a quote
+	first := func(cs *TestChatService) string {
+		if len(cs.SentMessages) == 0 {
+			return ""
+		}
+		return cs.SentMessages[0].Text
+	}
+
+	send(b.handleBadJanetMessageEvent, "<@UBAD> roast <@U100>")
+	if !strings.Contains(first(bad), "u100") {
+		t.Errorf("roast: sent %q; want a roast of u100", first(bad))
+	}
+
+	send(b.handleBadJanetMessageEvent, "<@UGOOD> roast")
+	if len(bad.SentMessages) != 0 {
+		t.Errorf("roast: bad janet responded to a mention of good janet")
+	}
+
+	send(b.handleBadJanetMessageEvent, "badjanet bottom 5")
+	if !strings.Contains(first(bad), "lost 3 points") {
+		t.Errorf("bottom: sent %q; want u100 to have lost 3 points", first(bad))
+	}
+
+	send(b.handleBadJanetMessageEvent, "<@U100>++")
+	if u, _ := db.GetUser("u100"); u.Points != -3 {
+		t.Errorf("bad janet applied karma: u100 has %d points; want -3", u.Points)
+	}
+
+	// without bad janet, good janet responds to her commands
+	b.Config.BadJanetSlack = nil
+	send(b.handleMessageEvent, "badjanet bottom")
+	if !strings.Contains(first(good), "lost 3 points") {
+		t.Errorf("bottom: good janet sent %q without bad janet", first(good))
+	}
+}
+
+func TestSingleToken(t *testing.T) {
+	b, cs, _ := newBot(&Config{
+		MaxPoints: 6,
+		BadJanetIdentity: Identity{
+			Name:   "Bad Janet",
+			Icon:   ":smiling_imp:",
+			Prefix: "bad janet: ",
+		},
+	})
+	b.Config.BadJanetSlack = nil
+
+	done := make(chan error)
+	go func() {
+		done <- b.Listen(context.Background())
+	}()
+
+	cs.IncomingEvents <- slack.RTMEvent{Data: &slack.MessageEvent{
+		Msg: slack.Msg{
+			Type:    "message",
+			Text:    "<@U100>--",
+			Channel: "channel",
+			User:    "u200",
+		},
+	}}
+	close(cs.IncomingEvents)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Listen: returned %v; want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Listen: waited for bad janet without a bad janet token")
+	}
+
+	if len(cs.SentAs) == 0 || cs.SentAs[0].Name != "Bad Janet" {
+		t.Errorf("sent %v as; want Bad Janet", cs.SentAs)
+	}
+	if len(cs.SentMessages) == 0 || !strings.HasPrefix(cs.SentMessages[0].Text, "bad janet: u100 now has -1 point") {
+		t.Errorf("sent %v; want bad janet's reply with the prefix", cs.SentMessages)
+	}
+}
+
+func TestDigest(t *testing.T) {
+	for _, spec := range []string{"", "C0123 week", "C0123 year @weekly", "C0123 week 0 9 * *", "C0123 day 0 25 * * *"} {
+		if _, err := ParseDigest(spec); err == nil {
+			t.Errorf("ParseDigest(%q): expected an error", spec)
+		}
+	}
+
+	digest, err := ParseDigest("C0123 Week 0 9 * * mon")
+	if err != nil {
+		t.Fatalf("ParseDigest: %v", err)
+	}
+	if digest.Channel != "C0123" || digest.Window != "week" {
+		t.Errorf("ParseDigest: got channel %q and window %q; want %q and %q", digest.Channel, digest.Window, "C0123", "week")
+	}
+
+	until := time.Date(2019, time.January, 14, 9, 0, 0, 0, time.UTC)
+	if next := digest.Schedule.Next(until.Add(-time.Hour)); !next.Equal(until) {
+		t.Errorf("Schedule.Next: got %v; want %v", next, until)
+	}
+
+	b, cs, db := newBot(&Config{})
+	db.InsertPoints(&database.Points{From: "point_giver", To: "someone", Points: 3})
+	db.InsertPoints(&database.Points{From: "someone", To: "onehundred_points", Points: -5})
+	db.InsertPoints(&database.Points{From: "janet", To: "someone", Points: 50})
+
+	err = b.SendDigest(digest, until)
+	if err != nil {
+		t.Fatalf("SendDigest: %v", err)
+	}
+	if len(cs.SentMessages) == 0 {
+		t.Fatalf("SendDigest: did not send a message")
+	}
+
+	text := cs.SentMessages[0].Text
+	for _, want := range []string{"past week", "Jan 7 - Jan 14", "top movers", "1. \u00f6nehundred_points +95", "most generous", "1. \u03c1oint_giver gave 103", "*total*: 108 points in 3 karma operations"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("SendDigest: message %q does not contain %q", text, want)
+		}
+	}
+	if cs.SentMessages[0].Channel != "C0123" {
+		t.Errorf("SendDigest: sent to %q; want %q", cs.SentMessages[0].Channel, "C0123")
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	type request struct {
+		event, signature string
+		payload          webhook.Payload
+	}
+
+	var (
+		requests = make(chan request, 10)
+		calls    int
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		req := request{event: r.Header.Get(webhook.HeaderEvent)}
+		if webhook.Verify("s3cret", body, r.Header.Get(webhook.HeaderSignature)) {
+			req.signature = "valid"
+		}
+		json.Unmarshal(body, &req.payload)
+		requests <- req
+
+		// the first delivery fails and is retried
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer receiver.Close()
+
+	db, err := database.New(&database.Config{Path: filepath.Join(t.TempDir(), "db.sqlite3")})
+	if err != nil {
+		t.Fatalf("database.New: %v", err)
+	}
+	defer db.Close()
+
+	err = db.InsertWebhook(&database.Webhook{URL: receiver.URL, Secret: "s3cret"})
+	if err != nil {
+		t.Fatalf("InsertWebhook: %v", err)
+	}
+
+	b, _, _ := newBot(&Config{
+		MaxPoints:    6,
+		Achievements: []*achievement.Milestone{{Kind: achievement.Total, Value: 2}},
+	})
+	b.Config.DB = db
+	b.handleMessageEvent(&slack.MessageEvent{
+		Msg: slack.Msg{
+			Type:    "message",
+			Text:    "<@U100>+++",
+			Channel: "channel",
+			User:    "u200",
+		},
+	})
+
+	sender := webhook.New(db, b.Config.Log)
+	sender.Backoff, sender.MaxBackoff = 0, 0
+	for i := 0; i < 2; i++ {
+		err := sender.Deliver(context.Background())
+		if err != nil {
+			t.Fatalf("Deliver: %v", err)
+		}
+	}
+	close(requests)
+
+	var events []string
+	for req := range requests {
+		events = append(events, req.event)
+		if req.signature != "valid" {
+			t.Errorf("%s: invalid signature", req.event)
+		}
+		if req.payload.Event != req.event {
+			t.Errorf("%s: payload has event %q", req.event, req.payload.Event)
+		}
+	}
+	if want := "karma.insert karma.milestone karma.insert"; strings.Join(events, " ") != want {
+		t.Errorf("received %v; want %s", events, want)
+	}
+
+	deliveries, err := db.GetDeliveries(0)
+	if err != nil {
+		t.Fatalf("GetDeliveries: %v", err)
+	}
+	for _, d := range deliveries {
+		if d.Status != database.DeliveryDelivered {
+			t.Errorf("delivery %d of %s: status %s; want delivered", d.ID, d.Event, d.Status)
+		}
+	}
+	if len(deliveries) != 2 || deliveries[1].Attempts != 2 {
+		t.Errorf("got %d deliveries; want 2, the insert after 2 attempts", len(deliveries))
+	}
+}
+
+func TestAchievements(t *testing.T) {
+	b, cs, db := newBot(&Config{
+		MaxPoints:    10,
+		Achievements: achievement.Default(),
+	})
+
+	send := func(text string) {
+		cs.SentMessages = nil
+		b.handleMessageEvent(&slack.MessageEvent{
+			Msg: slack.Msg{
+				Type:    "message",
+				Text:    text,
+				Channel: "channel",
+				User:    "u200",
+			},
+		})
+	}
+
+	texts := func() string {
+		var texts []string
+		for _, msg := range cs.SentMessages {
+			texts = append(texts, msg.Text)
+		}
+		return strings.Join(texts, "\n")
+	}
+
+	db.InsertPoints(&database.Points{From: "u200", To: "u200", Points: 5})
+	send("<@U100>+++")
+	if !strings.Contains(texts(), "u100 unlocked :seedling: first karma!") {
+		t.Errorf("first karma: sent %q; want an announcement", texts())
+	}
+
+	// the test database has no timestamps, so all karma was given last month
+	if !strings.Contains(texts(), "point_giver unlocked :gift: most generous of") {
+		t.Errorf("most generous: sent %q; want an announcement", texts())
+	}
+
+	send("<@U100>+++++++++")
+	if !strings.Contains(texts(), "u100 unlocked :trophy: 10 points!") {
+		t.Errorf("10 points: sent %q; want an announcement", texts())
+	}
+	if strings.Contains(texts(), "first karma") {
+		t.Errorf("first karma: announced twice")
+	}
+
+	send("<@U100>--")
+	send("<@U100>++")
+	if strings.Contains(texts(), "unlocked") {
+		t.Errorf("sent %q; want 10 points to be announced once", texts())
+	}
+
+	send("<@U100>==")
+	if want := "badges: :seedling: first karma · :trophy: 10 points"; !strings.Contains(texts(), want) {
+		t.Errorf("query: sent %q; want %q", texts(), want)
+	}
+
+	milestones := 0
+	for _, event := range db.events {
+		if event == "karma.milestone" {
+			milestones++
+		}
+	}
+	if milestones != 3 {
+		t.Errorf("sent %d milestone events to the webhooks; want 3", milestones)
+	}
+}
+
+func TestRewards(t *testing.T) {
+	admins := make(StringList)
+	admins.Set("admin")
+	b, cs, db := newBot(&Config{
+		Admins: admins,
+	})
+	cs.Users = []slack.User{{ID: "admin", Name: "admin"}}
+	db.rewards = []*database.Reward{
+		{Name: "coffee", Cost: 10, Description: "a coffee on the house"},
+		{Name: "cruise", Cost: 1000},
+		{Name: "lunch", Cost: 95},
+	}
+
+	send := func(user, text string) {
+		cs.SentMessages = nil
+		b.handleMessageEvent(&slack.MessageEvent{
+			Msg: slack.Msg{
+				Type:    "message",
+				Text:    text,
+				Channel: "channel",
+				User:    user,
+			},
+		})
+	}
+
+	// sent returns the texts sent to a channel
+	sent := func(channel string) string {
+		var texts []string
+		for _, msg := range cs.SentMessages {
+			if msg.Channel == channel {
+				texts = append(texts, msg.Text)
+			}
+		}
+		return strings.Join(texts, "\n")
+	}
+
+	balance := func() int {
+		u, _ := db.GetUser("onehundred_points")
+		return u.Balance
+	}
+
+	send("onehundred_points", "janet rewards")
+	if want := "*coffee*: 10 points (a coffee on the house)"; !strings.Contains(sent("channel"), want) {
+		t.Errorf("rewards: sent %q; want %q", sent("channel"), want)
+	}
+
+	send("onehundred_points", "janet redeem cruise")
+	if want := "cruise costs 1,000 points, but you only have 100."; !strings.Contains(sent("channel"), want) {
+		t.Errorf("redeem: sent %q; want %q", sent("channel"), want)
+	}
+
+	send("onehundred_points", "janet redeem coffee")
+	if balance() != 90 {
+		t.Errorf("redeem: user has %d points; want 90", balance())
+	}
+	if want := `reply "janet redeem approve 1"`; !strings.Contains(sent("admin"), want) {
+		t.Errorf("redeem: sent %q to the admin; want %q", sent("admin"), want)
+	}
+
+	send("onehundred_points", "janet redeem approve 1")
+	if db.redemptions[0].Status != database.RedemptionPending {
+		t.Errorf("redeem approve: non-admin decided redemption")
+	}
+
+	send("admin", "janet redeem deny 1 out of beans")
+	if balance() != 100 {
+		t.Errorf("redeem deny: user has %d points; want a refund to 100", balance())
+	}
+	if want := "your *coffee* was denied: out of beans."; !strings.Contains(sent("onehundred_points"), want) {
+		t.Errorf("redeem deny: sent %q to the user; want %q", sent("onehundred_points"), want)
+	}
+
+	send("admin", "janet redeem approve 1")
+	if want := "redemption #1 was already denied."; !strings.Contains(sent("channel"), want) {
+		t.Errorf("redeem approve: sent %q; want %q", sent("channel"), want)
+	}
+
+	send("onehundred_points", "janet redeem coffee")
+	send("admin", "janet redeem approve 2")
+	if balance() != 90 || db.redemptions[1].Status != database.RedemptionApproved {
+		t.Errorf("redeem approve: user has %d points and redemption is %s; want 90 and approved", balance(), db.redemptions[1].Status)
+	}
+
+	// only the balance can be spent, not the lifetime karma
+	send("onehundred_points", "janet redeem lunch")
+	if want := "lunch costs 95 points, but you only have 90."; !strings.Contains(sent("channel"), want) {
+		t.Errorf("redeem: sent %q; want %q", sent("channel"), want)
+	}
+
+	// spending karma leaves the lifetime karma alone
+	cs.SentMessages = nil
+	b.queryPoints(&slack.MessageEvent{Msg: slack.Msg{Text: "onehundred_points==", Channel: "channel"}})
+	if want := "onehundred_points == 100\n90 points left to spend"; !strings.Contains(sent("channel"), want) {
+		t.Errorf("query: sent %q; want %q", sent("channel"), want)
+	}
+}
+
+func TestTransfers(t *testing.T) {
+	b, cs, db := newBot(&Config{
+		Transfers: TransferConfig{Max: 20, Daily: 30},
+	})
+
+	send := func(user, text string) {
+		cs.SentMessages = nil
+		b.handleMessageEvent(&slack.MessageEvent{
+			Msg: slack.Msg{
+				Type:    "message",
+				Text:    text,
+				Channel: "channel",
+				User:    user,
+			},
+		})
+	}
+
+	// sent returns the texts sent to a channel
+	sent := func(channel string) string {
+		var texts []string
+		for _, msg := range cs.SentMessages {
+			if msg.Channel == channel {
+				texts = append(texts, msg.Text)
+			}
+		}
+		return strings.Join(texts, "\n")
+	}
+
+	for _, tc := range []struct {
+		user, text, want string
+	}{
+		{"onehundred_points", "janet give <@U100> 50", "you can give at most 20 points at once."},
+		{"onehundred_points", "janet give <@U100>", "usage: janet give <user> <points> [for <reason>]"},
+		{"onehundred_points", "janet give <@onehundred>", "usage: janet give <user> <points> [for <reason>]"},
+	} {
+		send(tc.user, tc.text)
+		if !strings.Contains(sent("channel"), tc.want) {
+			t.Errorf("%s: sent %q; want %q", tc.text, sent("channel"), tc.want)
+		}
+	}
+
+	send("onehundred_points", "janet give <@U100> 15 from my balance for the help")
+	if want := "gave you 15 points from their balance for the help."; !strings.Contains(sent("U100"), want) {
+		t.Errorf("give: sent %q to the receiver; want %q", sent("U100"), want)
+	}
+	if want := "you have 85 left to spend."; !strings.Contains(sent("onehundred_points"), want) {
+		t.Errorf("give: sent %q to the giver; want %q", sent("onehundred_points"), want)
+	}
+
+	giver, _ := db.GetUser("onehundred_points")
+	receiver, _ := db.GetUser("u100")
+	if giver.Points != 100 || giver.Balance != 85 || receiver.Points != 0 || receiver.Balance != 15 {
+		t.Errorf("give: giver has %d/%d and receiver %d/%d points; want 100/85 and 0/15",
+			giver.Points, giver.Balance, receiver.Points, receiver.Balance)
+	}
+	for _, r := range db.records[len(db.records)-2:] {
+		if r.Type != database.TypeTransfer {
+			t.Errorf("give: recorded %+v; want a transfer", r)
+		}
+	}
+	if want := "karma.insert karma.insert"; strings.Join(db.events, " ") != want {
+		t.Errorf("give: queued %v; want %s", db.events, want)
+	}
+
+	send("onehundred_points", "janet give <@U100> 20")
+	if want := "you can give 30 points a day and have 15 left for now."; !strings.Contains(sent("channel"), want) {
+		t.Errorf("give over daily limit: sent %q; want %q", sent("channel"), want)
+	}
+
+	send("U100", "janet tip <@U200> 16")
+	if want := "you only have 15 points to give."; !strings.Contains(sent("channel"), want) {
+		t.Errorf("give over balance: sent %q; want %q", sent("channel"), want)
+	}
+
+	send("U100", "janet give <@U100> 5")
+	if want := "You cannot give yourself points."; !strings.Contains(sent("channel"), want) {
+		t.Errorf("give to self: sent %q; want %q", sent("channel"), want)
+	}
+}
+
+func TestPersona(t *testing.T) {
+	dir := t.TempDir()
+	load := func(name, content string) (*persona.Catalog, error) {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return persona.Load(path)
+	}
+
+	for name, content := range map[string]string{
+		"unknown message": "good:\n  messages:\n    hello: hi\n",
+		"unknown field":   "bad:\n  messages:\n    selfpoints: \"{{.Points}}\"\n",
+		"probability":     "channels:\n  C0123:\n    quoteprobability: 2\n",
+		"unknown option":  "ugly:\n  quotes: []\n",
+	} {
+		if _, err := load("invalid.yaml", content); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	catalog, err := load("catalog.yaml", `
+good:
+  quoteprobability: 1
+  quotes: ["take it sleazy"]
+  messages:
+    selfpoints: "nice try, {{.User}}."
+bad:
+  quoteprobability: 0
+  messages:
+    points: "{{bold .User}} blew it, {{bold .Points}} points"
+channels:
+  quiet:
+    quotes: false
+`)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	b, cs, _ := newBot(&Config{
+		MaxPoints: 6,
+		Catalog:   catalog,
+	})
+
+	send := func(channel, text string) []string {
+		cs.SentMessages = nil
+		b.handleMessageEvent(&slack.MessageEvent{
+			Msg: slack.Msg{
+				Type:    "message",
+				Text:    text,
+				Channel: channel,
+				User:    "u200",
+			},
+		})
+
+		var sent []string
+		for _, msg := range cs.SentMessages {
+			sent = append(sent, msg.Text)
+		}
+		return sent
+	}
+
+	for _, tc := range []struct {
+		channel, text string
+		want          []string
+	}{
+		{"channel", "<@u200>++", []string{"nice try, u200.", "take it sleazy"}},
+		{"quiet", "<@u200>++", []string{"nice try, u200."}},
+		{"channel", "<@U100>--", []string{"u100 blew it, -1 points(-1)"}},
+	} {
+		if sent := send(tc.channel, tc.text); strings.Join(sent, "|") != strings.Join(tc.want, "|") {
+			t.Errorf("%s in %s: sent %q; want %q", tc.text, tc.channel, sent, tc.want)
+		}
+	}
+}
+
+func TestLocale(t *testing.T) {
+	en, de := i18n.Default(), i18n.Match("de-DE")
+	fr, err := i18n.Lookup("fr_FR")
+	if de == nil || err != nil {
+		t.Fatalf("could not look up german and french")
+	}
+	if _, err := i18n.Lookup("tlh"); err == nil {
+		t.Errorf("Lookup: expected an error for an unsupported locale")
+	}
+	if l := i18n.AcceptLanguage("tlh, fr-CH;q=0.9, en;q=0.8", en); l != fr {
+		t.Errorf("AcceptLanguage: got %s; want fr", l.Tag())
+	}
+
+	for _, tc := range []struct {
+		got, want string
+	}{
+		{en.Number(-1234567), "-1,234,567"},
+		{de.Number(1234), "1.234"},
+		{de.Signed(5), "+5"},
+		{en.Plural(0, "point", "points"), "points"},
+		{fr.Plural(0, "point", "points"), "point"},
+		{en.Date(time.Date(2019, time.March, 4, 0, 0, 0, 0, time.UTC)), "Mar 4"},
+		{de.Date(time.Date(2019, time.March, 4, 0, 0, 0, 0, time.UTC)), "4. März"},
+		{en.Ago(time.Unix(0, 0), time.Unix(0, 0)), "now"},
+		{en.Ago(time.Unix(0, 0), time.Unix(3600, 0)), "1 hour ago"},
+		{de.Ago(time.Unix(0, 0), time.Unix(3*86400, 0)), "vor 3 Tagen"},
+	} {
+		if tc.got != tc.want {
+			t.Errorf("got %q; want %q", tc.got, tc.want)
+		}
+	}
+
+	b, cs, _ := newBot(&Config{
+		MaxPoints: 6,
+		Locale:    fr,
+	})
+	cs.Locales = map[string]string{"U300": "de-DE", "U400": "tlh"}
+
+	for _, tc := range []struct {
+		user, want string
+	}{
+		{"U300", "u100 hat jetzt 1 Punkt(+1)"},
+		{"U400", "u100 a maintenant 2 points(+1)"},
+	} {
+		cs.SentMessages = nil
+		b.handleMessageEvent(&slack.MessageEvent{
+			Msg: slack.Msg{
+				Type:    "message",
+				Text:    "<@U100>++",
+				Channel: "channel",
+				User:    tc.user,
+			},
+		})
+
+		if len(cs.SentMessages) == 0 || cs.SentMessages[0].Text != tc.want {
+			t.Errorf("%s: sent %v; want %q", tc.user, cs.SentMessages, tc.want)
+		}
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	b, cs, _ := newBot(&Config{
+		Blocks:           true,
+		LeaderboardLimit: 10,
+		MaxPoints:        6,
+		UI:               blankui.New(),
+	})
+
+	send := func(text string) *SentBlocks {
+		cs.SentBlocks, cs.SentMessages = nil, nil
+		b.handleMessageEvent(&slack.MessageEvent{
+			Msg: slack.Msg{
+				Type:    "message",
+				Text:    text,
+				Channel: "channel",
+				User:    "point_giver",
+			},
+		})
+
+		if len(cs.SentBlocks) == 0 {
+			t.Fatalf("%q: did not send any blocks", text)
+		}
+		return cs.SentBlocks[0]
+	}
+
+	sent := send("<@U100>++")
+	if want := "u100 now has 1 point(+1)"; sent.Text != want {
+		t.Errorf("points: text fallback is %q; want %q", sent.Text, want)
+	}
+	if len(sent.Blocks) != 2 {
+		t.Fatalf("points: sent %d blocks; want 2", len(sent.Blocks))
+	}
+	section, ok := sent.Blocks[0].(*slack.SectionBlock)
+	if !ok {
+		t.Fatalf("points: first block is %T; want a section", sent.Blocks[0])
+	}
+	if want := "*u100* now has *1* point"; section.Text.Text != want {
+		t.Errorf("points: section is %q; want %q", section.Text.Text, want)
+	}
+	if section.Accessory == nil || section.Accessory.ImageElement == nil || section.Accessory.ImageElement.ImageURL != "https://avatars.example.com/U100" {
+		t.Errorf("points: section does not show the avatar of u100")
+	}
+
+	sent = send("goodplace top 2")
+	if len(sent.Blocks) != 3 {
+		t.Fatalf("leaderboard: sent %d blocks; want a header and 2 users", len(sent.Blocks))
+	}
+	if !strings.Contains(sent.Text, "1. \u00f6nehundred_points == 100") {
+		t.Errorf("leaderboard: text fallback %q does not list onehundred_points", sent.Text)
+	}
+
+	// janet has only seen the slack ID of u100
+	for i, elements := range []int{1, 2} {
+		context, ok := sent.Blocks[i+1].(*slack.ContextBlock)
+		if !ok || len(context.ContextElements.Elements) != elements {
+			t.Errorf("leaderboard: expected a context block with %d elements, got %#v", elements, sent.Blocks[i+1])
+		}
+	}
+
+	b.Config.Blocks = false
+	cs.SentBlocks, cs.SentMessages = nil, nil
+	b.handleMessageEvent(&slack.MessageEvent{
+		Msg: slack.Msg{
+			Type:    "message",
+			Text:    "<@U100>==",
+			Channel: "channel",
+			User:    "point_giver",
+		},
+	})
+	if len(cs.SentBlocks) != 0 || len(cs.SentMessages) == 0 {
+		t.Errorf("blocks disabled: sent %d blocks and %d messages; want only messages", len(cs.SentBlocks), len(cs.SentMessages))
+	}
+}
+
 func TestHandleSlackEvent(t *testing.T) {
 	tt := []struct {
 		Name                 string
@@ -7,3 +7,11 @@ func min(a, b int) int {
 
 	return b
 }
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
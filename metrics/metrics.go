@@ -0,0 +1,96 @@
+// Package metrics contains the Prometheus collectors
+// that janet exposes on /metrics.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "janet"
+
+var (
+	// KarmaOperations counts karma operations by sign
+	// (positive/negative) and source (message/reactji).
+	KarmaOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "karma_operations_total",
+		Help:      "Number of karma operations applied.",
+	}, []string{"sign", "source"})
+
+	// Commands counts the chat commands that were handled.
+	Commands = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "commands_total",
+		Help:      "Number of chat commands handled, by command.",
+	}, []string{"command"})
+
+	// SlackEvents counts the real-time events received
+	// from Slack by each janet.
+	SlackEvents = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "slack_events_total",
+		Help:      "Number of Slack RTM events received, by janet and event type.",
+	}, []string{"janet", "type"})
+
+	// HandlerErrors counts errors that occurred while
+	// handling Slack events.
+	HandlerErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "handler_errors_total",
+		Help:      "Number of errors that occurred while handling Slack events.",
+	})
+
+	// RTMReconnects counts how many times each janet had
+	// to reconnect to Slack.
+	RTMReconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rtm_reconnects_total",
+		Help:      "Number of Slack RTM reconnects, by janet.",
+	}, []string{"janet"})
+
+	// DBQueryDuration tracks database query latency by query.
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Latency of database queries, by query.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"query"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		KarmaOperations,
+		Commands,
+		SlackEvents,
+		HandlerErrors,
+		RTMReconnects,
+		DBQueryDuration,
+	)
+}
+
+// Sign returns the sign label for a karma operation.
+func Sign(points int) string {
+	if points < 0 {
+		return "negative"
+	}
+
+	return "positive"
+}
+
+// ObserveQuery records how long a database query took. It is
+// meant to be deferred at the start of the query:
+//
+//	defer metrics.ObserveQuery("get_user", time.Now())
+func ObserveQuery(query string, start time.Time) {
+	DBQueryDuration.WithLabelValues(query).Observe(time.Since(start).Seconds())
+}
+
+// Handler returns an http.Handler that serves the metrics
+// in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
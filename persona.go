@@ -0,0 +1,39 @@
+package janet
+
+import (
+	"github.com/troyxmccall/janet/i18n"
+	"github.com/troyxmccall/janet/persona"
+)
+
+// personaOf returns the persona of Good Janet or Bad Janet.
+func personaOf(whichJanet string) string {
+	if whichJanet == "badJanet" {
+		return persona.Bad
+	}
+
+	return persona.Good
+}
+
+// message renders a message of the catalog as plain text.
+func (b *Bot) message(locale *i18n.Locale, whichJanet, key string, data persona.Data) string {
+	return b.Config.Catalog.Message(personaOf(whichJanet), key, locale, false, data)
+}
+
+// markdownMessage renders a message of the catalog for blocks.
+func (b *Bot) markdownMessage(locale *i18n.Locale, whichJanet, key string, data persona.Data) string {
+	return b.Config.Catalog.Message(personaOf(whichJanet), key, locale, true, data)
+}
+
+// sendQuote sometimes follows up a message with a quote,
+// depending on the catalog.
+func (b *Bot) sendQuote(channel, thread, whichJanet string) {
+	quote := b.Config.Catalog.Quote(personaOf(whichJanet), channel)
+	if quote == "" {
+		return
+	}
+
+	err := b.post(quote, nil, channel, thread, whichJanet)
+	if err != nil {
+		b.Config.Log.Err(err).KV("channel", channel).Error("could not send quote")
+	}
+}
@@ -0,0 +1,360 @@
+// Package persona contains the message catalog that defines how
+// Good Janet and Bad Janet talk: the templates of their replies,
+// their quotes, how often they quote and in which channels.
+package persona
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/troyxmccall/janet/i18n"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// The personas.
+const (
+	Good = "good"
+	Bad  = "bad"
+)
+
+// The messages of the catalog.
+const (
+	MessageBroken      = "broken"
+	MessageSelfPoints  = "selfpoints"
+	MessagePoints      = "points"
+	MessageQuery       = "query"
+	MessageThrowback   = "throwback"
+	MessageNoThrowback = "nothrowback"
+	MessageLeaderboard = "leaderboard"
+	MessageNotAdmin    = "notadmin"
+	MessageDigest      = "digest"
+	MessageQuietDigest = "quietdigest"
+	MessageAchievement = "achievement"
+)
+
+// DefaultQuoteProbability is the default chance of a
+// quote being sent after a message.
+const DefaultQuoteProbability = 0.2
+
+// messageFields contain sample values of the fields that the
+// templates of each message may use. They are used to check
+// the templates when loading them.
+var messageFields = map[string]Data{
+	MessageBroken:      {"Error": ""},
+	MessageSelfPoints:  {"User": ""},
+	MessagePoints:      {"User": "", "Points": 0, "Change": "", "Reason": ""},
+	MessageQuery:       {"User": "", "Points": 0},
+	MessageThrowback:   {"To": "", "From": "", "Points": 0, "Date": "", "Reason": ""},
+	MessageNoThrowback: {"User": ""},
+	MessageLeaderboard: {"Limit": 0},
+	MessageNotAdmin:    {},
+	MessageDigest:      {"Window": "", "Since": "", "Until": ""},
+	MessageQuietDigest: {"Window": ""},
+	MessageAchievement: {"User": "", "Badge": ""},
+}
+
+// roastFields contain sample values of the fields that roasts may use.
+var roastFields = Data{"User": "", "Points": 0}
+
+// Data is the data passed to a message template.
+type Data map[string]interface{}
+
+// A Persona is the voice of one of the janets.
+type Persona struct {
+	// messages are the templates that replace the
+	// messages of the locales, keyed by message
+	messages map[string]*template.Template
+
+	// roasts are the templates of the persona's roasts
+	roasts []*template.Template
+
+	quotes      []string
+	probability float64
+}
+
+// A Channel overrides how the janets quote in a channel.
+type Channel struct {
+	Quotes           *bool    `yaml:"quotes" toml:"quotes"`
+	QuoteProbability *float64 `yaml:"quoteprobability" toml:"quoteprobability"`
+}
+
+// A Catalog contains both personas and the channel overrides.
+type Catalog struct {
+	personas map[string]*Persona
+	channels map[string]*Channel
+}
+
+// file is the format of a catalog file.
+type file struct {
+	Good     *personaFile        `yaml:"good" toml:"good"`
+	Bad      *personaFile        `yaml:"bad" toml:"bad"`
+	Channels map[string]*Channel `yaml:"channels" toml:"channels"`
+}
+
+type personaFile struct {
+	Messages         map[string]string `yaml:"messages" toml:"messages"`
+	Quotes           []string          `yaml:"quotes" toml:"quotes"`
+	QuoteProbability *float64          `yaml:"quoteprobability" toml:"quoteprobability"`
+	Roasts           []string          `yaml:"roasts" toml:"roasts"`
+}
+
+// Default returns the catalog of janet's built-in quotes,
+// which uses the messages of the locales.
+func Default() *Catalog {
+	catalog, err := newCatalog(&file{})
+	if err != nil {
+		panic(err)
+	}
+
+	return catalog
+}
+
+// Load reads a catalog file. The format is determined by the file
+// extension. Messages that are missing from the file are taken
+// from the locales, quotes that are missing keep their defaults
+// and Bad Janet's messages default to Good Janet's.
+func Load(path string) (*Catalog, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &file{}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.UnmarshalStrict(data, f)
+	case ".toml":
+		var meta toml.MetaData
+		meta, err = toml.DecodeReader(bytes.NewReader(data), f)
+		if err == nil && len(meta.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown options %v", meta.Undecoded())
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported catalog format, please use .yaml, .yml or .toml", path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	catalog, err := newCatalog(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return catalog, nil
+}
+
+func newCatalog(f *file) (*Catalog, error) {
+	var problems []string
+
+	good, bad := f.Good, f.Bad
+	if good == nil {
+		good = &personaFile{}
+	}
+	if bad == nil {
+		bad = &personaFile{}
+	}
+
+	goodMessages := merge(nil, good.Messages)
+	badMessages := merge(goodMessages, bad.Messages)
+
+	catalog := &Catalog{
+		personas: make(map[string]*Persona),
+		channels: f.Channels,
+	}
+
+	for _, p := range []struct {
+		name     string
+		file     *personaFile
+		messages map[string]string
+		quotes   []string
+		roasts   []string
+	}{
+		{Good, good, goodMessages, goodQuotes, nil},
+		{Bad, bad, badMessages, badQuotes, badRoasts},
+	} {
+		persona, err := newPersona(p.messages)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", p.name, err))
+			continue
+		}
+
+		persona.quotes = p.quotes
+		if p.file.Quotes != nil {
+			persona.quotes = p.file.Quotes
+		}
+
+		roasts := p.roasts
+		if p.file.Roasts != nil {
+			roasts = p.file.Roasts
+		}
+		for i, roast := range roasts {
+			t, err := check(fmt.Sprintf("roast %d", i+1), roast, roastFields)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", p.name, err))
+				continue
+			}
+
+			persona.roasts = append(persona.roasts, t)
+		}
+
+		persona.probability = DefaultQuoteProbability
+		if p.file.QuoteProbability != nil {
+			persona.probability = *p.file.QuoteProbability
+			if !validProbability(persona.probability) {
+				problems = append(problems, fmt.Sprintf("%s: quoteprobability must be between 0 and 1", p.name))
+			}
+		}
+
+		catalog.personas[p.name] = persona
+	}
+
+	for id, channel := range f.Channels {
+		if channel == nil {
+			problems = append(problems, fmt.Sprintf("channel %s has no options", id))
+			continue
+		}
+
+		if channel.QuoteProbability != nil && !validProbability(*channel.QuoteProbability) {
+			problems = append(problems, fmt.Sprintf("channel %s: quoteprobability must be between 0 and 1", id))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("invalid catalog: %s", strings.Join(problems, "; "))
+	}
+
+	return catalog, nil
+}
+
+// merge returns the messages with the overrides applied.
+func merge(messages, overrides map[string]string) map[string]string {
+	merged := make(map[string]string, len(messages))
+	for key, message := range messages {
+		merged[key] = message
+	}
+	for key, message := range overrides {
+		merged[key] = message
+	}
+
+	return merged
+}
+
+func validProbability(p float64) bool {
+	return p >= 0 && p <= 1
+}
+
+// newPersona parses the message templates and checks
+// that they only use the fields of their message.
+func newPersona(messages map[string]string) (*Persona, error) {
+	persona := &Persona{
+		messages: make(map[string]*template.Template),
+	}
+
+	for key, message := range messages {
+		sample, ok := messageFields[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown message %q", key)
+		}
+
+		t, err := check(key, message, sample)
+		if err != nil {
+			return nil, err
+		}
+
+		persona.messages[key] = t
+	}
+
+	return persona, nil
+}
+
+// check parses a template and makes sure that it
+// can be executed with the sample data.
+func check(name, text string, sample Data) (*template.Template, error) {
+	t, err := i18n.Parse(name, text)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = i18n.Default().Execute(t, false, sample)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Message renders a message of a persona for a locale. If markdown
+// is set, the message is formatted with Slack markdown.
+func (c *Catalog) Message(persona, key string, locale *i18n.Locale, markdown bool, data Data) string {
+	t, ok := c.persona(persona).messages[key]
+	if !ok {
+		return locale.Render(key, markdown, data)
+	}
+
+	text, err := locale.Execute(t, markdown, data)
+	if err != nil {
+		// the templates are checked when loading, so this is
+		// a bug in janet rather than in the catalog
+		return fmt.Sprintf("%s: %v", key, err)
+	}
+
+	return text
+}
+
+// Roast renders a random roast of a persona for a locale,
+// or returns an empty string if the persona has none.
+func (c *Catalog) Roast(persona string, locale *i18n.Locale, data Data) string {
+	roasts := c.persona(persona).roasts
+	if len(roasts) == 0 {
+		return ""
+	}
+
+	text, err := locale.Execute(roasts[rand.Intn(len(roasts))], false, data)
+	if err != nil {
+		return ""
+	}
+
+	return text
+}
+
+// Quote randomly returns one of the persona's quotes to send after
+// a message to a channel, or an empty string if there is none.
+func (c *Catalog) Quote(persona, channel string) string {
+	p := c.persona(persona)
+
+	probability := p.probability
+	if override, ok := c.channels[channel]; ok {
+		if override.Quotes != nil && !*override.Quotes {
+			return ""
+		}
+		if override.QuoteProbability != nil {
+			probability = *override.QuoteProbability
+		}
+	}
+
+	if len(p.quotes) == 0 || rand.Float64() >= probability {
+		return ""
+	}
+
+	return p.quotes[rand.Intn(len(p.quotes))]
+}
+
+func (c *Catalog) persona(name string) *Persona {
+	if p, ok := c.personas[name]; ok {
+		return p
+	}
+
+	return c.personas[Good]
+}
@@ -87,6 +87,36 @@ var regexTests = map[regexPattern]regexTestSuite{
 			"janet karma highscores",
 		},
 	},
+	regexPattern{
+		Regex: regexps.Roast,
+		Name:  "roast",
+	}: regexTestSuite{
+		true: []string{
+			"badjanet roast",
+			"badjanet roast <@U1934>",
+			"<@U1934> roast",
+			"<@U1934>: roast user",
+		},
+		false: []string{
+			"badjanet roast two users",
+			"janet roast",
+			"roast <@U1934>",
+		},
+	},
+	regexPattern{
+		Regex: regexps.Bottom,
+		Name:  "bottom",
+	}: regexTestSuite{
+		true: []string{
+			"badjanet bottom",
+			"badjanet bottom 10",
+			"<@U1934> lowscores 3",
+		},
+		false: []string{
+			"badjanet bottom 913f",
+			"janet bottom 10",
+		},
+	},
 	regexPattern{
 		Regex: regexps.SlackUser,
 		Name:  "slack user",
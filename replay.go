@@ -0,0 +1,26 @@
+package janet
+
+import (
+	"github.com/nlopes/slack"
+)
+
+// ReplayMessage applies the karma operations in a message the same
+// way as if it had just been received from Slack. All other commands
+// are ignored, so that replaying old messages has no side effects
+// other than the inserted karma.
+func (b *Bot) ReplayMessage(ev *slack.MessageEvent) {
+	if ev.Type != "message" {
+		return
+	}
+
+	b.convertMotivate(ev)
+
+	for _, split := range splitKarma(ev.Text) {
+		switch {
+		case regexps.GivePoints.MatchString(split):
+			b.applyPoints(ev, "", split)
+		case regexps.TakePoints.MatchString(split):
+			b.applyPoints(ev, "badJanet", split)
+		}
+	}
+}
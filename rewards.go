@@ -0,0 +1,207 @@
+package janet
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/troyxmccall/janet/database"
+	"github.com/troyxmccall/janet/munge"
+	"github.com/troyxmccall/janet/persona"
+
+	"github.com/nlopes/slack"
+)
+
+// printRewards lists the rewards that users can redeem.
+func (b *Bot) printRewards(ev *slack.MessageEvent) {
+	locale := b.locale(ev.User)
+
+	rewards, err := b.Config.DB.GetRewards()
+	if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+		return
+	}
+
+	if len(rewards) == 0 {
+		b.SendMessage(locale.T("norewards"), ev.Channel, ev.ThreadTimestamp, "")
+		return
+	}
+
+	text := "*" + locale.T("rewards") + "*\n"
+	for _, reward := range rewards {
+		text += locale.Render("rewards.line", true, persona.Data{
+			"Reward":      reward.Name,
+			"Cost":        reward.Cost,
+			"Description": reward.Description,
+		}) + "\n"
+	}
+	text += locale.T("rewards.help")
+
+	b.SendMessage(text, ev.Channel, ev.ThreadTimestamp, "")
+}
+
+// redeem spends the sender's karma on a reward and asks the
+// admins to approve it, or lets an admin approve or deny a
+// redemption.
+func (b *Bot) redeem(ev *slack.MessageEvent) {
+	match := regexps.Redeem.FindStringSubmatch(ev.Text)
+	if len(match) == 0 {
+		return
+	}
+
+	if match[1] != "" {
+		b.decideRedemption(ev, match[1] == "approve", match[2], match[3])
+		return
+	}
+
+	locale := b.locale(ev.User)
+	reply := func(key string, data persona.Data) {
+		b.SendMessage(locale.Render(key, true, data), ev.Channel, ev.ThreadTimestamp, "")
+	}
+
+	if match[4] == "" {
+		reply("redeem.usage", nil)
+		return
+	}
+
+	// nobody could approve it
+	if len(b.Config.Admins) == 0 {
+		reply("noadmins", nil)
+		return
+	}
+
+	name, err := b.getUserNameByID(ev.User)
+	if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+		return
+	}
+	user, err := b.parseUser(name)
+	if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+		return
+	}
+	user = strings.ToLower(user)
+
+	reward := strings.ToLower(match[4])
+	redemption, err := b.Config.DB.Redeem(user, reward)
+	switch {
+	case err == database.ErrNoSuchReward:
+		reply("nosuchreward", persona.Data{"Reward": reward})
+		return
+
+	case err == database.ErrInsufficientPoints:
+		balance := 0
+		if u, err := b.Config.DB.GetUser(user); err == nil {
+			balance = u.Balance
+		}
+
+		reply("insufficientpoints", persona.Data{"Reward": reward, "Cost": redemption.Cost, "Points": balance})
+		return
+
+	case b.handleError(err, ev.Channel, ev.ThreadTimestamp):
+		return
+	}
+
+	b.Config.Log.KV("user", user).KV("reward", reward).KV("redemption", redemption.ID).Info("reward redeemed")
+
+	data := persona.Data{
+		"ID":     redemption.ID,
+		"User":   munge.Munge(user),
+		"Reward": reward,
+		"Cost":   redemption.Cost,
+	}
+	reply("redeemed", data)
+
+	for admin := range b.Config.Admins {
+		id := b.userID(admin)
+		if id == "" {
+			b.Config.Log.KV("admin", admin).Info("could not find admin to approve redemption")
+			continue
+		}
+
+		b.DMUser(b.locale(id).Render("redemption.request", true, data), id, "", "")
+	}
+}
+
+// decideRedemption approves or denies a redemption and
+// lets the user who made it know.
+func (b *Bot) decideRedemption(ev *slack.MessageEvent, approve bool, id, note string) {
+	if !b.mustBeAdmin(ev) {
+		return
+	}
+
+	locale := b.locale(ev.User)
+	reply := func(key string, data persona.Data) {
+		b.SendMessage(locale.Render(key, true, data), ev.Channel, ev.ThreadTimestamp, "")
+	}
+
+	n, err := strconv.ParseInt(id, 10, 64)
+	if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+		return
+	}
+
+	admin, err := b.getUserNameByID(ev.User)
+	if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+		return
+	}
+
+	redemption, err := b.Config.DB.DecideRedemption(n, strings.ToLower(admin), approve, note)
+	switch {
+	case err == database.ErrNoSuchRedemption:
+		reply("nosuchredemption", persona.Data{"ID": n})
+		return
+
+	case err == database.ErrAlreadyDecided:
+		reply("alreadydecided", persona.Data{"ID": n, "Status": locale.T("status." + redemption.Status)})
+		return
+
+	case b.handleError(err, ev.Channel, ev.ThreadTimestamp):
+		return
+	}
+
+	b.Config.Log.KV("redemption", n).KV("admin", admin).KV("status", redemption.Status).Info("redemption decided")
+
+	data := persona.Data{
+		"ID":     redemption.ID,
+		"User":   munge.Munge(redemption.User),
+		"Reward": redemption.Reward,
+		"Cost":   redemption.Cost,
+		"Note":   note,
+	}
+
+	decision, receipt := "redemption.deny", "redemption.denied"
+	if approve {
+		decision, receipt = "redemption.approve", "redemption.approved"
+	}
+	reply(decision, data)
+
+	user := b.userID(redemption.User)
+	if user == "" {
+		b.Config.Log.KV("user", redemption.User).Info("could not let user know about redemption")
+		return
+	}
+
+	b.DMUser(b.locale(user).Render(receipt, true, data), user, "", "")
+}
+
+// userID returns the Slack ID of a user, or an empty string if
+// there is no such user. Users that janet has not seen yet are
+// looked up in the list of all users of the workspace.
+func (b *Bot) userID(name string) string {
+	name = strings.ToLower(name)
+	if id, ok := b.userIDs.Load(name); ok {
+		return id.(string)
+	}
+
+	users, err := b.Config.Slack.GetUsers()
+	if err != nil {
+		b.Config.Log.Err(err).Error("could not look up users")
+		return ""
+	}
+
+	for _, user := range users {
+		b.rememberUser(user.Name, user.ID)
+	}
+
+	if id, ok := b.userIDs.Load(name); ok {
+		return id.(string)
+	}
+
+	return ""
+}
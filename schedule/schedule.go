@@ -0,0 +1,219 @@
+// Package schedule parses cron-like schedules and computes
+// when they are due next.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Schedule is a set of times, described by the standard five
+// cron fields: minute, hour, day of month, month and day of week.
+type Schedule struct {
+	spec string
+
+	minute, hour, dom, month, dow uint64
+
+	// as in cron, a day matches if either the day of month or the
+	// day of week matches, unless one of them is a wildcard
+	domAny, dowAny bool
+}
+
+// descriptors are shorthands for common schedules.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	months   = []string{"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// Parse parses a schedule like "0 9 * * mon" or "@weekly". Fields
+// may be wildcards (*), numbers, ranges (1-5), lists (1,15) and
+// steps (*/15, 0-30/10). Months and days of week may also be given
+// by their first three letters. Sunday is both 0 and 7.
+func Parse(spec string) (*Schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	fields := strings.Fields(strings.ToLower(spec))
+	if len(fields) == 1 {
+		expanded, ok := descriptors[fields[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown schedule %q", spec)
+		}
+		fields = strings.Fields(expanded)
+	}
+
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("schedule %q must have 5 fields: minute, hour, day of month, month and day of week", spec)
+	}
+
+	s := &Schedule{
+		spec:   spec,
+		domAny: fields[2] == "*",
+		dowAny: fields[4] == "*",
+	}
+
+	var err error
+	for _, f := range []struct {
+		bits     *uint64
+		field    string
+		min, max int
+		names    []string
+	}{
+		{&s.minute, fields[0], 0, 59, nil},
+		{&s.hour, fields[1], 0, 23, nil},
+		{&s.dom, fields[2], 1, 31, nil},
+		{&s.month, fields[3], 1, 12, months},
+		{&s.dow, fields[4], 0, 7, weekdays},
+	} {
+		*f.bits, err = parseField(f.field, f.min, f.max, f.names)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %v", spec, err)
+		}
+	}
+
+	// 7 is another name for sunday
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+
+	// e.g. "0 0 30 2 *"
+	if s.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("schedule %q never matches", spec)
+	}
+
+	return s, nil
+}
+
+// parseField parses a comma-separated list of values, ranges
+// and steps into a bit set of the matching values.
+func parseField(field string, min, max int, names []string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+			part = part[:i]
+		}
+
+		var (
+			low, high int
+			err       error
+		)
+		switch {
+		case part == "*":
+			low, high = min, max
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			low, err = parseValue(bounds[0], names)
+			if err != nil {
+				return 0, err
+			}
+			high, err = parseValue(bounds[1], names)
+			if err != nil {
+				return 0, err
+			}
+		default:
+			low, err = parseValue(part, names)
+			if err != nil {
+				return 0, err
+			}
+			high = low
+
+			// as in cron, 5/10 means 5-max/10
+			if step > 1 {
+				high = max
+			}
+		}
+
+		if low < min || high > max || low > high {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+		}
+
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func parseValue(value string, names []string) (int, error) {
+	for i, name := range names {
+		if name != "" && value == name {
+			return i, nil
+		}
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", value)
+	}
+
+	return n, nil
+}
+
+// String returns the spec that the schedule was parsed from.
+func (s *Schedule) String() string {
+	return s.spec
+}
+
+// Next returns the first time after t that matches the schedule,
+// in t's location. It returns the zero time if there is none
+// within the next five years.
+func (s *Schedule) Next(t time.Time) time.Time {
+	// start at the next full minute
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, t.Location())
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case !has(s.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case !has(s.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case !has(s.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (s *Schedule) matchesDay(t time.Time) bool {
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
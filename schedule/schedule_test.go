@@ -0,0 +1,161 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	for _, spec := range []string{
+		"* * * * *",
+		"0 9 * * mon",
+		"0 9 * * MON",
+		" 0 9 * * 1 ",
+		"*/15 9-17 * * mon-fri",
+		"0,30 9 1,15 * *",
+		"5/10 * * * *",
+		"0 0 1 jan *",
+		"0 0 29 2 *",
+		"0 9 * * 7",
+		"@weekly",
+		"@Daily",
+	} {
+		s, err := Parse(spec)
+		if err != nil {
+			t.Errorf("%q: returned error %v", spec, err)
+			continue
+		}
+
+		if s.String() != strings.TrimSpace(spec) {
+			t.Errorf("%q: String returned %q", spec, s.String())
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"0 9 * *",
+		"0 9 * * * *",
+		"@fortnightly",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"1,x * * * *",
+		"* * * * funday",
+		"* * * mon *",
+		"0 0 30 2 *",
+		"0 0 31 4,6,9,11 *",
+	} {
+		if s, err := Parse(spec); err == nil {
+			t.Errorf("%q: parsed %v; want an error", spec, s)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	cet := time.FixedZone("CET", 3600)
+
+	// 2019-01-14 is a monday
+	for _, tc := range []struct {
+		spec       string
+		from, want time.Time
+	}{
+		{
+			spec: "0 9 * * mon",
+			from: time.Date(2019, 1, 14, 8, 59, 30, 0, time.UTC),
+			want: time.Date(2019, 1, 14, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			// strictly after the passed time
+			spec: "0 9 * * mon",
+			from: time.Date(2019, 1, 14, 9, 0, 0, 0, time.UTC),
+			want: time.Date(2019, 1, 21, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "30 23 * * *",
+			from: time.Date(2019, 1, 14, 23, 45, 0, 0, time.UTC),
+			want: time.Date(2019, 1, 15, 23, 30, 0, 0, time.UTC),
+		},
+		{
+			spec: "@daily",
+			from: time.Date(2019, 1, 31, 23, 59, 0, 0, time.UTC),
+			want: time.Date(2019, 2, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "@weekly",
+			from: time.Date(2019, 1, 19, 12, 0, 0, 0, time.UTC),
+			want: time.Date(2019, 1, 20, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			// sunday is both 0 and 7
+			spec: "0 9 * * 7",
+			from: time.Date(2019, 1, 14, 10, 0, 0, 0, time.UTC),
+			want: time.Date(2019, 1, 20, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "0 9-17/4 * * mon-fri",
+			from: time.Date(2019, 1, 18, 17, 1, 0, 0, time.UTC),
+			want: time.Date(2019, 1, 21, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "0 9-17/4 * * mon-fri",
+			from: time.Date(2019, 1, 18, 10, 0, 0, 0, time.UTC),
+			want: time.Date(2019, 1, 18, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "*/15 * * * *",
+			from: time.Date(2019, 1, 14, 10, 7, 0, 0, time.UTC),
+			want: time.Date(2019, 1, 14, 10, 15, 0, 0, time.UTC),
+		},
+		{
+			// either the day of month or the day of week
+			spec: "0 9 13 * fri",
+			from: time.Date(2019, 1, 14, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2019, 1, 18, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "0 9 13 * fri",
+			from: time.Date(2019, 2, 9, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2019, 2, 13, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "@yearly",
+			from: time.Date(2019, 12, 31, 23, 59, 0, 0, time.UTC),
+			want: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			spec: "0 0 29 2 *",
+			from: time.Date(2019, 1, 14, 0, 0, 0, 0, time.UTC),
+			want: time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			// in the location of the passed time
+			spec: "0 9 * * *",
+			from: time.Date(2019, 1, 14, 8, 30, 0, 0, time.UTC).In(cet),
+			want: time.Date(2019, 1, 15, 9, 0, 0, 0, cet),
+		},
+		{
+			spec: "0 9 * * *",
+			from: time.Date(2019, 1, 14, 8, 30, 0, 0, cet),
+			want: time.Date(2019, 1, 14, 9, 0, 0, 0, cet),
+		},
+	} {
+		s, err := Parse(tc.spec)
+		if err != nil {
+			t.Errorf("%q: %v", tc.spec, err)
+			continue
+		}
+
+		if got := s.Next(tc.from); !got.Equal(tc.want) || got.Location() != tc.want.Location() {
+			t.Errorf("%q: returned %v after %v; want %v", tc.spec, got, tc.from, tc.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Package slackexport reads standard Slack workspace exports.
+package slackexport
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/nlopes/slack"
+)
+
+// ErrNotAnExport is returned when a directory does
+// not contain any of the export's channel lists.
+var ErrNotAnExport = errors.New("directory is not a slack export")
+
+// An Export is an extracted Slack export.
+type Export struct {
+	Path     string
+	Users    map[string]*slack.User
+	Channels []*Channel
+}
+
+// A Channel is a conversation in an export. Its messages
+// are stored in a directory named after the channel.
+type Channel struct {
+	ID, Name string
+}
+
+// channelLists are the files that list the exported conversations.
+// public and private channels and group DMs are stored by name,
+// DMs only have an ID.
+var channelLists = []string{"channels.json", "groups.json", "mpims.json", "dms.json"}
+
+// Open reads the users and channels of the export in path.
+func Open(path string) (*Export, error) {
+	export := &Export{
+		Path:  path,
+		Users: make(map[string]*slack.User),
+	}
+
+	var users []*slack.User
+	err := readJSON(filepath.Join(path, "users.json"), &users)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, user := range users {
+		export.Users[user.ID] = user
+	}
+
+	found := false
+	for _, list := range channelLists {
+		var channels []*Channel
+		err := readJSON(filepath.Join(path, list), &channels)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		found = true
+		for _, channel := range channels {
+			if channel.Name == "" {
+				channel.Name = channel.ID
+			}
+		}
+		export.Channels = append(export.Channels, channels...)
+	}
+
+	if !found {
+		return nil, ErrNotAnExport
+	}
+
+	return export, nil
+}
+
+// Messages returns all messages of a channel, oldest first.
+func (e *Export) Messages(channel *Channel) ([]slack.Msg, error) {
+	files, err := filepath.Glob(filepath.Join(e.Path, channel.Name, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []slack.Msg
+	for _, file := range files {
+		var day []slack.Msg
+		err := readJSON(file, &day)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, day...)
+	}
+
+	for i := range messages {
+		messages[i].Channel = channel.ID
+	}
+
+	sort.SliceStable(messages, func(i, j int) bool {
+		ti, _ := ParseTimestamp(messages[i].Timestamp)
+		tj, _ := ParseTimestamp(messages[j].Timestamp)
+		return ti.Before(tj)
+	})
+
+	return messages, nil
+}
+
+// ParseTimestamp converts a Slack message timestamp
+// such as "1546300800.000200" to a time.
+func ParseTimestamp(ts string) (time.Time, error) {
+	f, err := strconv.ParseFloat(ts, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+}
+
+func readJSON(path string, v interface{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return json.NewDecoder(f).Decode(v)
+}
@@ -0,0 +1,117 @@
+package slackexport
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeExport writes the files of an export to a temporary directory.
+func writeExport(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestOpen(t *testing.T) {
+	dir := writeExport(t, map[string]string{
+		"users.json":    `[{"id": "U100", "name": "troy"}, {"id": "U200", "name": "janet"}]`,
+		"channels.json": `[{"id": "C100", "name": "general"}]`,
+		"dms.json":      `[{"id": "D100"}]`,
+	})
+
+	export, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	if len(export.Users) != 2 || export.Users["U100"].Name != "troy" {
+		t.Errorf("Open: read users %+v; want troy and janet", export.Users)
+	}
+
+	if len(export.Channels) != 2 {
+		t.Fatalf("Open: read %d channels; want 2", len(export.Channels))
+	}
+	if c := export.Channels[0]; c.ID != "C100" || c.Name != "general" {
+		t.Errorf("Open: read channel %+v; want general", c)
+	}
+	if c := export.Channels[1]; c.ID != "D100" || c.Name != "D100" {
+		t.Errorf("Open: read DM %+v; want it to be named after its ID", c)
+	}
+}
+
+func TestOpenNotAnExport(t *testing.T) {
+	dir := writeExport(t, map[string]string{
+		"users.json": `[]`,
+	})
+
+	if _, err := Open(dir); err != ErrNotAnExport {
+		t.Errorf("Open: returned %v; want %v", err, ErrNotAnExport)
+	}
+}
+
+func TestOpenInvalidJSON(t *testing.T) {
+	dir := writeExport(t, map[string]string{
+		"channels.json": `[{"id": `,
+	})
+
+	if _, err := Open(dir); err == nil {
+		t.Errorf("Open: returned nil; want an error")
+	}
+}
+
+func TestMessages(t *testing.T) {
+	dir := writeExport(t, map[string]string{
+		"channels.json":           `[{"id": "C100", "name": "general"}]`,
+		"general/2019-01-02.json": `[{"type": "message", "user": "U100", "text": "third", "ts": "1546387200.000100"}]`,
+		"general/2019-01-01.json": `[{"type": "message", "user": "U100", "text": "second", "ts": "1546300800.000200"}, {"type": "message", "user": "U200", "text": "first", "ts": "1546300800.000100"}]`,
+	})
+
+	export, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	messages, err := export.Messages(export.Channels[0])
+	if err != nil {
+		t.Fatalf("Messages: %v", err)
+	}
+
+	var texts []string
+	for _, msg := range messages {
+		texts = append(texts, msg.Text)
+		if msg.Channel != "C100" {
+			t.Errorf("Messages: %q is in channel %q; want C100", msg.Text, msg.Channel)
+		}
+	}
+	if len(texts) != 3 || texts[0] != "first" || texts[1] != "second" || texts[2] != "third" {
+		t.Errorf("Messages: returned %v; want first, second and third", texts)
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	got, err := ParseTimestamp("1546300800.500000")
+	if err != nil {
+		t.Fatalf("ParseTimestamp: %v", err)
+	}
+
+	want := time.Date(2019, 1, 1, 0, 0, 0, 500000000, time.UTC)
+	if d := got.Sub(want); d < -time.Millisecond || d > time.Millisecond {
+		t.Errorf("ParseTimestamp: returned %v; want %v", got, want)
+	}
+
+	if _, err := ParseTimestamp("yesterday"); err == nil {
+		t.Errorf("ParseTimestamp: parsed an invalid timestamp")
+	}
+}
@@ -0,0 +1,127 @@
+package janet
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/troyxmccall/janet/database"
+	"github.com/troyxmccall/janet/munge"
+	"github.com/troyxmccall/janet/persona"
+	"github.com/troyxmccall/janet/webhook"
+
+	"github.com/nlopes/slack"
+)
+
+// give transfers points from the sender's balance to another
+// user and sends both of them a receipt.
+func (b *Bot) give(ev *slack.MessageEvent) {
+	match := regexps.Give.FindStringSubmatch(ev.Text)
+	if len(match) == 0 {
+		return
+	}
+
+	locale := b.locale(ev.User)
+	reply := func(key string, data persona.Data) {
+		b.SendMessage(locale.Render(key, true, data), ev.Channel, ev.ThreadTimestamp, "")
+	}
+
+	limits := b.Config.Transfers
+	if limits.Max < 1 {
+		reply("transfers.off", nil)
+		return
+	}
+
+	points, err := strconv.Atoi(match[2])
+	if match[1] == "" || err != nil || points < 1 {
+		reply("give.usage", nil)
+		return
+	}
+	if points > limits.Max {
+		reply("transfer.max", persona.Data{"Limit": limits.Max})
+		return
+	}
+
+	from, err := b.getUserNameByID(ev.User)
+	if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+		return
+	}
+	from, err = b.parseUser(from)
+	if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+		return
+	}
+	from = strings.ToLower(from)
+
+	to, err := b.parseUser(strings.TrimPrefix(match[1], "@"))
+	if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+		return
+	}
+	to = strings.ToLower(to)
+
+	if from == to {
+		b.SendMessage(b.message(locale, "", persona.MessageSelfPoints, persona.Data{"User": from}), ev.Channel, ev.ThreadTimestamp, "")
+		return
+	}
+
+	blacklisted, err := b.isBlacklisted(to)
+	if b.handleError(err, ev.Channel, ev.ThreadTimestamp) {
+		return
+	}
+	if blacklisted {
+		b.Config.Log.KV("user", to).Info("user is blacklisted, ignoring transfer")
+		return
+	}
+
+	record := &database.Points{
+		From:    from,
+		To:      to,
+		Points:  points,
+		Reason:  match[3],
+		Message: database.MessageID(ev.Channel, ev.Timestamp),
+	}
+
+	summary, err := b.Config.DB.Transfer(record, limits.Daily)
+	switch {
+	case err == database.ErrInsufficientPoints:
+		reply("transfer.insufficient", persona.Data{"Points": summary.Balance})
+		return
+
+	case err == database.ErrTransferLimit:
+		reply("transfer.daily", persona.Data{"Limit": limits.Daily, "Points": max(limits.Daily-summary.Given, 0)})
+		return
+
+	case b.handleError(err, ev.Channel, ev.ThreadTimestamp):
+		return
+	}
+
+	b.Config.Log.KV("from", from).KV("to", to).KV("points", points).Info("points transferred")
+
+	// the same records as the ones that Transfer inserted
+	debit := &database.Points{From: from, To: from, Points: -points, Reason: record.Reason, Type: database.TypeTransfer}
+	credit := &database.Points{From: from, To: to, Points: points, Reason: record.Reason, Type: database.TypeTransfer}
+	for _, transfer := range []*database.Points{debit, credit} {
+		err = webhook.SendKarma(b.Config.DB, webhook.EventInsert, transfer)
+		if err != nil {
+			b.Config.Log.Err(err).Error("could not queue webhook events")
+			break
+		}
+	}
+
+	data := persona.Data{
+		"From":    munge.Munge(from),
+		"To":      munge.Munge(to),
+		"Points":  points,
+		"Reason":  record.Reason,
+		"Balance": summary.Balance,
+	}
+	reply("transferred", data)
+
+	b.DMUser(locale.Render("transfer.sent", true, data), ev.User, "", "")
+
+	receiver := b.userID(to)
+	if receiver == "" {
+		b.Config.Log.KV("user", to).Info("could not let user know about transfer")
+		return
+	}
+
+	b.DMUser(b.locale(receiver).Render("transfer.received", true, data), receiver, "", "")
+}
@@ -0,0 +1,592 @@
+// Package tui implements an interactive terminal dashboard
+// for managing janet's karma.
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/troyxmccall/janet/database"
+	"github.com/troyxmccall/janet/webhook"
+
+	"github.com/jroimartin/gocui"
+)
+
+// Config contains the options of the terminal UI.
+type Config struct {
+	DB               *database.DB
+	LeaderboardLimit int
+	HistoryLimit     int
+	RefreshInterval  time.Duration
+}
+
+// TUI is a terminal dashboard with a live leaderboard and a
+// searchable history, from which users can be managed.
+type TUI struct {
+	Config *Config
+
+	leaderboard database.Leaderboard
+	history     []*database.Record
+	search      string
+	prompt      *prompt
+	status      string
+}
+
+// A prompt asks for input before running an action.
+type prompt struct {
+	title    string
+	onSubmit func(input string) error
+}
+
+// The names of the views.
+const (
+	leaderboardView = "leaderboard"
+	historyView     = "history"
+	statusView      = "status"
+	promptView      = "prompt"
+)
+
+const help = "tab: switch  /: search  esc: clear  x: reset  m: migrate  a: alias  r: revoke  ctrl-r: refresh  q: quit"
+
+// New returns a new terminal UI.
+func New(config *Config) *TUI {
+	return &TUI{
+		Config: config,
+	}
+}
+
+// Run shows the terminal UI until the user quits.
+func (t *TUI) Run() error {
+	g, err := gocui.NewGui(gocui.OutputNormal)
+	if err != nil {
+		return err
+	}
+	defer g.Close()
+
+	g.InputEsc = true
+	g.Highlight = true
+	g.SelFgColor = gocui.ColorGreen
+	g.SetManagerFunc(t.layout)
+
+	err = t.setupKeybindings(g)
+	if err != nil {
+		return err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		ticker := time.NewTicker(t.Config.RefreshInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				g.Update(t.refresh)
+			}
+		}
+	}()
+
+	g.Update(t.refresh)
+
+	err = g.MainLoop()
+	if err == gocui.ErrQuit {
+		return nil
+	}
+
+	return err
+}
+
+func (t *TUI) layout(g *gocui.Gui) error {
+	maxX, maxY := g.Size()
+	split := maxX / 3
+
+	// wait for the terminal to be resized instead of failing
+	if split < 2 || maxY < 4 {
+		return nil
+	}
+
+	if v, err := g.SetView(leaderboardView, 0, 0, split-1, maxY-2); err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+
+		v.Title = "leaderboard"
+		v.Highlight = true
+		v.SelBgColor = gocui.ColorGreen
+		v.SelFgColor = gocui.ColorBlack
+
+		_, err = g.SetCurrentView(leaderboardView)
+		if err != nil {
+			return err
+		}
+	}
+
+	if v, err := g.SetView(historyView, split, 0, maxX-1, maxY-2); err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+
+		v.Highlight = true
+		v.SelBgColor = gocui.ColorGreen
+		v.SelFgColor = gocui.ColorBlack
+	}
+
+	if v, err := g.SetView(statusView, -1, maxY-2, maxX, maxY); err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+
+		v.Frame = false
+	}
+
+	if t.prompt != nil {
+		v, err := g.SetView(promptView, maxX/6, maxY/2-1, maxX-maxX/6, maxY/2+1)
+		if err != nil {
+			if err != gocui.ErrUnknownView {
+				return err
+			}
+
+			v.Editable = true
+			g.Cursor = true
+		}
+		v.Title = t.prompt.title
+
+		_, err = g.SetCurrentView(promptView)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (t *TUI) setupKeybindings(g *gocui.Gui) error {
+	type binding struct {
+		views   []string
+		key     interface{}
+		handler func(*gocui.Gui, *gocui.View) error
+	}
+
+	lists := []string{leaderboardView, historyView}
+	bindings := []binding{
+		{[]string{""}, gocui.KeyCtrlC, quit},
+		{lists, 'q', quit},
+		{lists, gocui.KeyTab, t.switchView},
+		{lists, gocui.KeyArrowDown, t.moveCursor(1)},
+		{lists, 'j', t.moveCursor(1)},
+		{lists, gocui.KeyArrowUp, t.moveCursor(-1)},
+		{lists, 'k', t.moveCursor(-1)},
+		{lists, '/', t.startSearch},
+		{lists, gocui.KeyEsc, t.clearSearch},
+		{lists, gocui.KeyCtrlR, func(g *gocui.Gui, v *gocui.View) error { return t.refresh(g) }},
+		{[]string{leaderboardView}, 'x', t.reset},
+		{[]string{leaderboardView}, 'm', t.migrate},
+		{[]string{leaderboardView}, 'a', t.alias},
+		{[]string{historyView}, 'r', t.revoke},
+		{[]string{promptView}, gocui.KeyEnter, t.submitPrompt},
+		{[]string{promptView}, gocui.KeyEsc, t.closePrompt},
+	}
+
+	for _, b := range bindings {
+		for _, view := range b.views {
+			err := g.SetKeybinding(view, b.key, gocui.ModNone, b.handler)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func quit(g *gocui.Gui, v *gocui.View) error {
+	return gocui.ErrQuit
+}
+
+// refresh reloads the leaderboard and the history from the database.
+func (t *TUI) refresh(g *gocui.Gui) error {
+	selected := t.selectedUser(g)
+
+	leaderboard, err := t.Config.DB.GetLeaderboard(t.Config.LeaderboardLimit)
+	if err != nil {
+		t.setStatus(g, fmt.Sprintf("could not load the leaderboard: %v", err))
+		return nil
+	}
+	t.leaderboard = leaderboard
+
+	// the views do not exist while the terminal is too small
+	v, err := g.View(leaderboardView)
+	if err != nil {
+		return nil
+	}
+
+	v.Clear()
+	for i, user := range t.leaderboard {
+		fmt.Fprintf(v, "%3d. %-20s %6d\n", i+1, user.Name, user.Points)
+
+		// keep the selected user selected, even if their rank changed
+		if user.Name == selected {
+			err := setLine(v, i)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return t.refreshHistory(g)
+}
+
+// refreshHistory shows the search results, or the
+// history of the selected user.
+func (t *TUI) refreshHistory(g *gocui.Gui) error {
+	v, err := g.View(historyView)
+	if err != nil {
+		return nil
+	}
+
+	v.Title, err = t.loadHistory(t.selectedUser(g))
+	if err != nil {
+		t.setStatus(g, fmt.Sprintf("could not load the history: %v", err))
+		return nil
+	}
+
+	v.Clear()
+	for _, record := range t.history {
+		reason := ""
+		if record.Reason != "" {
+			reason = "for " + record.Reason
+		}
+
+		fmt.Fprintf(v, "%s  %-15s -> %-15s %+5d %-10s %s\n", record.Timestamp.Local().Format("2006-01-02 15:04"), record.From, record.To, record.Points.Points, record.Type, reason)
+	}
+
+	if _, cy := v.Cursor(); cy >= len(t.history) {
+		err := setLine(v, 0)
+		if err != nil {
+			return err
+		}
+	}
+
+	t.setStatus(g, t.status)
+	return nil
+}
+
+// loadHistory loads the search results, or the history of
+// user, and returns the title of the history view.
+func (t *TUI) loadHistory(user string) (string, error) {
+	var (
+		title   = "history"
+		records []*database.Record
+		err     error
+	)
+	switch {
+	case t.search != "":
+		title = fmt.Sprintf("search: %s", t.search)
+		records, err = t.Config.DB.SearchRecords(t.search, t.Config.HistoryLimit)
+	case user != "":
+		title = fmt.Sprintf("history: %s", user)
+		records, err = t.Config.DB.GetHistory(user, t.Config.HistoryLimit)
+	}
+
+	if err != nil {
+		return title, err
+	}
+	t.history = records
+
+	return title, nil
+}
+
+func (t *TUI) setStatus(g *gocui.Gui, status string) {
+	t.status = status
+
+	v, err := g.View(statusView)
+	if err != nil {
+		return
+	}
+
+	v.Clear()
+	if status != "" {
+		fmt.Fprintf(v, "%s | ", status)
+	}
+	fmt.Fprint(v, help)
+}
+
+func (t *TUI) switchView(g *gocui.Gui, v *gocui.View) error {
+	next := historyView
+	if v.Name() == historyView {
+		next = leaderboardView
+	}
+
+	_, err := g.SetCurrentView(next)
+	return err
+}
+
+func (t *TUI) moveCursor(delta int) func(*gocui.Gui, *gocui.View) error {
+	return func(g *gocui.Gui, v *gocui.View) error {
+		lines := len(t.history)
+		if v.Name() == leaderboardView {
+			lines = len(t.leaderboard)
+		}
+
+		line := currentLine(v) + delta
+		if line < 0 || line >= lines {
+			return nil
+		}
+
+		err := setLine(v, line)
+		if err != nil {
+			return err
+		}
+
+		if v.Name() == leaderboardView && t.search == "" {
+			return t.refreshHistory(g)
+		}
+
+		return nil
+	}
+}
+
+func (t *TUI) selectedUser(g *gocui.Gui) string {
+	v, err := g.View(leaderboardView)
+	if err != nil {
+		return ""
+	}
+
+	line := currentLine(v)
+	if line < 0 || line >= len(t.leaderboard) {
+		return ""
+	}
+
+	return t.leaderboard[line].Name
+}
+
+func (t *TUI) selectedRecord(g *gocui.Gui) *database.Record {
+	v, err := g.View(historyView)
+	if err != nil {
+		return nil
+	}
+
+	line := currentLine(v)
+	if line < 0 || line >= len(t.history) {
+		return nil
+	}
+
+	return t.history[line]
+}
+
+func (t *TUI) startSearch(g *gocui.Gui, v *gocui.View) error {
+	t.openPrompt("search history (user or reason)", func(query string) error {
+		t.search = query
+		return nil
+	})
+
+	return nil
+}
+
+func (t *TUI) clearSearch(g *gocui.Gui, v *gocui.View) error {
+	t.search = ""
+	return t.refreshHistory(g)
+}
+
+func (t *TUI) reset(g *gocui.Gui, v *gocui.View) error {
+	name := t.selectedUser(g)
+	if name != "" {
+		t.promptReset(name)
+	}
+
+	return nil
+}
+
+// promptReset asks whether to reset the karma of a user.
+func (t *TUI) promptReset(name string) {
+	t.openPrompt(fmt.Sprintf("reset the karma of %s? (y/n)", name), confirm(func() error {
+		op, err := t.Config.DB.PlanReset(name, "janetctl resetting karma")
+		if err != nil {
+			return err
+		}
+
+		err = t.Config.DB.Apply(op)
+		if err != nil {
+			return err
+		}
+
+		t.status = fmt.Sprintf("reset the karma of %s", name)
+		return nil
+	}))
+}
+
+func (t *TUI) migrate(g *gocui.Gui, v *gocui.View) error {
+	from := t.selectedUser(g)
+	if from != "" {
+		t.promptMigrate(from)
+	}
+
+	return nil
+}
+
+// promptMigrate asks which user to migrate the karma of a user to.
+func (t *TUI) promptMigrate(from string) {
+	t.openPrompt(fmt.Sprintf("migrate the karma of %s to", from), func(to string) error {
+		to = strings.ToLower(to)
+		if to == "" || to == from {
+			return errors.New("please enter another user")
+		}
+
+		op, err := t.Config.DB.PlanMigrate(from, to, "")
+		if err != nil {
+			return err
+		}
+
+		err = t.Config.DB.Apply(op)
+		if err != nil {
+			return err
+		}
+
+		t.status = fmt.Sprintf("migrated %d points from %s to %s", op.Records[1].Points, from, to)
+		return nil
+	})
+}
+
+func (t *TUI) alias(g *gocui.Gui, v *gocui.View) error {
+	user := t.selectedUser(g)
+	if user != "" {
+		t.promptAlias(user)
+	}
+
+	return nil
+}
+
+// promptAlias asks for a new alias of a user.
+func (t *TUI) promptAlias(user string) {
+	t.openPrompt(fmt.Sprintf("add an alias for %s", user), func(alias string) error {
+		alias = strings.ToLower(alias)
+		if alias == "" || alias == user {
+			return errors.New("please enter an alias")
+		}
+
+		err := t.Config.DB.InsertAlias(alias, user)
+		if err != nil {
+			return err
+		}
+
+		t.status = fmt.Sprintf("%s is now an alias of %s", alias, user)
+		return nil
+	})
+}
+
+func (t *TUI) revoke(g *gocui.Gui, v *gocui.View) error {
+	record := t.selectedRecord(g)
+	if record != nil {
+		t.promptRevoke(record)
+	}
+
+	return nil
+}
+
+// promptRevoke asks whether to revoke a record.
+func (t *TUI) promptRevoke(record *database.Record) {
+	title := fmt.Sprintf("revoke %+d from %s to %s? (y/n)", record.Points.Points, record.From, record.To)
+	t.openPrompt(title, confirm(func() error {
+		op, err := t.Config.DB.PlanRevoke(record)
+		if err != nil {
+			return err
+		}
+
+		err = t.Config.DB.Apply(op)
+		if err != nil {
+			return err
+		}
+
+		t.status = fmt.Sprintf("revoked %+d from %s to %s", record.Points.Points, record.From, record.To)
+
+		err = webhook.SendOperation(t.Config.DB, op)
+		if err != nil {
+			t.status += fmt.Sprintf(", but could not queue webhook events: %v", err)
+		}
+		return nil
+	}))
+}
+
+// confirm runs action if the prompt was answered with yes.
+func confirm(action func() error) func(string) error {
+	return func(answer string) error {
+		switch strings.ToLower(answer) {
+		case "y", "yes":
+			return action()
+		default:
+			return nil
+		}
+	}
+}
+
+func (t *TUI) openPrompt(title string, onSubmit func(string) error) {
+	t.prompt = &prompt{
+		title:    title,
+		onSubmit: onSubmit,
+	}
+}
+
+func (t *TUI) submitPrompt(g *gocui.Gui, v *gocui.View) error {
+	p := t.prompt
+	input := strings.TrimSpace(v.Buffer())
+
+	err := t.closePrompt(g, v)
+	if err != nil {
+		return err
+	}
+
+	err = p.onSubmit(input)
+	if err != nil {
+		t.status = fmt.Sprintf("error: %v", err)
+	}
+
+	return t.refresh(g)
+}
+
+func (t *TUI) closePrompt(g *gocui.Gui, v *gocui.View) error {
+	t.prompt = nil
+	g.Cursor = false
+
+	err := g.DeleteView(promptView)
+	if err != nil {
+		return err
+	}
+
+	_, err = g.SetCurrentView(leaderboardView)
+	return err
+}
+
+// currentLine returns the line of the buffer that the cursor is on.
+func currentLine(v *gocui.View) int {
+	_, oy := v.Origin()
+	_, cy := v.Cursor()
+
+	return oy + cy
+}
+
+// setLine moves the cursor to a line of the buffer,
+// scrolling if it is not visible.
+func setLine(v *gocui.View, line int) error {
+	_, height := v.Size()
+	_, oy := v.Origin()
+
+	switch {
+	case line < oy:
+		oy = line
+	case line >= oy+height:
+		oy = line - height + 1
+	}
+
+	err := v.SetOrigin(0, oy)
+	if err != nil {
+		return err
+	}
+
+	return v.SetCursor(0, line-oy)
+}
@@ -0,0 +1,185 @@
+package tui
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/troyxmccall/janet/database"
+
+	"github.com/jroimartin/gocui"
+)
+
+// newTUI returns a terminal UI for a new database with some karma.
+func newTUI(t *testing.T) *TUI {
+	db, err := database.New(&database.Config{Path: filepath.Join(t.TempDir(), "janet.sqlite3")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	timestamp := time.Date(2019, 1, 14, 9, 0, 0, 0, time.UTC)
+	for i, record := range []database.Points{
+		{From: "alex", To: "troy", Points: 3, Reason: "the review"},
+		{From: "bob", To: "alex", Points: 1, Reason: "lunch"},
+		{From: "troy", To: "bob", Points: 2},
+	} {
+		err := db.InsertRecord(&database.Record{Points: record, Timestamp: timestamp.Add(time.Duration(i) * time.Minute)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return New(&Config{DB: db, LeaderboardLimit: 10, HistoryLimit: 10})
+}
+
+// points returns the lifetime karma of a user.
+func points(t *testing.T, tui *TUI, name string) int {
+	user, err := tui.Config.DB.GetUser(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user.Points
+}
+
+// submit answers the open prompt.
+func submit(t *testing.T, tui *TUI, input string) error {
+	if tui.prompt == nil {
+		t.Fatalf("no prompt is open to answer %q", input)
+	}
+
+	p := tui.prompt
+	tui.prompt = nil
+
+	return p.onSubmit(input)
+}
+
+func TestLoadHistory(t *testing.T) {
+	tui := newTUI(t)
+
+	for _, tc := range []struct {
+		name, search, user, title string
+		history                   []string
+	}{
+		{
+			name:  "nothing selected",
+			title: "history",
+		},
+		{
+			name:    "selected user",
+			user:    "troy",
+			title:   "history: troy",
+			history: []string{"troy -> bob", "alex -> troy"},
+		},
+		{
+			name:    "search",
+			search:  "lunch",
+			title:   "search: lunch",
+			history: []string{"bob -> alex"},
+		},
+		{
+			name:    "search with a selected user",
+			search:  "lunch",
+			user:    "troy",
+			title:   "search: lunch",
+			history: []string{"bob -> alex"},
+		},
+		{
+			name:    "search by user",
+			search:  "ale",
+			title:   "search: ale",
+			history: []string{"bob -> alex", "alex -> troy"},
+		},
+	} {
+		tui.search = tc.search
+		title, err := tui.loadHistory(tc.user)
+		if err != nil {
+			t.Errorf("%s: returned error %v", tc.name, err)
+			continue
+		}
+
+		var history []string
+		for _, record := range tui.history {
+			history = append(history, record.From+" -> "+record.To)
+		}
+
+		if title != tc.title || !reflect.DeepEqual(history, tc.history) {
+			t.Errorf("%s: loaded %q with %v; want %q with %v", tc.name, title, history, tc.title, tc.history)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	tui := newTUI(t)
+
+	// a terminal without any views
+	g := &gocui.Gui{}
+
+	if err := tui.startSearch(g, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := submit(t, tui, "lunch"); err != nil {
+		t.Fatal(err)
+	}
+	if tui.search != "lunch" {
+		t.Errorf("searching set the search to %q; want lunch", tui.search)
+	}
+
+	if err := tui.clearSearch(g, nil); err != nil {
+		t.Fatal(err)
+	}
+	if tui.search != "" {
+		t.Errorf("clearing the search left %q", tui.search)
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	tui := newTUI(t)
+	if _, err := tui.loadHistory("troy"); err != nil {
+		t.Fatal(err)
+	}
+	record := tui.history[1]
+
+	// anything but yes cancels
+	tui.promptRevoke(record)
+	if err := submit(t, tui, "n"); err != nil {
+		t.Fatal(err)
+	}
+	if got := points(t, tui, "troy"); got != 3 {
+		t.Errorf("revoked the record without confirmation; troy has %d points", got)
+	}
+
+	tui.promptRevoke(record)
+	if err := submit(t, tui, "Y"); err != nil {
+		t.Fatalf("could not revoke: %v", err)
+	}
+	if got := points(t, tui, "troy"); got != 0 {
+		t.Errorf("troy has %d points after revoking; want 0", got)
+	}
+	if want := "revoked +3 from alex to troy"; tui.status != want {
+		t.Errorf("set status %q; want %q", tui.status, want)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	tui := newTUI(t)
+
+	tui.promptMigrate("troy")
+	if err := submit(t, tui, "troy"); err == nil {
+		t.Errorf("migrated karma to the same user")
+	}
+
+	tui.promptMigrate("troy")
+	if err := submit(t, tui, "Bob"); err != nil {
+		t.Fatalf("could not migrate: %v", err)
+	}
+
+	if troy, bob := points(t, tui, "troy"), points(t, tui, "bob"); troy != 0 || bob != 5 {
+		t.Errorf("left troy with %d and bob with %d points; want 0 and 5", troy, bob)
+	}
+	if want := "migrated 3 points from troy to bob"; tui.status != want {
+		t.Errorf("set status %q; want %q", tui.status, want)
+	}
+}
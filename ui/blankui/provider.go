@@ -1,6 +1,8 @@
 package blankui
 
 import (
+	"context"
+
 	"github.com/troyxmccall/janet/ui"
 )
 
@@ -21,6 +23,11 @@ func (p *Provider) Listen() error {
 	return nil
 }
 
+// Shutdown does nothing.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	return nil
+}
+
 // GetURL returns an empty string which
 // signifies that the UI is disabled.
 func (p *Provider) GetURL(URI string) (string, error) {
@@ -1,8 +1,11 @@
 package ui
 
+import "context"
+
 // A Provider provides a UI service that can be
 // attached to janet.
 type Provider interface {
 	GetURL(URI string) (string, error)
 	Listen() error
+	Shutdown(ctx context.Context) error
 }
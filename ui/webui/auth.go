@@ -1,25 +1,42 @@
 package webui
 
 import (
+	"context"
 	"errors"
 	"net/http"
 )
 
+// tenantKey is the context key of the tenant
+// that a request is authenticated for.
+type tenantKey struct{}
+
 // MustAuth wraps an http.HandlerFunc and ensures that the
 // user is authenticated before the said HandlerFunc is
 // executed. The user is redirected to a "session expired"
 // page if they are not authenticated.
 func (h *Handlers) MustAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		authed, err := h.ui.authenticator.Authenticate(w, r)
+		client, err := h.ui.authenticator.Authenticate(w, r)
 		if err != nil {
-			h.ui.renderError(w, err)
+			h.ui.renderError(w, r, err)
+			return
 		}
 
-		if authed {
-			next(w, r)
+		if client != nil {
+			next(w, r.WithContext(context.WithValue(r.Context(), tenantKey{}, client.Tenant)))
 		} else {
-			h.ui.renderError(w, errors.New(`your session has expired. Please type "janet web" and click on the generated url`))
+			h.ui.renderError(w, r, errors.New(h.ui.locale(r).T("web.sessionexpired")))
 		}
 	}
 }
+
+// tenant returns the tenant that a request is
+// authenticated for, or nil if it is not.
+func (u *UI) tenant(r *http.Request) *Tenant {
+	name, ok := r.Context().Value(tenantKey{}).(string)
+	if !ok {
+		return nil
+	}
+
+	return u.tenants[name]
+}
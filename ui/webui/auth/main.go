@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"fmt"
 	"net/http"
 	"sync"
 	"time"
@@ -14,8 +15,10 @@ import (
 // TOTP authentication serivce that is used
 // for the web UI.
 type Config struct {
-	Token string
-	Log   *log.Log
+	// Tokens are the TOTP keys of the tenants, keyed by
+	// tenant name. The default tenant's name is empty.
+	Tokens map[string]string
+	Log    *log.Log
 }
 
 // An Authenticator contains a list of authenticated
@@ -28,9 +31,11 @@ type Authenticator struct {
 }
 
 // A Client is an authenticated web UI session
+// of a tenant
 type Client struct {
-	UUID  string
-	Added time.Time
+	UUID   string
+	Tenant string
+	Added  time.Time
 }
 
 // New returns a new Authenticator instance and spins
@@ -45,61 +50,73 @@ func New(config *Config) *Authenticator {
 }
 
 // Authenticate logs in the client if the request contains
-// a token and checks whether the current request is
-// authenticated.
-func (a *Authenticator) Authenticate(w http.ResponseWriter, r *http.Request) (bool, error) {
+// a token and returns the session that the current request
+// is authenticated with, or nil if it is not authenticated.
+// A token for another tenant replaces the session.
+func (a *Authenticator) Authenticate(w http.ResponseWriter, r *http.Request) (*Client, error) {
 	cookie, err := r.Cookie("session")
 
 	if err != nil {
 		if err != http.ErrNoCookie {
 			a.Config.Log.Err(err).Error("could not authenticate user")
 
-			return false, err
+			return nil, err
 		}
 
 		err = nil
 	}
 
-	authed := false
+	var authed *Client
 	if cookie != nil {
 		a.clientsMutex.RLock()
 		for _, client := range a.authedClients {
 			if cookie.Value == client.UUID {
-				authed = true
+				authed = client
 				break
 			}
 		}
 		a.clientsMutex.RUnlock()
 	}
 
-	if !authed && a.hasValidToken(r) {
+	tenant, ok := a.validToken(r)
+	if ok && (authed == nil || authed.Tenant != tenant) {
 		cookie = &http.Cookie{
 			Name:  "session",
 			Value: uuid.Must(uuid.NewV4(), err).String(),
 			Path:  "/",
 		}
 
+		authed = &Client{
+			UUID:   cookie.Value,
+			Tenant: tenant,
+			Added:  time.Now(),
+		}
+
 		a.clientsMutex.Lock()
-		a.authedClients = append(a.authedClients, &Client{
-			UUID:  cookie.Value,
-			Added: time.Now(),
-		})
+		a.authedClients = append(a.authedClients, authed)
 		a.clientsMutex.Unlock()
 
-		authed = true
 		http.SetCookie(w, cookie)
 	}
 
 	return authed, nil
 }
 
-func (a *Authenticator) hasValidToken(r *http.Request) bool {
+// validToken returns the tenant that the request
+// has a valid token for.
+func (a *Authenticator) validToken(r *http.Request) (string, bool) {
 	token := r.URL.Query().Get("token")
 	if token == "" {
-		return false
+		return "", false
+	}
+
+	tenant := r.URL.Query().Get("tenant")
+	key, ok := a.Config.Tokens[tenant]
+	if !ok {
+		return "", false
 	}
 
-	return totp.Validate(token, a.Config.Token)
+	return tenant, totp.Validate(token, key)
 }
 
 // ExpireClients expires all sessions that have been logged
@@ -120,8 +137,13 @@ func (a *Authenticator) ExpireClients() {
 	}
 }
 
-// GetToken generates a TOTP token that is
-// valid for 30 seconds.
-func (a *Authenticator) GetToken() (string, error) {
-	return totp.GenerateCode(a.Config.Token, time.Now())
+// GetToken generates a TOTP token for a tenant
+// that is valid for 30 seconds.
+func (a *Authenticator) GetToken(tenant string) (string, error) {
+	key, ok := a.Config.Tokens[tenant]
+	if !ok {
+		return "", fmt.Errorf("unknown tenant %q", tenant)
+	}
+
+	return totp.GenerateCode(key, time.Now())
 }
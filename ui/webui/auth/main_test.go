@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/aybabtme/log"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	defaultKey = "JBSWY3DPEHPK3PXP"
+	acmeKey    = "KRSXG5CTMVRXEZLU"
+)
+
+func newAuthenticator() *Authenticator {
+	return &Authenticator{
+		Config: &Config{
+			Tokens: map[string]string{
+				"":     defaultKey,
+				"acme": acmeKey,
+			},
+			Log: log.KV("test", true),
+		},
+	}
+}
+
+func token(t *testing.T, key string) string {
+	code, err := totp.GenerateCode(key, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return code
+}
+
+// request returns a request for the web UI with the
+// given query and session cookie.
+func request(query url.Values, session *http.Cookie) *http.Request {
+	r := httptest.NewRequest("GET", "/?"+query.Encode(), nil)
+	if session != nil {
+		r.AddCookie(session)
+	}
+
+	return r
+}
+
+// session returns the session cookie set by a response.
+func session(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "session" {
+			return cookie
+		}
+	}
+
+	return nil
+}
+
+func TestValidToken(t *testing.T) {
+	a := newAuthenticator()
+
+	for _, tc := range []struct {
+		name   string
+		query  url.Values
+		tenant string
+		ok     bool
+	}{
+		{
+			name:  "default tenant",
+			query: url.Values{"token": {token(t, defaultKey)}},
+			ok:    true,
+		},
+		{
+			name:   "named tenant",
+			query:  url.Values{"token": {token(t, acmeKey)}, "tenant": {"acme"}},
+			tenant: "acme",
+			ok:     true,
+		},
+		{
+			name:  "token of another tenant",
+			query: url.Values{"token": {token(t, defaultKey)}, "tenant": {"acme"}},
+		},
+		{
+			name:  "token of a named tenant for the default tenant",
+			query: url.Values{"token": {token(t, acmeKey)}},
+		},
+		{
+			name:  "unknown tenant",
+			query: url.Values{"token": {token(t, acmeKey)}, "tenant": {"globex"}},
+		},
+		{
+			name:  "invalid token",
+			query: url.Values{"token": {"000000x"}, "tenant": {"acme"}},
+		},
+		{
+			name:  "no token",
+			query: url.Values{"tenant": {"acme"}},
+		},
+	} {
+		tenant, ok := a.validToken(request(tc.query, nil))
+		if ok != tc.ok || (ok && tenant != tc.tenant) {
+			t.Errorf("%s: returned %q, %v; want %q, %v", tc.name, tenant, ok, tc.tenant, tc.ok)
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	a := newAuthenticator()
+
+	// no token and no session
+	w := httptest.NewRecorder()
+	client, err := a.Authenticate(w, request(nil, nil))
+	if err != nil || client != nil {
+		t.Fatalf("Authenticate: returned %+v, %v without a token; want no session", client, err)
+	}
+
+	// a token for another tenant does not log in
+	w = httptest.NewRecorder()
+	client, err = a.Authenticate(w, request(url.Values{"token": {token(t, defaultKey)}, "tenant": {"acme"}}, nil))
+	if err != nil || client != nil || session(w) != nil {
+		t.Fatalf("Authenticate: returned %+v, %v for the token of another tenant; want no session", client, err)
+	}
+
+	// logging in to acme
+	w = httptest.NewRecorder()
+	client, err = a.Authenticate(w, request(url.Values{"token": {token(t, acmeKey)}, "tenant": {"acme"}}, nil))
+	if err != nil || client == nil || client.Tenant != "acme" {
+		t.Fatalf("Authenticate: returned %+v, %v; want a session for acme", client, err)
+	}
+	acme := session(w)
+	if acme == nil || acme.Value != client.UUID {
+		t.Fatalf("Authenticate: set cookie %+v; want the session of %+v", acme, client)
+	}
+
+	// the session authenticates later requests
+	w = httptest.NewRecorder()
+	client, err = a.Authenticate(w, request(nil, acme))
+	if err != nil || client == nil || client.Tenant != "acme" || client.UUID != acme.Value {
+		t.Errorf("Authenticate: returned %+v, %v for the acme session; want the acme session", client, err)
+	}
+	if session(w) != nil {
+		t.Errorf("Authenticate: replaced a valid session")
+	}
+
+	// a token for another tenant does not switch tenants
+	w = httptest.NewRecorder()
+	client, err = a.Authenticate(w, request(url.Values{"token": {token(t, defaultKey)}, "tenant": {"acme"}}, acme))
+	if err != nil || client == nil || client.Tenant != "acme" || session(w) != nil {
+		t.Errorf("Authenticate: returned %+v, %v for the token of another tenant; want the acme session", client, err)
+	}
+
+	// switching to the default tenant replaces the session
+	w = httptest.NewRecorder()
+	client, err = a.Authenticate(w, request(url.Values{"token": {token(t, defaultKey)}}, acme))
+	if err != nil || client == nil || client.Tenant != "" {
+		t.Fatalf("Authenticate: returned %+v, %v when switching tenants; want a session for the default tenant", client, err)
+	}
+	switched := session(w)
+	if switched == nil || switched.Value == acme.Value || switched.Value != client.UUID {
+		t.Fatalf("Authenticate: set cookie %+v when switching tenants; want a new session", switched)
+	}
+
+	w = httptest.NewRecorder()
+	client, err = a.Authenticate(w, request(nil, switched))
+	if err != nil || client == nil || client.Tenant != "" {
+		t.Errorf("Authenticate: returned %+v, %v for the new session; want the default tenant", client, err)
+	}
+}
+
+func TestAuthenticateUnknownSession(t *testing.T) {
+	a := newAuthenticator()
+
+	w := httptest.NewRecorder()
+	client, err := a.Authenticate(w, request(nil, &http.Cookie{Name: "session", Value: "forged"}))
+	if err != nil || client != nil {
+		t.Errorf("Authenticate: returned %+v, %v for an unknown session; want no session", client, err)
+	}
+}
+
+func TestGetToken(t *testing.T) {
+	a := newAuthenticator()
+
+	code, err := a.GetToken("acme")
+	if err != nil {
+		t.Fatalf("GetToken: %v", err)
+	}
+	if !totp.Validate(code, acmeKey) {
+		t.Errorf("GetToken: returned %q, which is not valid for acme", code)
+	}
+
+	if _, err := a.GetToken("globex"); err == nil {
+		t.Errorf("GetToken: returned a token for an unknown tenant")
+	}
+}
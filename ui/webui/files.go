@@ -0,0 +1,45 @@
+package webui
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/troyxmccall/janet/www"
+)
+
+// overlayFS serves files from an on-disk override directory
+// and falls back to the embedded files for anything that
+// does not exist there. Other errors, such as unreadable
+// overrides, are returned instead of being hidden.
+type overlayFS struct {
+	override, fallback fs.FS
+}
+
+func (o *overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.override.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.fallback.Open(name)
+	}
+
+	return f, err
+}
+
+// filesystem returns the files of the passed www subdirectory,
+// preferring the files in Config.FilesPath if it is set.
+func (u *UI) filesystem(dir string) (fs.FS, error) {
+	embedded, err := fs.Sub(www.FS, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Config.FilesPath == "" {
+		return embedded, nil
+	}
+
+	return &overlayFS{
+		override: os.DirFS(path.Join(u.Config.FilesPath, dir)),
+		fallback: embedded,
+	}, nil
+}
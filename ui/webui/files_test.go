@@ -0,0 +1,74 @@
+package webui
+
+import (
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/troyxmccall/janet/www"
+)
+
+func TestFilesystem(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "assets", "stylesheets"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "assets", "stylesheets", "main.css"), []byte("body {}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	u := &UI{Config: &Config{FilesPath: dir}}
+	assets, err := u.filesystem("assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the override wins over the embedded file
+	css, err := fs.ReadFile(assets, "stylesheets/main.css")
+	if err != nil {
+		t.Fatalf("could not read the overridden file: %v", err)
+	}
+	if string(css) != "body {}" {
+		t.Errorf("read %q; want the override", css)
+	}
+
+	// files that are not overridden are embedded
+	js, err := fs.ReadFile(assets, "javascripts/main.min.js")
+	if err != nil {
+		t.Fatalf("could not read the embedded file: %v", err)
+	}
+	embedded, err := fs.ReadFile(www.FS, "assets/javascripts/main.min.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(js) != string(embedded) {
+		t.Errorf("did not fall back to the embedded file")
+	}
+
+	if _, err := assets.Open("javascripts/missing.js"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("returned %v for a missing file; want fs.ErrNotExist", err)
+	}
+}
+
+// failingFS fails to open any file.
+type failingFS struct{}
+
+func (failingFS) Open(name string) (fs.File, error) {
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+}
+
+func TestOverlayFSErrors(t *testing.T) {
+	o := &overlayFS{
+		override: failingFS{},
+		fallback: fstest.MapFS{"main.css": {Data: []byte("body {}")}},
+	}
+
+	// an unreadable override is not replaced by the embedded file
+	if _, err := o.Open("main.css"); !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("returned %v; want fs.ErrPermission", err)
+	}
+}
@@ -1,10 +1,10 @@
 package webui
 
 import (
-	"fmt"
 	"net/http"
 	"strconv"
 
+	"github.com/troyxmccall/janet/achievement"
 	"github.com/troyxmccall/janet/database"
 
 	"github.com/gorilla/mux"
@@ -28,54 +28,135 @@ func (h *Handlers) Leaderboard(w http.ResponseWriter, r *http.Request) {
 		err   error
 	)
 
+	tenant := h.ui.tenant(r)
 	limitS := mux.Vars(r)["limit"]
 
 	if limitS == "" {
-		limit = h.ui.Config.LeaderboardLimit
+		limit = tenant.LeaderboardLimit
 	} else {
 		limit, err = strconv.Atoi(limitS)
 
 		if err != nil {
-			h.ui.renderError(w, err)
+			h.ui.renderError(w, r, err)
 			return
 		}
 	}
 
-	points, err := h.ui.Config.DB.GetTotalPoints()
+	points, err := tenant.DB.GetTotalPoints()
 
-	leaderboard, err := h.ui.Config.DB.GetLeaderboard(limit)
+	leaderboard, err := tenant.DB.GetLeaderboard(limit)
 	if err != nil {
 		h.ui.Config.Log.Err(err).KV("limit", limit).Error("could not generate leaderboard")
 
-		h.ui.renderError(w, err)
+		h.ui.renderError(w, r, err)
 		return
 	}
 
+	entries := make([]*leaderboardEntry, 0, len(leaderboard))
+	for _, user := range leaderboard {
+		badges, err := badges(tenant.DB, user.Name)
+		if err != nil {
+			h.ui.Config.Log.Err(err).KV("user", user.Name).Error("could not look up badges")
+
+			h.ui.renderError(w, r, err)
+			return
+		}
+
+		entries = append(entries, &leaderboardEntry{User: user, Badges: badges})
+	}
+
 	data := &templateData{
 		Config: &templateConfig{
-			LeaderboardLimit: h.ui.Config.LeaderboardLimit,
+			LeaderboardLimit: tenant.LeaderboardLimit,
 		},
 		Data: &struct {
 			Limit, TotalPoints int
-			Leaderboard        database.Leaderboard
+			Leaderboard        []*leaderboardEntry
 		}{
 			Limit:       limit,
 			TotalPoints: points,
-			Leaderboard: leaderboard,
+			Leaderboard: entries,
+		},
+	}
+
+	h.ui.renderTemplate(w, r, "leaderboard.html", data)
+}
+
+// A leaderboardEntry is a user of the leaderboard with their badges.
+type leaderboardEntry struct {
+	*database.User
+	Badges []*achievement.Badge
+}
+
+// badges returns the badges that a user earned, oldest first.
+func badges(db *database.DB, user string) ([]*achievement.Badge, error) {
+	achievements, err := db.GetAchievements(user)
+	if err != nil {
+		return nil, err
+	}
+
+	var badges []*achievement.Badge
+	for _, a := range achievements {
+		badge, err := achievement.ParseBadge(a.Badge)
+		if err != nil {
+			continue
+		}
+
+		badges = append(badges, badge)
+	}
+
+	return badges, nil
+}
+
+// redemptionsLimit is the amount of redemptions
+// shown on the rewards view.
+const redemptionsLimit = 100
+
+// Rewards serves the rewards view, with the catalog
+// and the latest redemptions.
+func (h *Handlers) Rewards(w http.ResponseWriter, r *http.Request) {
+	tenant := h.ui.tenant(r)
+
+	rewards, err := tenant.DB.GetRewards()
+	if err != nil {
+		h.ui.Config.Log.Err(err).Error("could not look up rewards")
+
+		h.ui.renderError(w, r, err)
+		return
+	}
+
+	redemptions, err := tenant.DB.GetRedemptions("", redemptionsLimit)
+	if err != nil {
+		h.ui.Config.Log.Err(err).Error("could not look up redemptions")
+
+		h.ui.renderError(w, r, err)
+		return
+	}
+
+	data := &templateData{
+		Config: &templateConfig{
+			LeaderboardLimit: tenant.LeaderboardLimit,
+		},
+		Data: &struct {
+			Rewards     []*database.Reward
+			Redemptions []*database.Redemption
+		}{
+			Rewards:     rewards,
+			Redemptions: redemptions,
 		},
 	}
 
-	h.ui.renderTemplate(w, "leaderboard.html", data)
+	h.ui.renderTemplate(w, r, "rewards.html", data)
 }
 
 // NotFound handles invalid URIs that do not
 // have a matching route.
 func (h *Handlers) NotFound(w http.ResponseWriter, r *http.Request) {
-	h.ui.renderTemplate(w, "error.html", &templateData{
+	h.ui.renderTemplate(w, r, "error.html", &templateData{
 		Data: &struct {
 			Error string
 		}{
-			Error: fmt.Sprintf("page [%s] not found", r.RequestURI),
+			Error: h.ui.locale(r).T("web.notfound", "URI", r.RequestURI),
 		},
 	})
 }
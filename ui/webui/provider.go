@@ -1,9 +1,14 @@
 package webui
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/url"
 
 	"github.com/troyxmccall/janet/database"
+	"github.com/troyxmccall/janet/health"
+	"github.com/troyxmccall/janet/i18n"
 	"github.com/troyxmccall/janet/ui"
 
 	"github.com/aybabtme/log"
@@ -16,8 +21,29 @@ type Config struct {
 	ListenAddr, URL, TOTP, FilesPath string
 	LeaderboardLimit                 int
 	Log                              *log.Log
-	Debug                            bool
+	Debug, Metrics                   bool
+	Health                           *health.Checker
 	DB                               *database.DB
+
+	// Locale is used for browsers that do not ask
+	// for a language that janet speaks
+	Locale *i18n.Locale
+
+	// Tenants are the workspaces that are served in addition
+	// to the default one, keyed by name. TOTP, DB,
+	// LeaderboardLimit and Locale belong to the default
+	// workspace, which is left out if TOTP is empty.
+	Tenants map[string]*Tenant
+}
+
+// A Tenant is a workspace with its own TOTP key and database.
+// Viewers only see the leaderboard of the tenant whose token
+// they logged in with.
+type Tenant struct {
+	TOTP             string
+	DB               *database.DB
+	LeaderboardLimit int
+	Locale           *i18n.Locale
 }
 
 // A Provider provides a UI service that can be
@@ -30,51 +56,118 @@ type Provider struct {
 // ensure that Provider implements the ui.Provider interface
 var _ ui.Provider = new(Provider)
 
+// ErrNoTOTP is returned by New when no TOTP key is
+// passed. A freshly generated key is logged instead.
+var ErrNoTOTP = errors.New("no totp key passed")
+
 // New returns a new instance the web UI provider.
-// It also generates a TOTP token and quits if one is not
-// passed.
+// It also generates a TOTP token and returns ErrNoTOTP
+// if one is not passed.
 func New(config *Config) (*Provider, error) {
+	if config.Locale == nil {
+		config.Locale = i18n.Default()
+	}
+
 	if config.URL == "" {
 		config.URL = fmt.Sprintf("http://%s", config.ListenAddr)
 	}
 
-	if config.TOTP == "" {
+	for name, tenant := range config.Tenants {
+		if tenant.TOTP == "" {
+			return nil, fmt.Errorf("tenant %s has no totp key", name)
+		}
+		if tenant.LeaderboardLimit == 0 {
+			tenant.LeaderboardLimit = config.LeaderboardLimit
+		}
+		if tenant.Locale == nil {
+			tenant.Locale = config.Locale
+		}
+	}
+
+	if config.TOTP == "" && len(config.Tenants) == 0 {
 		key, err := totp.Generate(totp.GenerateOpts{
 			Issuer:      "janet",
 			AccountName: "slack",
 		})
 
 		if err != nil {
-			config.Log.Err(err).Fatal("an error occurred while generating a TOTP key")
-		} else {
-			config.Log.KV("totpKey", key.Secret()).Fatal("please use the following TOTP key")
+			config.Log.Err(err).Error("an error occurred while generating a TOTP key")
+			return nil, err
 		}
+
+		config.Log.KV("totpKey", key.Secret()).Error("please use the following TOTP key")
+		return nil, ErrNoTOTP
+	}
+
+	ui, err := newUI(config)
+	if err != nil {
+		return nil, err
 	}
 
 	provider := &Provider{
 		Config: config,
-		ui:     newUI(config),
+		ui:     ui,
 	}
 
 	return provider, nil
 }
 
-// Listen starts the HTTP server.
+// Listen starts the HTTP server and blocks until
+// it is shut down.
 func (p *Provider) Listen() error {
 	p.Config.Log.Info("webui listening")
-	p.ui.Listen()
 
-	return nil
+	return p.ui.Listen()
+}
+
+// Shutdown gracefully stops the HTTP server.
+func (p *Provider) Shutdown(ctx context.Context) error {
+	return p.ui.Shutdown(ctx)
 }
 
 // GetURL returns the passed URI as a full URL
 // with an authentication token that is valid
 // for 30 seconds.
 func (p *Provider) GetURL(URI string) (string, error) {
-	token, err := p.ui.authenticator.GetToken()
+	return p.tenantURL("", URI)
+}
+
+func (p *Provider) tenantURL(tenant, URI string) (string, error) {
+	token, err := p.ui.authenticator.GetToken(tenant)
 	if err != nil {
 		return "", err
 	}
 
-	return fmt.Sprintf("%s%s?token=%s", p.Config.URL, URI, token), nil
+	if tenant == "" {
+		return fmt.Sprintf("%s%s?token=%s", p.Config.URL, URI, token), nil
+	}
+
+	return fmt.Sprintf("%s%s?tenant=%s&token=%s", p.Config.URL, URI, url.QueryEscape(tenant), token), nil
+}
+
+// Tenant returns the provider of a tenant, whose URLs log viewers
+// in to the tenant. It shares the web UI's server, so its Listen
+// and Shutdown do nothing.
+func (p *Provider) Tenant(name string) ui.Provider {
+	return &tenantProvider{
+		provider: p,
+		name:     name,
+	}
+}
+
+type tenantProvider struct {
+	provider *Provider
+	name     string
+}
+
+func (t *tenantProvider) GetURL(URI string) (string, error) {
+	return t.provider.tenantURL(t.name, URI)
+}
+
+func (t *tenantProvider) Listen() error {
+	return nil
+}
+
+func (t *tenantProvider) Shutdown(ctx context.Context) error {
+	return nil
 }
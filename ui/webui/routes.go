@@ -2,10 +2,11 @@ package webui
 
 import (
 	"net/http"
-	"path"
+
+	"github.com/troyxmccall/janet/metrics"
 )
 
-func (u *UI) setupRoutes() {
+func (u *UI) setupRoutes() error {
 	u.handlers = &Handlers{
 		ui: u,
 	}
@@ -16,15 +17,32 @@ func (u *UI) setupRoutes() {
 	)
 
 	// assets
-	assetsPath := path.Join(u.Config.FilesPath, "assets")
-	assetsHandler := http.StripPrefix("/assets/", http.FileServer(http.Dir(assetsPath)))
+	assets, err := u.filesystem("assets")
+	if err != nil {
+		return err
+	}
+	assetsHandler := http.StripPrefix("/assets/", http.FileServer(http.FS(assets)))
 	r.PathPrefix("/assets/").Handler(assetsHandler)
 
 	// routes
 	r.HandleFunc("/", h.MustAuth(h.Home)).Methods("GET")
 	r.HandleFunc("/leaderboard", h.MustAuth(h.Leaderboard)).Methods("GET")
 	r.HandleFunc(`/leaderboard/{limit:\d+}`, h.MustAuth(h.Leaderboard)).Methods("GET")
+	r.HandleFunc("/rewards", h.MustAuth(h.Rewards)).Methods("GET")
+
+	// prometheus
+	if u.Config.Metrics {
+		r.Handle("/metrics", metrics.Handler()).Methods("GET")
+	}
+
+	// health checks
+	if u.Config.Health != nil {
+		r.Handle("/healthz", u.Config.Health.LiveHandler()).Methods("GET")
+		r.Handle("/readyz", u.Config.Health.ReadyHandler()).Methods("GET")
+	}
 
 	// custom handlers
 	r.NotFoundHandler = http.HandlerFunc(h.NotFound)
+
+	return nil
 }
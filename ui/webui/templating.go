@@ -2,11 +2,15 @@ package webui
 
 import (
 	"html/template"
+	"io/fs"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+
+	"github.com/troyxmccall/janet/i18n"
+	"github.com/troyxmccall/janet/www"
 )
 
 type templateConfig struct {
@@ -15,16 +19,67 @@ type templateConfig struct {
 
 type templateData struct {
 	Config *templateConfig
-	Data   interface{}
+	Locale *i18n.Locale
+
+	// Tenant is the name of the workspace that is shown,
+	// which is empty for the default workspace
+	Tenant string
+
+	Data interface{}
+}
+
+func (u *UI) setupTemplates() error {
+	templates, err := u.parseTemplates()
+	if err != nil {
+		u.Config.Log.Err(err).Error("could not parse all templates properly")
+		return err
+	}
+
+	u.templates = templates
+	return nil
+}
+
+// reloadTemplates re-parses the templates so that changes are
+// picked up without a restart. It is used in debug mode and keeps
+// the previous templates if parsing fails.
+func (u *UI) reloadTemplates() {
+	templates, err := u.parseTemplates()
+	if err != nil {
+		u.Config.Log.Err(err).Error("could not reload templates")
+		return
+	}
+
+	u.templatesMutex.Lock()
+	u.templates = templates
+	u.templatesMutex.Unlock()
 }
 
-func (u *UI) setupTemplates() {
-	u.templates = template.New("")
+// parseTemplates parses the embedded templates and then any
+// templates in the override directory, which replace the embedded
+// ones with the same name.
+func (u *UI) parseTemplates() (*template.Template, error) {
+	embedded, err := fs.Sub(www.FS, "templates")
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := template.New("").ParseFS(embedded, "*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Config.FilesPath == "" {
+		return templates, nil
+	}
 
 	templatesPath := path.Join(u.Config.FilesPath, "templates")
-	err := filepath.Walk(templatesPath, func(path string, info os.FileInfo, err error) error {
+	if _, err := os.Stat(templatesPath); os.IsNotExist(err) {
+		return templates, nil
+	}
+
+	err = filepath.Walk(templatesPath, func(path string, info os.FileInfo, err error) error {
 		if strings.HasSuffix(path, ".html") {
-			_, err = u.templates.ParseFiles(path)
+			_, err = templates.ParseFiles(path)
 
 			if err != nil {
 				u.Config.Log.Err(err).KV("template", path).Error("could not parse template")
@@ -35,12 +90,36 @@ func (u *UI) setupTemplates() {
 	})
 
 	if err != nil {
-		u.Config.Log.Err(err).Fatal("could not parse all templates properly. exiting.")
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+// locale returns the locale that the browser asks for, or
+// the locale of the viewer's workspace.
+func (u *UI) locale(r *http.Request) *i18n.Locale {
+	fallback := u.Config.Locale
+	if tenant := u.tenant(r); tenant != nil {
+		fallback = tenant.Locale
 	}
+
+	return i18n.AcceptLanguage(r.Header.Get("Accept-Language"), fallback)
 }
 
-func (u *UI) renderTemplate(w http.ResponseWriter, tmpl string, data *templateData) {
-	err := u.templates.ExecuteTemplate(w, tmpl, data)
+func (u *UI) renderTemplate(w http.ResponseWriter, r *http.Request, tmpl string, data *templateData) {
+	data.Locale = u.locale(r)
+	data.Tenant, _ = r.Context().Value(tenantKey{}).(string)
+
+	if u.Config.Debug {
+		u.reloadTemplates()
+	}
+
+	u.templatesMutex.RLock()
+	templates := u.templates
+	u.templatesMutex.RUnlock()
+
+	err := templates.ExecuteTemplate(w, tmpl, data)
 
 	if err != nil {
 		u.Config.Log.Err(err).KV("template", tmpl).Error("could not render template")
@@ -49,8 +128,8 @@ func (u *UI) renderTemplate(w http.ResponseWriter, tmpl string, data *templateDa
 	}
 }
 
-func (u *UI) renderError(w http.ResponseWriter, err error) {
-	u.renderTemplate(w, "error.html", &templateData{
+func (u *UI) renderError(w http.ResponseWriter, r *http.Request, err error) {
+	u.renderTemplate(w, r, "error.html", &templateData{
 		Config: nil,
 		Data: &struct {
 			Error string
@@ -1,8 +1,10 @@
 package webui
 
 import (
+	"context"
 	"html/template"
 	"net/http"
+	"sync"
 
 	"github.com/gorilla/mux"
 	"github.com/troyxmccall/janet/ui/webui/auth"
@@ -13,39 +15,87 @@ import (
 type UI struct {
 	Config *Config
 
-	handlers      *Handlers
-	router        *mux.Router
-	templates     *template.Template
-	authenticator *auth.Authenticator
+	handlers       *Handlers
+	router         *mux.Router
+	server         *http.Server
+	templates      *template.Template
+	templatesMutex sync.RWMutex
+	authenticator  *auth.Authenticator
+
+	// tenants are the served workspaces by name,
+	// the default one having an empty name
+	tenants map[string]*Tenant
 }
 
-func newUI(config *Config) *UI {
+func newUI(config *Config) (*UI, error) {
+	tenants := make(map[string]*Tenant, len(config.Tenants)+1)
+	for name, tenant := range config.Tenants {
+		tenants[name] = tenant
+	}
+	if config.TOTP != "" {
+		tenants[""] = &Tenant{
+			TOTP:             config.TOTP,
+			DB:               config.DB,
+			LeaderboardLimit: config.LeaderboardLimit,
+			Locale:           config.Locale,
+		}
+	}
+
+	tokens := make(map[string]string, len(tenants))
+	for name, tenant := range tenants {
+		tokens[name] = tenant.TOTP
+	}
+
 	ui := &UI{
-		Config: config,
-		router: mux.NewRouter(),
+		Config:  config,
+		router:  mux.NewRouter(),
+		tenants: tenants,
 		authenticator: auth.New(&auth.Config{
-			Token: config.TOTP,
-			Log:   config.Log.KV("service", "auth"),
+			Tokens: tokens,
+			Log:    config.Log.KV("service", "auth"),
 		}),
 	}
 
-	ui.Init()
-	return ui
+	ui.server = &http.Server{
+		Addr:    config.ListenAddr,
+		Handler: ui.router,
+	}
+
+	err := ui.Init()
+	if err != nil {
+		return nil, err
+	}
+
+	return ui, nil
 }
 
 // Init initializes the web UI by parsing the HTML
 // templates and setting up the HTTP routes.
-func (u *UI) Init() {
-	u.setupTemplates()
-	u.setupRoutes()
+func (u *UI) Init() error {
+	err := u.setupTemplates()
+	if err != nil {
+		return err
+	}
+
+	return u.setupRoutes()
 }
 
-// Listen starts the actual HTTP server.
-func (u *UI) Listen() {
+// Listen starts the actual HTTP server. It returns
+// nil once the server has been shut down.
+func (u *UI) Listen() error {
 	u.Config.Log.KV("address", u.Config.ListenAddr).Info("starting http server")
-	err := http.ListenAndServe(u.Config.ListenAddr, u.router)
+	err := u.server.ListenAndServe()
 
-	if err != nil {
-		u.Config.Log.Err(err).Fatal("could not start http server")
+	if err == http.ErrServerClosed {
+		return nil
 	}
+
+	return err
+}
+
+// Shutdown gracefully stops the HTTP server, waiting
+// for active requests until the context expires.
+func (u *UI) Shutdown(ctx context.Context) error {
+	u.Config.Log.Info("stopping http server")
+	return u.server.Shutdown(ctx)
 }
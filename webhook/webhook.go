@@ -0,0 +1,304 @@
+// Package webhook posts karma events to the webhooks of other tools,
+// such as dashboards or kudos systems. Events are queued in the
+// database, so that deliveries survive restarts and are retried
+// with backoff until the receiver accepts them.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/troyxmccall/janet/database"
+
+	"github.com/aybabtme/log"
+)
+
+// The events that webhooks can subscribe to.
+const (
+	EventInsert    = "karma.insert"
+	EventRevoke    = "karma.revoke"
+	EventMilestone = "karma.milestone"
+)
+
+// Events lists all events.
+var Events = []string{EventInsert, EventRevoke, EventMilestone}
+
+// The headers of a delivery. The signature is the hex-encoded
+// HMAC-SHA256 of the body, keyed with the webhook's secret
+// and prefixed with "sha256=".
+const (
+	HeaderEvent     = "X-Janet-Event"
+	HeaderDelivery  = "X-Janet-Delivery"
+	HeaderSignature = "X-Janet-Signature"
+)
+
+// A Payload is the JSON body that is posted to a webhook.
+type Payload struct {
+	Event     string      `json:"event"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// Karma is the data of insert and revoke events.
+type Karma struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Points int    `json:"points"`
+	Reason string `json:"reason"`
+
+	// Total is the receiver's karma after the operation.
+	Total int `json:"total"`
+
+	// Type is the type of the record, e.g. `transfer`. It is
+	// left out for karma that users gave or took.
+	Type string `json:"type,omitempty"`
+}
+
+// Milestone is the data of milestone events, which are
+// sent when a user earns a badge.
+type Milestone struct {
+	User string `json:"user"`
+
+	// Badge identifies the badge, e.g. `total:100`, and
+	// Name describes it in English, e.g. `100 points`.
+	Badge string `json:"badge"`
+	Name  string `json:"name"`
+
+	Total int `json:"total"`
+}
+
+// A Queue stores events until they are delivered.
+type Queue interface {
+	// QueueEvent queues a delivery of an event's payload to
+	// each webhook that is subscribed to the event.
+	QueueEvent(event string, payload []byte) error
+}
+
+// Send queues an event with its data.
+func Send(q Queue, event string, data interface{}) error {
+	payload, err := json.Marshal(&Payload{
+		Event:     event,
+		Timestamp: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	return q.QueueEvent(event, payload)
+}
+
+// A DB stores events and looks up karma for their data.
+type DB interface {
+	Queue
+	GetUser(name string) (*database.User, error)
+}
+
+// SendKarma queues an insert or revoke event
+// for a karma operation that was recorded.
+func SendKarma(db DB, event string, record *database.Points) error {
+	user, err := db.GetUser(record.To)
+	if err != nil {
+		return err
+	}
+
+	return Send(db, event, &Karma{
+		From:   record.From,
+		To:     record.To,
+		Points: record.Points,
+		Reason: record.Reason,
+		Total:  user.Points,
+		Type:   record.Type,
+	})
+}
+
+// SendOperation queues a revoke event for each record of
+// an applied revoke. Other operations have no events.
+func SendOperation(db DB, op *database.Operation) error {
+	if op.Kind != database.OperationRevoke {
+		return nil
+	}
+
+	for _, record := range op.Records {
+		err := SendKarma(db, EventRevoke, record)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Sign returns the signature of a body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a body, as receivers should.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// A Store holds the deliveries that are due.
+type Store interface {
+	DueDeliveries(now time.Time, limit int) ([]*database.Delivery, error)
+	UpdateDelivery(d *database.Delivery) error
+}
+
+// A Sender delivers the queued events of a database.
+type Sender struct {
+	Store  Store
+	Client *http.Client
+	Log    *log.Log
+
+	// Interval is how often the sender looks for due deliveries.
+	Interval time.Duration
+
+	// A failed delivery is retried after Backoff, which doubles
+	// with every attempt up to MaxBackoff, until MaxAttempts
+	// attempts have failed.
+	Backoff, MaxBackoff time.Duration
+	MaxAttempts         int
+}
+
+// batch is the amount of deliveries that are sent at once.
+const batch = 50
+
+// New returns a Sender with the default settings.
+func New(store Store, ll *log.Log) *Sender {
+	return &Sender{
+		Store:       store,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		Log:         ll,
+		Interval:    5 * time.Second,
+		Backoff:     30 * time.Second,
+		MaxBackoff:  time.Hour,
+		MaxAttempts: 10,
+	}
+}
+
+// Run delivers events every interval until the context is cancelled.
+func (s *Sender) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := s.Deliver(ctx)
+			if err != nil {
+				s.Log.Err(err).Error("could not deliver webhook events")
+			}
+		}
+	}
+}
+
+// Deliver attempts the deliveries that are due once.
+func (s *Sender) Deliver(ctx context.Context) error {
+	deliveries, err := s.Store.DueDeliveries(time.Now(), batch)
+	if err != nil {
+		return err
+	}
+
+	for _, d := range deliveries {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		s.attempt(ctx, d)
+
+		err := s.Store.UpdateDelivery(d)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// attempt posts a delivery and records the outcome in it.
+func (s *Sender) attempt(ctx context.Context, d *database.Delivery) {
+	ll := s.Log.KV("delivery", d.ID).KV("url", d.URL).KV("event", d.Event)
+
+	d.Attempts++
+
+	var err error
+	d.Response, err = s.post(ctx, d)
+	if err == nil {
+		d.Status = database.DeliveryDelivered
+		d.Error = ""
+		d.Delivered = time.Now()
+		ll.Info("delivered webhook event")
+		return
+	}
+
+	d.Error = err.Error()
+	if d.Attempts >= s.MaxAttempts {
+		d.Status = database.DeliveryFailed
+		ll.Err(err).KV("attempts", d.Attempts).Error("giving up on webhook event")
+		return
+	}
+
+	d.NextAttempt = time.Now().Add(s.backoff(d.Attempts))
+	ll.Err(err).KV("attempts", d.Attempts).KV("retry", d.NextAttempt).Info("could not deliver webhook event, retrying")
+}
+
+// backoff returns how long to wait after a failed attempt.
+func (s *Sender) backoff(attempts int) time.Duration {
+	backoff := s.Backoff
+	for i := 1; i < attempts && backoff < s.MaxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > s.MaxBackoff {
+		return s.MaxBackoff
+	}
+
+	return backoff
+}
+
+// post sends a delivery and returns the status code of the response.
+// Any status but 2xx is an error.
+func (s *Sender) post(ctx context.Context, d *database.Delivery) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(ctx)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "janet")
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(d.ID, 10))
+	req.Header.Set(HeaderSignature, Sign(d.Secret, d.Payload))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// drain the body so that the connection can be reused
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
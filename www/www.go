@@ -0,0 +1,11 @@
+// Package www contains the default web UI templates and assets.
+// They are embedded into the janet binaries so that the web UI
+// works without any files on disk.
+package www
+
+import "embed"
+
+// FS contains the `templates` and `assets` directories.
+//
+//go:embed templates assets
+var FS embed.FS